@@ -2,15 +2,19 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"strings"
 
 	"github.com/Pirionfr/lookatch-agent/events"
+	"github.com/Pirionfr/lookatch-agent/processors"
 	"github.com/Pirionfr/lookatch-agent/sinks"
 	"github.com/Pirionfr/lookatch-agent/sources"
 	"github.com/Pirionfr/lookatch-agent/utils"
 
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"time"
@@ -34,6 +38,15 @@ const (
 	AgentStatusOnError        = "ON_ERROR"
 )
 
+// DefaultCheckpointInterval default interval between two offset store checkpoints
+const DefaultCheckpointInterval = 10 * time.Second
+
+// DefaultShutdownTimeout default time given to agent to stop gracefully
+const DefaultShutdownTimeout = 30 * time.Second
+
+// agentTarget task target of agent level tasks
+const agentTarget = "agent"
+
 // Agent representation of agent
 type (
 	Agent struct {
@@ -44,8 +57,13 @@ type (
 		uuid           uuid.UUID
 		srcMutex       sync.RWMutex
 		sources        map[string]sources.SourceI
+		sourceConfigs  map[string]string
 		sinksMutex     sync.RWMutex
 		sinks          map[string]sinks.SinkI
+		sinkConfigs    map[string]string
+		commitRouters  map[sinks.SinkI]*commitRouter
+		reloadMutex    sync.Mutex
+		muxMutex       sync.RWMutex
 		multiplexers   map[string]*Multiplexer
 		deMultiplexers map[string]*DeMultiplexer
 		controller     *Controller
@@ -53,6 +71,11 @@ type (
 		encryptionKey  string
 		status         string
 		processingTask bool
+		offsetStore    sources.OffsetStore
+		checkpointStop chan struct{}
+		// configuration values set by tasks, by kind then component name
+		// merged again on top of configuration after each reload
+		taskConfig map[string]map[string]map[string]interface{}
 	}
 )
 
@@ -78,7 +101,10 @@ func newAgent(config *viper.Viper, s chan error) (a *Agent) {
 		hostname:       config.GetString("agent.hostname"),
 		config:         config,
 		sources:        make(map[string]sources.SourceI),
+		sourceConfigs:  make(map[string]string),
 		sinks:          make(map[string]sinks.SinkI),
+		sinkConfigs:    make(map[string]string),
+		commitRouters:  make(map[sinks.SinkI]*commitRouter),
 		multiplexers:   make(map[string]*Multiplexer),
 		deMultiplexers: make(map[string]*DeMultiplexer),
 		encryptionKey:  config.GetString("agent.encryptionKey"),
@@ -100,8 +126,12 @@ func newAgent(config *viper.Viper, s chan error) (a *Agent) {
 // if controller part is present in config file
 // remote will be init
 // else agent will be standalone mode
-func Run(config *viper.Viper, s chan error) (err error) {
-	a := newAgent(config, s)
+func Run(config *viper.Viper, s chan error) (a *Agent, err error) {
+	a = newAgent(config, s)
+	a.offsetStore, err = sources.NewOffsetStore(config)
+	if err != nil {
+		return nil, errors.Annotate(err, "error while creating offset store")
+	}
 	a.healthCheckChecker()
 	if config.Get("controller") != nil {
 		err = a.RemoteInit()
@@ -109,16 +139,19 @@ func Run(config *viper.Viper, s chan error) (err error) {
 		err = a.InitAgent()
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	err = a.Start()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	a.status = AgentStatusOnline
-	return nil
+	if config.GetBool("agent.watch_config") {
+		a.WatchConfig()
+	}
+	return a, nil
 }
 
 // RemoteInit init controller
@@ -243,9 +276,222 @@ func (a *Agent) Start() error {
 			return err
 		}
 	}
+
+	if a.offsetStore != nil {
+		a.checkpointStop = make(chan struct{})
+		go a.checkpointer(a.checkpointStop)
+	}
+	return nil
+}
+
+// Stop agent gracefully
+// sources are stopped first, events already read are sent to sinks,
+// sinks flush and commit pending events, dead letter sinks after the sinks rejecting to them,
+// then offsets are saved and reported
+// remaining steps are skipped when context is done
+func (a *Agent) Stop(ctx context.Context) (err error) {
+	log.Info("Stopping agent")
+	a.stopComponents(ctx)
+
+	for name, demux := range a.getDeMultiplexers() {
+		if errStop := demux.Drain(ctx); errStop != nil {
+			log.WithError(errStop).WithField("source", name).Error("Error while waiting for commits")
+		}
+	}
+
+	a.status = AgentStatusOffline
+	err = a.SaveOffsets()
+	if err != nil {
+		log.WithError(err).Error("Error while saving offsets")
+	}
+
+	if a.controller != nil {
+		if errMeta := a.controller.SendMeta(a.getMetas()); errMeta != nil {
+			log.WithError(errMeta).Error("Error while sending last meta")
+		}
+	}
+	log.Info("Agent stopped")
+	return err
+}
+
+// stopComponents stop checkpointer, sources, multiplexers then sinks still running
+func (a *Agent) stopComponents(ctx context.Context) {
+	if a.checkpointStop != nil {
+		close(a.checkpointStop)
+		a.checkpointStop = nil
+	}
+
+	for name, source := range a.getSources() {
+		if errStop := stopWithContext(ctx, source.Stop); errStop != nil {
+			log.WithError(errStop).WithField("source", name).Error("Error while stopping source")
+		}
+	}
+
+	for name, multiplexer := range a.getMultiplexers() {
+		if errStop := multiplexer.Stop(ctx); errStop != nil {
+			log.WithError(errStop).WithField("source", name).Error("Error while draining multiplexer")
+		}
+	}
+
+	sinksMap := a.getSinks()
+	for _, name := range a.sinksStopOrder(sinksMap) {
+		sink := sinksMap[name]
+		in := sink.GetInputChan()
+		errStop := waitUntil(ctx, func() bool {
+			return len(in) == 0
+		})
+		if errStop == nil {
+			errStop = stopWithContext(ctx, sink.Stop)
+		}
+		if errStop != nil {
+			log.WithError(errStop).WithField("sink", name).Error("Error while stopping sink")
+		}
+	}
+}
+
+// Restart stop agent then rebuild it from current configuration
+// agent not online is not stopped gracefully, its offsets are not saved,
+// but its components which may still be running are stopped before being dropped
+func (a *Agent) Restart(ctx context.Context) error {
+	if a.status == AgentStatusOnline {
+		err := a.Stop(ctx)
+		if err != nil {
+			return errors.Annotate(err, "error while stopping agent")
+		}
+	} else {
+		a.stopComponents(ctx)
+	}
+	return a.rebuild()
+}
+
+// rebuild drop all sources, sinks and multiplexers
+// then create them again from current configuration and start them
+func (a *Agent) rebuild() (err error) {
+	for _, demux := range a.getDeMultiplexers() {
+		demux.Stop()
+	}
+	a.srcMutex.Lock()
+	a.sources = make(map[string]sources.SourceI)
+	a.sourceConfigs = make(map[string]string)
+	a.srcMutex.Unlock()
+	a.sinksMutex.Lock()
+	for _, router := range a.commitRouters {
+		router.stop()
+	}
+	a.sinks = make(map[string]sinks.SinkI)
+	a.sinkConfigs = make(map[string]string)
+	a.commitRouters = make(map[sinks.SinkI]*commitRouter)
+	a.sinksMutex.Unlock()
+	a.muxMutex.Lock()
+	a.multiplexers = make(map[string]*Multiplexer)
+	a.deMultiplexers = make(map[string]*DeMultiplexer)
+	a.muxMutex.Unlock()
+
+	a.status = AgentStatusStarting
+	defer func() {
+		if err != nil {
+			a.status = AgentStatusOnError
+		}
+	}()
+
+	err = a.InitAgent()
+	if err != nil {
+		return errors.Annotate(err, "error while initializing agent")
+	}
+	if a.controller != nil {
+		a.InitRemoteMeta()
+	}
+	err = a.Start()
+	if err != nil {
+		return errors.Annotate(err, "error while starting agent")
+	}
+	a.status = AgentStatusOnline
+	return nil
+}
+
+// ShutdownTimeout return time given to agent to stop gracefully
+func (a *Agent) ShutdownTimeout() time.Duration {
+	if a.config.IsSet("agent.shutdown_timeout") {
+		wait, err := time.ParseDuration(a.config.GetString("agent.shutdown_timeout"))
+		if err == nil {
+			return wait
+		}
+		log.WithError(err).Error("Error while parsing shutdown timeout")
+	}
+	return DefaultShutdownTimeout
+}
+
+// stopWithContext call stop func and return its error
+// or context error if context is done before
+func stopWithContext(ctx context.Context, stop func() error) error {
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- stop()
+	}()
+	select {
+	case err := <-stopped:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitUntil check condition periodically until it is true
+// return context error if context is done before
+func waitUntil(ctx context.Context, condition func() bool) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for !condition() {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 	return nil
 }
 
+// checkpointer save sources offsets in offset store at each tick until stop is closed
+func (a *Agent) checkpointer(stop chan struct{}) {
+	interval := DefaultCheckpointInterval
+	if a.config.IsSet("agent.offset_store.interval") {
+		wait, err := time.ParseDuration(a.config.GetString("agent.offset_store.interval"))
+		if err != nil {
+			log.WithError(err).Error("Error while parsing offset store interval")
+		} else {
+			interval = wait
+		}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		err := a.SaveOffsets()
+		if err != nil {
+			log.WithError(err).Error("Error while saving offsets")
+		}
+	}
+}
+
+// SaveOffsets save checkpoint of all sources and flush offset store
+func (a *Agent) SaveOffsets() error {
+	if a.offsetStore == nil {
+		return nil
+	}
+	for name, source := range a.getSources() {
+		err := a.offsetStore.Save(name, source.GetCheckpoint())
+		if err != nil {
+			return errors.Annotatef(err, "error while saving checkpoint of '%s'", name)
+		}
+	}
+	return a.offsetStore.Flush()
+}
+
 // LoadSources Load all Sources
 // init sources from conf
 func (a *Agent) LoadSources(multiplexer *map[string][]string, demux *map[string][]string) (err error) {
@@ -289,11 +535,14 @@ func (a *Agent) LoadSource(sourceName string, sourceType string) (err error) {
 		return errors.New(sourceName + ".Source already exists")
 	}
 	//create sources
-	aSource, err := sources.New(sourceName, sourceType, a.config)
+	aSource, err := sources.New(sourceName, sourceType, a.config, a.offsetStore)
 	if err != nil {
 		return errors.Annotatef(err, "error creating new source")
 	}
 	a.setSource(sourceName, aSource)
+	a.srcMutex.Lock()
+	a.sourceConfigs[sourceName] = a.configSnapshot("sources", sourceName)
+	a.srcMutex.Unlock()
 	return
 }
 
@@ -316,8 +565,18 @@ func (a *Agent) LoadSinks() (err error) {
 	}
 
 	if len(a.getSinks()) == 0 {
-		err = errors.New("No sinks found")
+		return errors.New("No sinks found")
+	}
+
+	sinkNames := make(map[string]bool)
+	for sinkName := range a.getSinks() {
+		sinkNames[sinkName] = true
+	}
+	err = a.checkDeadLetters(sinkNames)
+	if err != nil {
+		return
 	}
+	a.linkDeadLetters(a.getSinks())
 	return
 }
 
@@ -335,8 +594,10 @@ func (a *Agent) LoadSink(sinkName string, sinkType string) (err error) {
 	if err != nil {
 		return errors.Annotatef(err, "error creating new sink")
 	}
+	aSink.SetSchemaProvider(a.getSourceSchema)
 
 	a.setSink(sinkName, aSink)
+	a.setSinkConfig(sinkName, a.configSnapshot("sinks", sinkName))
 
 	return
 }
@@ -345,7 +606,8 @@ func (a *Agent) LoadSink(sinkName string, sinkType string) (err error) {
 // each source as is own multiplexer
 func (a *Agent) LoadMultiplexer(multiplexer *map[string][]string) error {
 	for sourceName, sinkList := range *multiplexer {
-		var sinksChan []chan events.LookatchEvent
+		sinksChan := make(map[string]chan events.LookatchEvent)
+		linksConfig := make(map[string]LinkConfig)
 		src, found := a.getSource(sourceName)
 		if !found {
 			return errors.Errorf("Source '%s' not found\n", sourceName)
@@ -356,23 +618,133 @@ func (a *Agent) LoadMultiplexer(multiplexer *map[string][]string) error {
 			if !found {
 				return errors.Errorf("sink name '%s' not found\n", sinkName)
 			}
-			sinksChan = append(sinksChan, aSink.GetInputChan())
+			sinksChan[sinkName] = aSink.GetInputChan()
+			linksConfig[sinkName] = a.getLinkConfig(sourceName, sinkName)
 			log.WithFields(
 				log.Fields{
 					"sourceName": sourceName,
 					"sinkName":   sinkName,
 				}).Debug("create link")
 		}
-		a.multiplexers[sourceName] = NewMultiplexer(src.GetOutputChan(), sinksChan)
+		var coordinator *CommitCoordinator
+		if demux, ok := a.getDeMultiplexer(sourceName); ok {
+			coordinator = demux.GetCoordinator()
+		}
+		pipeline, err := a.getPipeline(sourceName)
+		if err != nil {
+			return errors.Annotatef(err, "invalid processors for '%s'", sourceName)
+		}
+		dedup, err := a.getDeduplicator(sourceName)
+		if err != nil {
+			return errors.Annotatef(err, "invalid deduplication for '%s'", sourceName)
+		}
+		if demux, ok := a.getDeMultiplexer(sourceName); ok && dedup != nil {
+			demux.SetDeduplicator(dedup)
+		}
+		mux := NewMultiplexer(src.GetOutputChan(), sinksChan, linksConfig, coordinator)
+		mux.SetSourceName(sourceName)
+		mux.SetPipeline(pipeline)
+		if dedup != nil {
+			mux.SetDeduplicator(dedup)
+		}
+		enricher, err := a.getEnricher(sourceName)
+		if err != nil {
+			return errors.Annotatef(err, "invalid metadata for '%s'", sourceName)
+		}
+		if enricher != nil {
+			mux.SetEnricher(enricher)
+		}
+		a.setMultiplexer(sourceName, mux)
 	}
 	return nil
 }
 
+// getPipeline create processors of source from configuration
+func (a *Agent) getPipeline(sourceName string) (processors.Pipeline, error) {
+	var configs []processors.Config
+	err := a.config.UnmarshalKey("sources."+sourceName+".processors", &configs)
+	if err != nil {
+		return nil, err
+	}
+	return processors.NewPipeline(configs)
+}
+
+// getEnricher create enricher of source from its metadata fields, nil if none is set
+func (a *Agent) getEnricher(sourceName string) (*enricher, error) {
+	fields := a.config.GetStringSlice("sources." + sourceName + ".metadata")
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	hostname := a.hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	return newEnricher(fields, events.Metadata{
+		AgentUUID:  a.config.GetString("agent.uuid"),
+		Hostname:   hostname,
+		SourceName: sourceName,
+		SourceType: a.config.GetString("sources." + sourceName + ".type"),
+	})
+}
+
+// getDedupConfig read deduplication configuration of source
+// windows are stored by default under agent dedup path
+func (a *Agent) getDedupConfig(sourceName string) (config DedupConfig, err error) {
+	err = a.config.UnmarshalKey("sources."+sourceName+".dedup", &config)
+	if err != nil {
+		return
+	}
+	if config.Window != "" {
+		if _, err = time.ParseDuration(config.Window); err != nil {
+			return config, errors.Annotate(err, "invalid deduplication window")
+		}
+	}
+	if config.Path == "" {
+		dedupPath := DefaultDedupPath
+		if a.config.IsSet("agent.dedup_path") {
+			dedupPath = a.config.GetString("agent.dedup_path")
+		}
+		config.Path = filepath.Join(dedupPath, sourceName)
+	}
+	return
+}
+
+// getDeduplicator open deduplication window of source, nil if deduplication is not enabled
+func (a *Agent) getDeduplicator(sourceName string) (*deduplicator, error) {
+	config, err := a.getDedupConfig(sourceName)
+	if err != nil || !config.Enabled {
+		return nil, err
+	}
+	return newDeduplicator(config)
+}
+
+// getLinkConfig read queue configuration of link between a source and a sink
+// spilled events are stored by default under agent spill path
+func (a *Agent) getLinkConfig(sourceName string, sinkName string) (config LinkConfig) {
+	if conf := a.config.Sub("sources." + sourceName + ".links." + sinkName); conf != nil {
+		err := conf.Unmarshal(&config)
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"sourceName": sourceName,
+				"sinkName":   sinkName,
+			}).Error("Error while reading link config")
+		}
+	}
+	if config.SpillPath == "" {
+		spillPath := DefaultSpillPath
+		if a.config.IsSet("agent.spill_path") {
+			spillPath = a.config.GetString("agent.spill_path")
+		}
+		config.SpillPath = filepath.Join(spillPath, sourceName)
+	}
+	return
+}
+
 // LoadDeMultiplexer setup DeMultiplexer
 // each source has its own DeMultiplexer
 func (a *Agent) LoadDeMultiplexer(demux *map[string][]string) error {
 	for sourceName, sinkList := range *demux {
-		var sinksChan []chan interface{}
+		sinksChan := make(map[string]chan interface{})
 		src, found := a.getSource(sourceName)
 		if !found {
 			return errors.Errorf("Source '%s' not found\n", sourceName)
@@ -383,9 +755,9 @@ func (a *Agent) LoadDeMultiplexer(demux *map[string][]string) error {
 			if !found {
 				return errors.Errorf("sink name '%s' not found\n", sinkName)
 			}
-			sinksChan = append(sinksChan, aSink.GetCommitChan())
+			sinksChan[sinkName] = a.commitChan(sourceName, aSink)
 		}
-		a.deMultiplexers[sourceName] = NewDemultiplexer(sinksChan, src.GetCommitChan())
+		a.setDeMultiplexer(sourceName, NewDemultiplexer(sinksChan, src.GetCommitChan()))
 
 	}
 	return nil
@@ -394,7 +766,10 @@ func (a *Agent) LoadDeMultiplexer(demux *map[string][]string) error {
 // getSources get all sources
 func (a *Agent) getSources() map[string]sources.SourceI {
 	a.srcMutex.RLock()
-	src := a.sources
+	src := make(map[string]sources.SourceI, len(a.sources))
+	for k, v := range a.sources {
+		src[k] = v
+	}
 	a.srcMutex.RUnlock()
 	return src
 }
@@ -407,6 +782,15 @@ func (a *Agent) getSource(sourceName string) (sources.SourceI, bool) {
 	return src, ok
 }
 
+// getSourceSchema return schema of source, nil if source is not found
+func (a *Agent) getSourceSchema(sourceName string) map[string]map[string]*sources.Column {
+	src, ok := a.getSource(sourceName)
+	if !ok {
+		return nil
+	}
+	return src.GetSchema()
+}
+
 // setSources add new source
 func (a *Agent) setSource(sourceName string, src sources.SourceI) {
 	a.srcMutex.Lock()
@@ -414,10 +798,25 @@ func (a *Agent) setSource(sourceName string, src sources.SourceI) {
 	a.srcMutex.Unlock()
 }
 
+// deleteSource remove source and its multiplexers
+func (a *Agent) deleteSource(sourceName string) {
+	a.srcMutex.Lock()
+	delete(a.sources, sourceName)
+	delete(a.sourceConfigs, sourceName)
+	a.srcMutex.Unlock()
+	a.muxMutex.Lock()
+	delete(a.multiplexers, sourceName)
+	delete(a.deMultiplexers, sourceName)
+	a.muxMutex.Unlock()
+}
+
 // getSinks get all sinks
 func (a *Agent) getSinks() map[string]sinks.SinkI {
 	a.sinksMutex.RLock()
-	sink := a.sinks
+	sink := make(map[string]sinks.SinkI, len(a.sinks))
+	for k, v := range a.sinks {
+		sink[k] = v
+	}
 	a.sinksMutex.RUnlock()
 	return sink
 }
@@ -437,7 +836,122 @@ func (a *Agent) setSink(sinkName string, s sinks.SinkI) {
 	a.sinksMutex.Unlock()
 }
 
-// HealthCheck returns true if agent and all source are up
+// setSinkConfig record configuration of a running sink
+func (a *Agent) setSinkConfig(sinkName string, snapshot string) {
+	a.sinksMutex.Lock()
+	a.sinkConfigs[sinkName] = snapshot
+	a.sinksMutex.Unlock()
+}
+
+// deleteSink remove sink and stop dispatching its commits
+func (a *Agent) deleteSink(sinkName string) {
+	a.sinksMutex.Lock()
+	if router, ok := a.commitRouters[a.sinks[sinkName]]; ok {
+		router.stop()
+		delete(a.commitRouters, a.sinks[sinkName])
+	}
+	delete(a.sinks, sinkName)
+	delete(a.sinkConfigs, sinkName)
+	a.sinksMutex.Unlock()
+}
+
+// commitChan return channel of offsets committed by sink for source
+// commits of a sink are dispatched by source, so a sink can be linked to several sources
+func (a *Agent) commitChan(sourceName string, aSink sinks.SinkI) chan interface{} {
+	a.sinksMutex.Lock()
+	router, ok := a.commitRouters[aSink]
+	if !ok {
+		router = newCommitRouter(aSink.GetName(), aSink.GetCommitChan())
+		a.commitRouters[aSink] = router
+	}
+	a.sinksMutex.Unlock()
+	return router.channel(sourceName)
+}
+
+// removeCommitChan stop dispatching offsets committed by sink for source
+func (a *Agent) removeCommitChan(sourceName string, aSink sinks.SinkI) {
+	a.sinksMutex.RLock()
+	router, ok := a.commitRouters[aSink]
+	a.sinksMutex.RUnlock()
+	if ok {
+		router.remove(sourceName)
+	}
+}
+
+// pendingCommits return number of offsets committed by sink not yet read by sources
+func (a *Agent) pendingCommits(aSink sinks.SinkI) int {
+	a.sinksMutex.RLock()
+	router, ok := a.commitRouters[aSink]
+	a.sinksMutex.RUnlock()
+	if !ok {
+		return len(aSink.GetCommitChan())
+	}
+	return router.pending()
+}
+
+// stopCommitRouter stop dispatching offsets committed by a replaced sink
+func (a *Agent) stopCommitRouter(aSink sinks.SinkI) {
+	a.sinksMutex.Lock()
+	defer a.sinksMutex.Unlock()
+	if router, ok := a.commitRouters[aSink]; ok {
+		router.stop()
+		delete(a.commitRouters, aSink)
+	}
+}
+
+// getMultiplexers get all multiplexers
+func (a *Agent) getMultiplexers() map[string]*Multiplexer {
+	a.muxMutex.RLock()
+	multiplexers := make(map[string]*Multiplexer, len(a.multiplexers))
+	for k, v := range a.multiplexers {
+		multiplexers[k] = v
+	}
+	a.muxMutex.RUnlock()
+	return multiplexers
+}
+
+// getMultiplexer get multiplexer of a source
+func (a *Agent) getMultiplexer(sourceName string) (*Multiplexer, bool) {
+	a.muxMutex.RLock()
+	multiplexer, ok := a.multiplexers[sourceName]
+	a.muxMutex.RUnlock()
+	return multiplexer, ok
+}
+
+// setMultiplexer add multiplexer of a source
+func (a *Agent) setMultiplexer(sourceName string, multiplexer *Multiplexer) {
+	a.muxMutex.Lock()
+	a.multiplexers[sourceName] = multiplexer
+	a.muxMutex.Unlock()
+}
+
+// getDeMultiplexers get all DeMultiplexers
+func (a *Agent) getDeMultiplexers() map[string]*DeMultiplexer {
+	a.muxMutex.RLock()
+	demux := make(map[string]*DeMultiplexer, len(a.deMultiplexers))
+	for k, v := range a.deMultiplexers {
+		demux[k] = v
+	}
+	a.muxMutex.RUnlock()
+	return demux
+}
+
+// getDeMultiplexer get DeMultiplexer of a source
+func (a *Agent) getDeMultiplexer(sourceName string) (*DeMultiplexer, bool) {
+	a.muxMutex.RLock()
+	demux, ok := a.deMultiplexers[sourceName]
+	a.muxMutex.RUnlock()
+	return demux, ok
+}
+
+// setDeMultiplexer add DeMultiplexer of a source
+func (a *Agent) setDeMultiplexer(sourceName string, demux *DeMultiplexer) {
+	a.muxMutex.Lock()
+	a.deMultiplexers[sourceName] = demux
+	a.muxMutex.Unlock()
+}
+
+// HealthCheck returns true if agent and all sources and sinks are up
 func (a *Agent) HealthCheck() (alive bool) {
 	if a.status == AgentStatusStarting {
 		return true
@@ -449,6 +963,13 @@ func (a *Agent) HealthCheck() (alive bool) {
 			return false
 		}
 	}
+
+	sinkList := a.getSinks()
+	for _, sink := range sinkList {
+		if !sink.HealthCheck() {
+			return false
+		}
+	}
 	return true
 }
 
@@ -464,6 +985,18 @@ func (a *Agent) getSourceCapabilities() map[string]map[string]*utils.TaskDescrip
 	return sourceAction
 }
 
+// getSinkCapabilities get sink capabilities from sinks
+// return TaskDescription for each sink
+func (a *Agent) getSinkCapabilities() map[string]map[string]*utils.TaskDescription {
+	var sinkAction = make(map[string]map[string]*utils.TaskDescription)
+	sinkList := a.getSinks()
+	for _, sink := range sinkList {
+		sinkAction[sink.GetName()] = sink.GetCapabilities()
+	}
+
+	return sinkAction
+}
+
 // getCapabilities get agent Capabilities
 // return it as TaskDescription map
 func (a *Agent) getCapabilities() map[string]*utils.TaskDescription {
@@ -471,16 +1004,30 @@ func (a *Agent) getCapabilities() map[string]*utils.TaskDescription {
 	action[utils.AgentStart] = utils.DeclareNewTaskDescription(nil, "Start agent")
 	action[utils.AgentStop] = utils.DeclareNewTaskDescription(nil, "Stop agent")
 	action[utils.AgentRestart] = utils.DeclareNewTaskDescription(nil, "Restart agent")
+	for taskType, description := range getComponentCapabilities() {
+		action[taskType] = description
+	}
 	return action
 }
 
 // getSourceMeta get source meta from sources
 // return Metas for each source
+// commit coordinator and link queues metas are added to their source metas
 func (a *Agent) getSourceMeta() map[string]map[string]utils.Meta {
 	var sourceMeta = make(map[string]map[string]utils.Meta)
 	sourceList := a.getSources()
 	for _, source := range sourceList {
 		sourceMeta[source.GetName()] = source.GetMeta()
+		if demux, ok := a.getDeMultiplexer(source.GetName()); ok {
+			for k, v := range demux.GetCoordinator().GetMeta() {
+				sourceMeta[source.GetName()][k] = v
+			}
+		}
+		if multiplexer, ok := a.getMultiplexer(source.GetName()); ok {
+			for k, v := range multiplexer.GetMeta() {
+				sourceMeta[source.GetName()][k] = v
+			}
+		}
 	}
 
 	return sourceMeta
@@ -498,6 +1045,30 @@ func (a *Agent) getSourceStatus() map[string]utils.Meta {
 	return sourceStatus
 }
 
+// getSinkMeta get sink meta from sinks
+// return Metas for each sink
+func (a *Agent) getSinkMeta() map[string]map[string]utils.Meta {
+	var sinkMeta = make(map[string]map[string]utils.Meta)
+	sinkList := a.getSinks()
+	for _, sink := range sinkList {
+		sinkMeta[sink.GetName()] = sink.GetMeta()
+	}
+
+	return sinkMeta
+}
+
+// getSinkStatus get status from sinks
+// return status of each sink as Meta
+func (a *Agent) getSinkStatus() map[string]utils.Meta {
+	var sinkStatus = make(map[string]utils.Meta)
+	sinkList := a.getSinks()
+	for _, sink := range sinkList {
+		sinkStatus[sink.GetName()] = utils.NewMeta("status", sink.GetStatus())
+	}
+
+	return sinkStatus
+}
+
 // GetSchemas get schema from sources
 func (a *Agent) GetSchemas() map[string]map[string]map[string]*sources.Column {
 	var sourceSchemas = make(map[string]map[string]map[string]*sources.Column)
@@ -566,19 +1137,8 @@ func (a *Agent) Poller() {
 // if number is > 0 get the oldest task in TODO, , PENDING or IN_PROGRESS status
 // from server and handle it
 func (a *Agent) sendMetaAndGetProcessTask() (err error) {
-	metas := utils.NewMetas()
 	log.Debug("Send Meta")
-
-	//get meta from sources
-	metas.Sources = a.getSourceMeta()
-	for k, v := range a.getSourceStatus() {
-		metas.SetMetaSources(k, v)
-	}
-	//set status
-	metas.Agent["status"] = utils.NewMeta("status", a.status)
-	metas.Agent["version"] = utils.NewMeta("version", a.config.GetString("agent.version"))
-	metas.Agent["date"] = utils.NewMeta("date", a.config.GetString("agent.date"))
-	err = a.controller.SendMeta(metas)
+	err = a.controller.SendMeta(a.getMetas())
 	if err != nil {
 		return
 	}
@@ -602,6 +1162,27 @@ func (a *Agent) sendMetaAndGetProcessTask() (err error) {
 	return err
 }
 
+// getMetas get meta of agent, sources and sinks
+func (a *Agent) getMetas() utils.Metas {
+	metas := utils.NewMetas()
+
+	//get meta from sources
+	metas.Sources = a.getSourceMeta()
+	for k, v := range a.getSourceStatus() {
+		metas.SetMetaSources(k, v)
+	}
+	//get meta from sinks
+	metas.Sinks = a.getSinkMeta()
+	for k, v := range a.getSinkStatus() {
+		metas.SetMetaSinks(k, v)
+	}
+	//set status
+	metas.Agent["status"] = utils.NewMeta("status", a.status)
+	metas.Agent["version"] = utils.NewMeta("version", a.config.GetString("agent.version"))
+	metas.Agent["date"] = utils.NewMeta("date", a.config.GetString("agent.date"))
+	return metas
+}
+
 // SendCapabilities send capability to server
 func (a *Agent) SendCapabilities() (err error) {
 	err = a.controller.SendCapabilities(a.getCapabilities())
@@ -618,6 +1199,16 @@ func (a *Agent) SendCapabilities() (err error) {
 			}
 		}
 	}
+
+	sinkTask := a.getSinkCapabilities()
+	for k, v := range sinkTask {
+		if v != nil {
+			err = a.controller.SendSinksCapabilities(k, v)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	return
 }
 
@@ -655,10 +1246,18 @@ func (a *Agent) ProcessTask(task utils.Task) (err error) {
 		log.WithError(err).Error("Error while Updating task")
 	}
 
+	err = a.runTask(task)
+	return
+}
+
+// runTask run task on its target
+// target is 'agent' or agent uuid for agent tasks, 'sources::<name>' or 'sinks::<name>' otherwise
+func (a *Agent) runTask(task utils.Task) (err error) {
 	target := strings.Split(task.Target, "::")
+	switch target[0] {
 	//handle source task
-	if target[0] == "sources" {
-		s, ok := a.getSource(target[1])
+	case "sources":
+		s, ok := a.getSource(targetName(target))
 		if !ok {
 			err = errors.New("source name not found")
 			return
@@ -681,6 +1280,62 @@ func (a *Agent) ProcessTask(task utils.Task) (err error) {
 				err = errProcess
 			}
 		}
+	//handle agent task
+	case agentTarget, a.uuid.String():
+		a.reloadMutex.Lock()
+		defer a.reloadMutex.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), a.ShutdownTimeout())
+		defer cancel()
+		switch task.TaskType {
+		case utils.SourceAdd, utils.SourceRemove, utils.SinkAdd, utils.SinkRemove, utils.SinkLink, utils.SinkUnlink:
+			err = a.runComponentTask(task)
+
+		case utils.AgentStop:
+			if a.status == AgentStatusOnline {
+				err = a.Stop(ctx)
+			}
+
+		case utils.AgentStart:
+			if a.status != AgentStatusOnline {
+				err = a.Restart(ctx)
+			}
+
+		case utils.AgentRestart:
+			err = a.Restart(ctx)
+		default:
+			err = errors.Errorf("task '%s' not implemented for agent", task.TaskType)
+		}
+	//handle sink task
+	case "sinks":
+		s, ok := a.getSink(targetName(target))
+		if !ok {
+			err = errors.New("sink name not found")
+			return
+		}
+		switch task.TaskType {
+		case utils.SinkStop:
+			err = s.Stop()
+
+		case utils.SinkStart:
+			err = s.Start()
+
+		case utils.SinkRestart:
+			if err = s.Stop(); err == nil {
+				err = s.Start()
+			}
+		default:
+			err = errors.Errorf("task '%s' not implemented for sink", task.TaskType)
+		}
+	default:
+		err = errors.Errorf("unknown task target '%s'", task.Target)
 	}
-	return nil
+	return
+}
+
+// targetName return component name of a task target split on '::'
+func targetName(target []string) string {
+	if len(target) < 2 {
+		return ""
+	}
+	return target[1]
 }
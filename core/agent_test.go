@@ -1,13 +1,17 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Pirionfr/lookatch-agent/sinks"
 	"github.com/Pirionfr/lookatch-agent/sources"
 
 	log "github.com/sirupsen/logrus"
@@ -64,6 +68,8 @@ func init() {
 			w.WriteHeader(http.StatusNoContent)
 		case strings.Replace(strings.Replace(sourceCapabilitiesPath, agentIDParamPath, TestUUID, 1), sourceIDParamPath, "default", 1):
 			w.WriteHeader(http.StatusNoContent)
+		case strings.Replace(strings.Replace(sinkCapabilitiesPath, agentIDParamPath, TestUUID, 1), sinkIDParamPath, "default", 1):
+			w.WriteHeader(http.StatusNoContent)
 		}
 
 	}
@@ -282,6 +288,47 @@ func TestGetSourceStatus(t *testing.T) {
 
 }
 
+func TestGetSinkCapabilities(t *testing.T) {
+	agent := NewTestAgent()
+
+	agent.InitAgent()
+
+	taskdesc := agent.getSinkCapabilities()
+
+	if taskdesc["default"][utils.SinkRestart] == nil {
+		t.Fail()
+	}
+}
+
+func TestGetSinkMeta(t *testing.T) {
+	agent := NewTestAgent()
+
+	agent.InitAgent()
+
+	sinkMeta := agent.getSinkMeta()
+
+	if len(sinkMeta["default"]) == 0 {
+		t.Fail()
+	}
+}
+
+func TestGetSinkStatus(t *testing.T) {
+	agent := NewTestAgent()
+
+	agent.InitAgent()
+
+	sinkStatus := agent.getSinkStatus()
+	if sinkStatus["default"].Value != sinks.SinkStatusWaiting {
+		t.Fail()
+	}
+
+	agent.Start()
+	sinkStatus = agent.getSinkStatus()
+	if sinkStatus["default"].Value != sinks.SinkStatusRunning {
+		t.Fail()
+	}
+}
+
 func TestGetSourceSchema(t *testing.T) {
 	agent := NewTestAgent()
 
@@ -382,6 +429,34 @@ func TestProcessTask4(t *testing.T) {
 
 }
 
+func TestProcessSinkTask(t *testing.T) {
+	agent := NewTestAgent()
+
+	err := agent.updateConfig([]byte(ConfJSON))
+	if err != nil {
+		t.Error(err)
+	}
+	agent.InitAgent()
+
+	aTask := []utils.Task{}
+
+	if json.Unmarshal([]byte(TaskJSON), &aTask) != nil {
+		t.Fail()
+	}
+	aTask[0].Target = "sinks::default"
+	aTask[0].TaskType = utils.SinkRestart
+
+	err = agent.ProcessTask(aTask[0])
+	if err != nil {
+		t.Fail()
+	}
+
+	sink, _ := agent.getSink("default")
+	if !sink.HealthCheck() {
+		t.Fail()
+	}
+}
+
 func TestProcessTaskError(t *testing.T) {
 	agent := NewTestAgent()
 
@@ -404,6 +479,75 @@ func TestProcessTaskError(t *testing.T) {
 
 }
 
+func TestRunTaskUnknownTarget(t *testing.T) {
+	agent := NewTestAgent()
+
+	task := utils.Task{
+		Target:   "toto::default",
+		TaskType: utils.SourceStart,
+	}
+	if agent.runTask(task) == nil {
+		t.Fail()
+	}
+}
+
+func TestRunTaskSourceError(t *testing.T) {
+	agent := NewTestAgent()
+	agent.InitAgent()
+
+	task := utils.Task{
+		Target:   "sources::default",
+		TaskType: "toto",
+	}
+	if agent.runTask(task) == nil {
+		t.Fail()
+	}
+}
+
+func TestRunAgentTasks(t *testing.T) {
+	agent := NewTestAgent()
+	err := agent.rebuild()
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, _ := agent.getSource("default")
+
+	task := utils.Task{
+		Target:   agentTarget,
+		TaskType: utils.AgentStop,
+	}
+	err = agent.runTask(task)
+	if err != nil || agent.status != AgentStatusOffline {
+		t.Fail()
+	}
+
+	task.TaskType = utils.AgentStart
+	err = agent.runTask(task)
+	if err != nil || agent.status != AgentStatusOnline {
+		t.Fail()
+	}
+
+	restarted, ok := agent.getSource("default")
+	if !ok || restarted == source {
+		t.Fail()
+	}
+
+	task.TaskType = utils.AgentRestart
+	err = agent.runTask(task)
+	if err != nil || agent.status != AgentStatusOnline {
+		t.Fail()
+	}
+
+	task.TaskType = "toto"
+	if agent.runTask(task) == nil {
+		t.Fail()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	agent.Stop(ctx)
+}
+
 func TestAgentSendCapabilities(t *testing.T) {
 
 	agent := NewTestAgent()
@@ -447,3 +591,157 @@ func TestSendMetaAndGetProcessTask(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestSaveOffsets(t *testing.T) {
+	agent := NewTestAgent()
+	vStore := viper.New()
+	vStore.Set("agent.offset_store.type", sources.FileOffsetStoreType)
+	vStore.Set("agent.offset_store.path", t.TempDir()+"/offsets.json")
+
+	store, err := sources.NewOffsetStore(vStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.offsetStore = store
+
+	err = agent.InitAgent()
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = agent.SaveOffsets()
+	if err != nil {
+		t.Error(err)
+	}
+
+	checkpoint, err := store.Load("default")
+	if err != nil || checkpoint == nil {
+		t.Fail()
+	}
+}
+
+func TestAgentStop(t *testing.T) {
+	agent := NewTestAgent()
+	vStore := viper.New()
+	vStore.Set("agent.offset_store.type", sources.FileOffsetStoreType)
+	vStore.Set("agent.offset_store.path", t.TempDir()+"/offsets.json")
+
+	store, err := sources.NewOffsetStore(vStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.offsetStore = store
+
+	err = agent.InitAgent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = agent.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = agent.Stop(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if agent.status != AgentStatusOffline {
+		t.Fail()
+	}
+
+	checkpoint, err := store.Load("default")
+	if err != nil || checkpoint == nil {
+		t.Fail()
+	}
+}
+
+func TestGetPipeline(t *testing.T) {
+	config := viper.New()
+	config.Set("sources.default.processors", []map[string]interface{}{
+		{"type": "rename", "field": "a", "to": "b"},
+		{"type": "drop", "fields": []string{"c"}},
+	})
+	config.Set("sources.invalid.processors", []map[string]interface{}{{"type": "unknown"}})
+	agent := newAgent(config, make(chan error))
+
+	pipeline, err := agent.getPipeline("default")
+	if err != nil || len(pipeline) != 2 {
+		t.Fail()
+	}
+	if _, err = agent.getPipeline("invalid"); err == nil {
+		t.Fail()
+	}
+	if pipeline, err = agent.getPipeline("missing"); err != nil || len(pipeline) != 0 {
+		t.Fail()
+	}
+}
+
+func TestGetEnricher(t *testing.T) {
+	config := viper.New()
+	config.Set("agent.uuid", "agent-uuid")
+	config.Set("agent.hostname", "host")
+	config.Set("sources.default.type", "Random")
+	config.Set("sources.default.metadata", []string{"agent_uuid", "hostname", "source_name", "source_type"})
+	config.Set("sources.invalid.metadata", []string{"unknown"})
+	agent := newAgent(config, make(chan error))
+
+	enricher, err := agent.getEnricher("default")
+	if err != nil || enricher == nil {
+		t.Fatal(err)
+	}
+	metadata := enricher.metadata()
+	if metadata.AgentUUID != "agent-uuid" || metadata.Hostname != "host" || metadata.SourceName != "default" || metadata.SourceType != "Random" {
+		t.Errorf("unexpected metadata %+v", metadata)
+	}
+	if _, err = agent.getEnricher("invalid"); err == nil {
+		t.Fail()
+	}
+	if enricher, err = agent.getEnricher("missing"); err != nil || enricher != nil {
+		t.Fail()
+	}
+}
+
+func TestGetDedupConfig(t *testing.T) {
+	config := viper.New()
+	config.Set("agent.dedup_path", "/var/lib/lookatch/dedup")
+	config.Set("sources.default.dedup", map[string]interface{}{"enabled": true, "window": "1h"})
+	config.Set("sources.invalid.dedup", map[string]interface{}{"enabled": true, "window": "forever"})
+	agent := newAgent(config, make(chan error))
+
+	dedupConfig, err := agent.getDedupConfig("default")
+	if err != nil || !dedupConfig.Enabled || dedupConfig.Path != filepath.Join("/var/lib/lookatch/dedup", "default") {
+		t.Errorf("unexpected config %+v %v", dedupConfig, err)
+	}
+	if _, err = agent.getDedupConfig("invalid"); err == nil {
+		t.Fail()
+	}
+}
+
+func TestRestartOnError(t *testing.T) {
+	agent := newReloadAgent(t)
+	source, _ := agent.getSource("default")
+	sink, _ := agent.getSink("default")
+	agent.status = AgentStatusOnError
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := agent.Restart(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := source.(*sources.Random).Status; status != sources.SourceStatusWaiting {
+		t.Error("source not stopped", status)
+	}
+	if sink.GetStatus() != sinks.SinkStatusWaiting {
+		t.Error("sink not stopped", sink.GetStatus())
+	}
+	if s, _ := agent.getSource("default"); s == source {
+		t.Error("source not rebuilt")
+	}
+	if agent.status != AgentStatusOnline {
+		t.Error(agent.status)
+	}
+}
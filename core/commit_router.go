@@ -0,0 +1,118 @@
+package core
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Pirionfr/lookatch-agent/sinks"
+)
+
+// commitRouter dispatch offsets committed by a sink to the channel of their source
+// each source linked to the sink reads its own channel, so acks of a source are never consumed by another one
+type commitRouter struct {
+	sync.Mutex
+	sinkName string
+	in       chan interface{}
+	outs     map[string]chan interface{}
+	done     chan struct{}
+}
+
+// newCommitRouter create router and start dispatching offsets committed by sink
+func newCommitRouter(sinkName string, in chan interface{}) *commitRouter {
+	r := &commitRouter{
+		sinkName: sinkName,
+		in:       in,
+		outs:     make(map[string]chan interface{}),
+		done:     make(chan struct{}),
+	}
+	go r.dispatch()
+	return r
+}
+
+// channel return channel of offsets committed for source, created on first call
+func (r *commitRouter) channel(sourceName string) chan interface{} {
+	r.Lock()
+	defer r.Unlock()
+	out, ok := r.outs[sourceName]
+	if !ok {
+		out = make(chan interface{}, cap(r.in)+1)
+		r.outs[sourceName] = out
+	}
+	return out
+}
+
+// remove stop dispatching offsets committed for source
+func (r *commitRouter) remove(sourceName string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.outs, sourceName)
+}
+
+// dispatch send each committed offset to the channel of its source until router is stopped
+// offsets of sources no longer linked are dropped
+func (r *commitRouter) dispatch() {
+	for {
+		var commit interface{}
+		select {
+		case <-r.done:
+			return
+		case commit = <-r.in:
+		}
+
+		out, offset := r.route(commit)
+		if out == nil {
+			log.WithFields(log.Fields{
+				"sink":   r.sinkName,
+				"commit": commit,
+			}).Warn("Commit doesn't match any linked source")
+			continue
+		}
+		select {
+		case out <- offset:
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// route return channel and offset of a committed offset
+// untagged offsets are sent to the only linked source, nil channel is returned if there are several
+func (r *commitRouter) route(commit interface{}) (chan interface{}, interface{}) {
+	sourceName, offset := "", commit
+	if ack, ok := commit.(sinks.Ack); ok {
+		sourceName, offset = ack.SourceName, ack.Offset
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if sourceName != "" {
+		return r.outs[sourceName], offset
+	}
+	if len(r.outs) == 1 {
+		for _, out := range r.outs {
+			return out, offset
+		}
+	}
+	return nil, offset
+}
+
+// pending return number of offsets not yet read by sources
+func (r *commitRouter) pending() int {
+	r.Lock()
+	defer r.Unlock()
+	pending := len(r.in)
+	for _, out := range r.outs {
+		pending += len(out)
+	}
+	return pending
+}
+
+// stop stop dispatching offsets
+func (r *commitRouter) stop() {
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pirionfr/lookatch-agent/sinks"
+)
+
+// receive return next offset of channel, nil after timeout
+func receive(c chan interface{}) interface{} {
+	select {
+	case offset := <-c:
+		return offset
+	case <-time.After(time.Second):
+		return nil
+	}
+}
+
+func TestCommitRouterDispatch(t *testing.T) {
+	in := make(chan interface{}, 10)
+	router := newCommitRouter("default", in)
+	defer router.stop()
+	mysql := router.channel("mysql")
+	pg := router.channel("pg")
+
+	in <- sinks.Ack{SourceName: "pg", Offset: "0/16B3748"}
+	in <- sinks.Ack{SourceName: "mysql", Offset: "mysql-bin.000003:154:"}
+
+	if offset := receive(mysql); offset != "mysql-bin.000003:154:" {
+		t.Error(offset)
+	}
+	if offset := receive(pg); offset != "0/16B3748" {
+		t.Error(offset)
+	}
+}
+
+func TestCommitRouterUntagged(t *testing.T) {
+	in := make(chan interface{}, 10)
+	router := newCommitRouter("default", in)
+	defer router.stop()
+	mysql := router.channel("mysql")
+
+	in <- sinks.Ack{Offset: "1"}
+	in <- "2"
+	if offset := receive(mysql); offset != "1" {
+		t.Error(offset)
+	}
+	if offset := receive(mysql); offset != "2" {
+		t.Error(offset)
+	}
+
+	// untagged offsets can't be dispatched between several sources
+	pg := router.channel("pg")
+	in <- "3"
+	in <- sinks.Ack{SourceName: "pg", Offset: "4"}
+	if offset := receive(pg); offset != "4" {
+		t.Error(offset)
+	}
+	if len(mysql) != 0 {
+		t.Error("untagged offset dispatched")
+	}
+}
+
+func TestCommitRouterRemove(t *testing.T) {
+	in := make(chan interface{}, 10)
+	router := newCommitRouter("default", in)
+	defer router.stop()
+	mysql := router.channel("mysql")
+	pg := router.channel("pg")
+
+	router.remove("mysql")
+	in <- sinks.Ack{SourceName: "mysql", Offset: "1"}
+	in <- sinks.Ack{SourceName: "pg", Offset: "2"}
+	if offset := receive(pg); offset != "2" {
+		t.Error(offset)
+	}
+	if len(mysql) != 0 || router.pending() != 0 {
+		t.Error("offset of removed source dispatched")
+	}
+}
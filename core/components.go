@@ -0,0 +1,229 @@
+package core
+
+import (
+	"github.com/juju/errors"
+	"github.com/mitchellh/mapstructure"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Pirionfr/lookatch-agent/utils"
+)
+
+type (
+	// ComponentParameters parameters of tasks adding a source or a sink
+	ComponentParameters struct {
+		Name   string                 `name:"name" description:"Name of the component" required:"true"`
+		Config map[string]interface{} `name:"config" description:"Configuration of the component, same as in configuration file" required:"true"`
+	}
+
+	// NameParameters parameters of tasks removing a source or a sink
+	NameParameters struct {
+		Name string `name:"name" description:"Name of the component" required:"true"`
+	}
+
+	// LinkParameters parameters of tasks linking a source to a sink
+	LinkParameters struct {
+		Source string `name:"source" description:"Name of the source" required:"true"`
+		Sink   string `name:"sink" description:"Name of the sink" required:"true"`
+	}
+)
+
+// getComponentCapabilities return tasks adding, removing and linking sources and sinks
+func getComponentCapabilities() map[string]*utils.TaskDescription {
+	var action = make(map[string]*utils.TaskDescription)
+	action[utils.SourceAdd] = utils.DeclareNewTaskDescription(ComponentParameters{}, "Add and start a source")
+	action[utils.SourceRemove] = utils.DeclareNewTaskDescription(NameParameters{}, "Stop and remove a source")
+	action[utils.SinkAdd] = utils.DeclareNewTaskDescription(ComponentParameters{}, "Add and start a sink")
+	action[utils.SinkRemove] = utils.DeclareNewTaskDescription(NameParameters{}, "Stop and remove a sink")
+	action[utils.SinkLink] = utils.DeclareNewTaskDescription(LinkParameters{}, "Send events of a source to a sink")
+	action[utils.SinkUnlink] = utils.DeclareNewTaskDescription(LinkParameters{}, "Stop sending events of a source to a sink")
+	return action
+}
+
+// runComponentTask add, remove or link sources and sinks from task parameters
+// caller must hold reloadMutex
+func (a *Agent) runComponentTask(task utils.Task) (err error) {
+	switch task.TaskType {
+	case utils.SourceAdd, utils.SinkAdd:
+		params := ComponentParameters{}
+		if err = mapstructure.Decode(task.Parameters, &params); err != nil {
+			return errors.Annotate(err, "invalid parameters")
+		}
+		return a.addComponent(task.TaskType == utils.SourceAdd, params)
+
+	case utils.SourceRemove, utils.SinkRemove:
+		params := NameParameters{}
+		if err = mapstructure.Decode(task.Parameters, &params); err != nil {
+			return errors.Annotate(err, "invalid parameters")
+		}
+		if task.TaskType == utils.SourceRemove {
+			if _, ok := a.getSource(params.Name); !ok {
+				return errors.Errorf("source '%s' not found", params.Name)
+			}
+			return a.mergeComponent("sources", params.Name, map[string]interface{}{"enabled": false})
+		}
+		if _, ok := a.getSink(params.Name); !ok {
+			return errors.Errorf("sink '%s' not found", params.Name)
+		}
+		return a.mergeComponent("sinks", params.Name, map[string]interface{}{"enabled": false})
+
+	case utils.SinkLink, utils.SinkUnlink:
+		params := LinkParameters{}
+		if err = mapstructure.Decode(task.Parameters, &params); err != nil {
+			return errors.Annotate(err, "invalid parameters")
+		}
+		return a.linkSink(task.TaskType == utils.SinkLink, params)
+	}
+	return errors.Errorf("task '%s' not implemented for agent", task.TaskType)
+}
+
+// addComponent create a new source or sink from its configuration
+// capabilities and schema of new component are sent to controller
+func (a *Agent) addComponent(isSource bool, params ComponentParameters) (err error) {
+	if params.Name == "" || params.Config == nil {
+		return errors.New("name and config are required")
+	}
+	kind := "sinks"
+	_, exists := a.getSink(params.Name)
+	if isSource {
+		kind = "sources"
+		_, exists = a.getSource(params.Name)
+	}
+	if exists {
+		return errors.Errorf("%s '%s' already exists", kind, params.Name)
+	}
+
+	conf := make(map[string]interface{}, len(params.Config)+1)
+	for k, v := range params.Config {
+		conf[k] = v
+	}
+	conf["enabled"] = true
+	err = a.mergeComponent(kind, params.Name, conf)
+	if err != nil || a.controller == nil {
+		return
+	}
+
+	if !isSource {
+		aSink, _ := a.getSink(params.Name)
+		return a.controller.SendSinksCapabilities(params.Name, aSink.GetCapabilities())
+	}
+	source, _ := a.getSource(params.Name)
+	err = a.controller.SendSourcesCapabilities(params.Name, source.GetCapabilities())
+	if err != nil {
+		return
+	}
+	return a.controller.SendSchema(params.Name, source.GetSchema())
+}
+
+// linkSink add or remove a sink from sinks linked to a source
+func (a *Agent) linkSink(link bool, params LinkParameters) error {
+	if _, ok := a.getSource(params.Source); !ok {
+		return errors.Errorf("source '%s' not found", params.Source)
+	}
+	if _, ok := a.getSink(params.Sink); !ok {
+		return errors.Errorf("sink '%s' not found", params.Sink)
+	}
+
+	linkedSinks := make([]string, 0)
+	found := false
+	for _, sinkName := range a.config.GetStringSlice("sources." + params.Source + ".linked_sinks") {
+		if sinkName == params.Sink {
+			found = true
+			if !link {
+				continue
+			}
+		}
+		linkedSinks = append(linkedSinks, sinkName)
+	}
+	if link == found {
+		return nil
+	}
+	if link {
+		linkedSinks = append(linkedSinks, params.Sink)
+	}
+	if len(linkedSinks) == 0 {
+		return errors.Errorf("can't unlink last sink of source '%s'", params.Source)
+	}
+	return a.mergeComponent("sources", params.Source, map[string]interface{}{"linked_sinks": linkedSinks})
+}
+
+// mergeComponent merge values into component configuration and apply it
+// values are kept in task configuration, so they are not lost when configuration is read again
+// previous values are restored if configuration can't be applied
+func (a *Agent) mergeComponent(kind string, name string, values map[string]interface{}) error {
+	key := kind + "." + name
+	previous := make(map[string]interface{}, len(values))
+	for k := range values {
+		previous[k] = a.config.Get(key + "." + k)
+	}
+	previousTaskConfig := a.getTaskConfig(kind, name)
+
+	err := a.config.MergeConfigMap(componentConfigMap(kind, name, values))
+	if err != nil {
+		return errors.Annotate(err, "error while merging configuration")
+	}
+	taskConfig := a.getTaskConfig(kind, name)
+	if taskConfig == nil {
+		taskConfig = make(map[string]interface{}, len(values))
+	}
+	for k, v := range values {
+		taskConfig[k] = v
+	}
+	a.setTaskConfig(kind, name, taskConfig)
+
+	err = a.applyConfig()
+	if err != nil {
+		a.setTaskConfig(kind, name, previousTaskConfig)
+		errMerge := a.config.MergeConfigMap(componentConfigMap(kind, name, previous))
+		if errMerge != nil {
+			log.WithError(errMerge).WithField(kind, name).Error("Error while restoring configuration")
+		}
+	}
+	return err
+}
+
+// getTaskConfig return copy of values set by tasks in component configuration, nil if none
+func (a *Agent) getTaskConfig(kind string, name string) map[string]interface{} {
+	values, ok := a.taskConfig[kind][name]
+	if !ok {
+		return nil
+	}
+	taskConfig := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		taskConfig[k] = v
+	}
+	return taskConfig
+}
+
+// setTaskConfig replace values set by tasks in component configuration, nil values remove them
+func (a *Agent) setTaskConfig(kind string, name string, values map[string]interface{}) {
+	if values == nil {
+		delete(a.taskConfig[kind], name)
+		return
+	}
+	if a.taskConfig == nil {
+		a.taskConfig = make(map[string]map[string]map[string]interface{})
+	}
+	if a.taskConfig[kind] == nil {
+		a.taskConfig[kind] = make(map[string]map[string]interface{})
+	}
+	a.taskConfig[kind][name] = values
+}
+
+// mergeTaskConfig merge values set by tasks on top of configuration
+// caller must hold reloadMutex
+func (a *Agent) mergeTaskConfig() error {
+	for kind, components := range a.taskConfig {
+		for name, values := range components {
+			err := a.config.MergeConfigMap(componentConfigMap(kind, name, values))
+			if err != nil {
+				return errors.Annotatef(err, "error while merging configuration of %s '%s'", kind, name)
+			}
+		}
+	}
+	return nil
+}
+
+// componentConfigMap return configuration map holding values of component
+func componentConfigMap(kind string, name string, values map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{kind: map[string]interface{}{name: values}}
+}
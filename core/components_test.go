@@ -0,0 +1,179 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/Pirionfr/lookatch-agent/utils"
+)
+
+func TestGetComponentCapabilities(t *testing.T) {
+	capabilities := getComponentCapabilities()
+	for _, taskType := range []string{utils.SourceAdd, utils.SourceRemove, utils.SinkAdd, utils.SinkRemove, utils.SinkLink, utils.SinkUnlink} {
+		if capabilities[taskType] == nil {
+			t.Errorf("missing capability %s", taskType)
+		}
+	}
+}
+
+func TestRunComponentTaskSink(t *testing.T) {
+	agent := newReloadAgent(t)
+	agent.reloadMutex.Lock()
+	defer agent.reloadMutex.Unlock()
+
+	err := agent.runComponentTask(utils.Task{
+		TaskType: utils.SinkAdd,
+		Parameters: map[string]interface{}{
+			"name":   "other",
+			"config": map[string]interface{}{"type": "Stdout"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := agent.getSink("other"); !ok {
+		t.Error("sink not added")
+	}
+
+	err = agent.runComponentTask(utils.Task{
+		TaskType:   utils.SinkLink,
+		Parameters: map[string]interface{}{"source": "default", "sink": "other"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiplexer, _ := agent.getMultiplexer("default")
+	if len(multiplexer.GetSinks()) != 2 {
+		t.Error("sink not linked")
+	}
+
+	err = agent.runComponentTask(utils.Task{
+		TaskType:   utils.SinkRemove,
+		Parameters: map[string]interface{}{"name": "other"},
+	})
+	if err == nil {
+		t.Error("linked sink removed")
+	}
+
+	err = agent.runComponentTask(utils.Task{
+		TaskType:   utils.SinkUnlink,
+		Parameters: map[string]interface{}{"source": "default", "sink": "other"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = agent.runComponentTask(utils.Task{
+		TaskType:   utils.SinkRemove,
+		Parameters: map[string]interface{}{"name": "other"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := agent.getSink("other"); ok {
+		t.Error("sink not removed")
+	}
+}
+
+func TestRunComponentTaskSource(t *testing.T) {
+	agent := newReloadAgent(t)
+	agent.reloadMutex.Lock()
+	defer agent.reloadMutex.Unlock()
+
+	err := agent.runComponentTask(utils.Task{
+		TaskType: utils.SourceAdd,
+		Parameters: map[string]interface{}{
+			"name":   "other",
+			"config": map[string]interface{}{"type": "Random", "linked_sinks": []string{"default"}, "wait": "1s"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := agent.getSource("other"); !ok {
+		t.Error("source not added")
+	}
+
+	err = agent.runComponentTask(utils.Task{
+		TaskType: utils.SourceAdd,
+		Parameters: map[string]interface{}{
+			"name":   "other",
+			"config": map[string]interface{}{"type": "Random", "linked_sinks": []string{"default"}},
+		},
+	})
+	if err == nil {
+		t.Error("source added twice")
+	}
+
+	err = agent.runComponentTask(utils.Task{
+		TaskType:   utils.SourceRemove,
+		Parameters: map[string]interface{}{"name": "other"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := agent.getSource("other"); ok {
+		t.Error("source not removed")
+	}
+}
+
+func TestRunComponentTaskReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(ReloadJSON), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := viper.New()
+	config.SetConfigFile(path)
+	err = config.ReadInConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := newAgent(config, make(chan error))
+	agent.status = AgentStatusOnline
+	err = agent.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		agent.Stop(ctx)
+	})
+
+	agent.reloadMutex.Lock()
+	err = agent.runComponentTask(utils.Task{
+		TaskType: utils.SinkAdd,
+		Parameters: map[string]interface{}{
+			"name":   "other",
+			"config": map[string]interface{}{"type": "Stdout"},
+		},
+	})
+	if err == nil {
+		err = agent.runComponentTask(utils.Task{
+			TaskType:   utils.SinkLink,
+			Parameters: map[string]interface{}{"source": "default", "sink": "other"},
+		})
+	}
+	agent.reloadMutex.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink, _ := agent.getSink("other")
+
+	err = agent.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := agent.getSink("other"); !ok || s != sink {
+		t.Error("sink added by task removed by reload")
+	}
+	multiplexer, _ := agent.getMultiplexer("default")
+	if len(multiplexer.GetSinks()) != 2 {
+		t.Error("sink linked by task unlinked by reload")
+	}
+}
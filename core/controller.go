@@ -22,11 +22,13 @@ import (
 const (
 	agentIDParamPath       = "{agentId}"
 	sourceIDParamPath      = "{sourceId}"
+	sinkIDParamPath        = "{sinkId}"
 	taskIDParamPath        = "{taskId}"
 	configurationPath      = "/collectors/" + agentIDParamPath + "/controller/configuration"
 	metaPath               = "/collectors/" + agentIDParamPath + "/controller/meta"
 	capabilitiesPath       = "/collectors/" + agentIDParamPath + "/controller/capabilities"
 	sourceCapabilitiesPath = "/collectors/" + agentIDParamPath + "/controller/sources/" + sourceIDParamPath + "/capabilities"
+	sinkCapabilitiesPath   = "/collectors/" + agentIDParamPath + "/controller/sinks/" + sinkIDParamPath + "/capabilities"
 	taskPath               = "/collectors/" + agentIDParamPath + "/controller/tasks/" + taskIDParamPath
 	tasksPath              = "/collectors/" + agentIDParamPath + "/controller/tasks"
 	schemaPath             = "/collectors/" + agentIDParamPath + "/controller/sources/" + sourceIDParamPath + "/schema"
@@ -176,6 +178,18 @@ func (c *Controller) SendSourcesCapabilities(sourceName string, tasks map[string
 	return
 }
 
+// SendSinksCapabilities send the currently supported capabilities of the configured sink to the API
+func (c *Controller) SendSinksCapabilities(sinkName string, tasks map[string]*utils.TaskDescription) (err error) {
+	body, _ := json.Marshal(tasks)
+
+	path := strings.Replace(sinkCapabilitiesPath, sinkIDParamPath, sinkName, 1)
+	_, err = c.call(http.MethodPost, path, nil, nil, body)
+	if err != nil {
+		err = errors.Annotate(err, "error while sending sinks capabilities")
+	}
+	return
+}
+
 // GetTasks get the list of tasks the collector needs to execute from the API
 // if limit is set to -1 return all task else return limit set
 func (c *Controller) GetTasks(limit int) (task []utils.Task, err error) {
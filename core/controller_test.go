@@ -325,6 +325,65 @@ func TestSendSourcesCapabilities(t *testing.T) {
 
 }
 
+func TestSendSinksCapabilities(t *testing.T) {
+
+	paramDesc := utils.ParametersDescription{
+		Description: "parameter",
+		Name:        "aParam",
+		Type:        "string",
+		Required:    false,
+	}
+
+	capabilities := make(map[string]*utils.TaskDescription)
+	capabilities["aTask"] = &utils.TaskDescription{
+		Description: "test task",
+		Parameters: []*utils.ParametersDescription{
+			&paramDesc,
+		},
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get(authHeader) != "Bearer "+token {
+			t.Fail()
+		}
+		if !strings.Contains(r.URL.EscapedPath(), UUID) {
+			t.Fail()
+		}
+		if !strings.Contains(r.URL.EscapedPath(), "/sinks/default/") {
+			t.Fail()
+		}
+
+		returnBody, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fail()
+		}
+		res := make(map[string]*utils.TaskDescription)
+		if err := json.Unmarshal(returnBody, &res); err != nil {
+			t.Fail()
+		}
+
+		if ok := reflect.DeepEqual(res, capabilities); !ok {
+			t.Fail()
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusNoContent)
+
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+
+	vCtrl.Set("controller.base_url", server.URL)
+	auth.token = token
+	ctrl := NewControllerClient(vCtrl.Sub("controller"), auth)
+
+	if ctrl.SendSinksCapabilities("default", capabilities) != nil {
+		t.Fail()
+	}
+
+}
+
 func TestGetOneTasks(t *testing.T) {
 
 	handler := func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/Pirionfr/lookatch-agent/utils"
+)
+
+type (
+	// CommitCoordinator keep track of offsets sent by a source to its linked sinks
+	// an offset is released to the source only when every sink has acknowledged it
+	CommitCoordinator struct {
+		sync.RWMutex
+		pending  []*pendingOffset
+		acked    map[string]string
+		released string
+		// releasable is notified when leading offsets wait for no sink
+		releasable chan struct{}
+	}
+
+	// pendingOffset an offset waiting for sinks acknowledgement
+	pendingOffset struct {
+		offset  string
+		waiting map[string]bool
+	}
+)
+
+// NewCommitCoordinator create new CommitCoordinator for the given sinks
+func NewCommitCoordinator(sinks []string) *CommitCoordinator {
+	c := &CommitCoordinator{
+		pending:    make([]*pendingOffset, 0),
+		acked:      make(map[string]string),
+		releasable: make(chan struct{}, 1),
+	}
+	for _, sinkName := range sinks {
+		c.acked[sinkName] = ""
+	}
+	return c
+}
+
+// Track register an offset sent to the given sinks
+// consecutive events sharing the same offset are merged
+// an offset sent to no sink is releasable as soon as previous offsets are released
+func (c *CommitCoordinator) Track(offset string, sinks []string) {
+	c.Lock()
+	defer c.Unlock()
+	defer c.notifyReleasable()
+
+	if n := len(c.pending); n > 0 && c.pending[n-1].offset == offset {
+		for _, sinkName := range sinks {
+			c.pending[n-1].waiting[sinkName] = true
+		}
+		return
+	}
+
+	waiting := make(map[string]bool, len(sinks))
+	for _, sinkName := range sinks {
+		waiting[sinkName] = true
+	}
+	c.pending = append(c.pending, &pendingOffset{
+		offset:  offset,
+		waiting: waiting,
+	})
+}
+
+// notifyReleasable notify, without blocking, that leading offsets wait for no sink
+func (c *CommitCoordinator) notifyReleasable() {
+	if len(c.pending) == 0 || len(c.pending[0].waiting) > 0 {
+		return
+	}
+	select {
+	case c.releasable <- struct{}{}:
+	default:
+	}
+}
+
+// Releasable return channel notified when leading offsets can be released without waiting for an ack
+func (c *CommitCoordinator) Releasable() <-chan struct{} {
+	return c.releasable
+}
+
+// Release remove leading offsets waiting for no sink
+// return the offset that can be committed and true if a new offset is released
+func (c *CommitCoordinator) Release() (string, bool) {
+	c.Lock()
+	defer c.Unlock()
+	return c.release()
+}
+
+// Ack acknowledge offset for a sink
+// a sink acknowledging an offset acknowledges all previous offsets sent to it
+// return the offset that can be committed and true if a new offset is released
+func (c *CommitCoordinator) Ack(sinkName string, offset string) (string, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	index := c.search(offset)
+	if index == -1 {
+		return "", false
+	}
+	c.acked[sinkName] = offset
+
+	for i := 0; i <= index; i++ {
+		delete(c.pending[i].waiting, sinkName)
+	}
+
+	return c.release()
+}
+
+// search return position of offset in pending offsets, -1 otherwise
+func (c *CommitCoordinator) search(offset string) int {
+	for index, p := range c.pending {
+		if p.offset == offset {
+			return index
+		}
+	}
+	return -1
+}
+
+// release remove all leading offsets acknowledged by every sink
+// return the last removed offset
+func (c *CommitCoordinator) release() (string, bool) {
+	n := 0
+	for n < len(c.pending) && len(c.pending[n].waiting) == 0 {
+		n++
+	}
+	if n == 0 {
+		return "", false
+	}
+	c.released = c.pending[n-1].offset
+	c.pending = c.pending[n:]
+	return c.released, true
+}
+
+// AddSink register a new sink, only offsets tracked from now will wait for it
+func (c *CommitCoordinator) AddSink(sinkName string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.acked[sinkName]; !ok {
+		c.acked[sinkName] = ""
+	}
+}
+
+// RemoveSink unregister a sink, pending offsets no longer wait for it
+// return the offset that can be committed and true if a new offset is released
+func (c *CommitCoordinator) RemoveSink(sinkName string) (string, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.acked, sinkName)
+	for _, p := range c.pending {
+		delete(p.waiting, sinkName)
+	}
+	return c.release()
+}
+
+// Pending return number of offsets waiting for sinks acknowledgement
+func (c *CommitCoordinator) Pending() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.pending)
+}
+
+// GetMeta returns acknowledged offset of each sink and the last released offset
+func (c *CommitCoordinator) GetMeta() map[string]utils.Meta {
+	c.RLock()
+	defer c.RUnlock()
+
+	acked := make(map[string]string, len(c.acked))
+	for sinkName, offset := range c.acked {
+		acked[sinkName] = offset
+	}
+
+	meta := make(map[string]utils.Meta)
+	meta["sinks_acked_offset"] = utils.NewMeta("sinks_acked_offset", acked)
+	meta["released_offset"] = utils.NewMeta("released_offset", c.released)
+	meta["pending_offsets"] = utils.NewMeta("pending_offsets", len(c.pending))
+	return meta
+}
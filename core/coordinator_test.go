@@ -0,0 +1,128 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestCoordinatorAckSingleSink(t *testing.T) {
+	c := NewCommitCoordinator([]string{"default"})
+	c.Track("1", []string{"default"})
+	c.Track("2", []string{"default"})
+
+	released, ok := c.Ack("default", "2")
+	if !ok || released != "2" {
+		t.Fail()
+	}
+}
+
+func TestCoordinatorAckLowestOffset(t *testing.T) {
+	c := NewCommitCoordinator([]string{"fast", "slow"})
+	c.Track("1", []string{"fast", "slow"})
+	c.Track("2", []string{"fast", "slow"})
+	c.Track("3", []string{"fast", "slow"})
+
+	if _, ok := c.Ack("fast", "3"); ok {
+		t.Fail()
+	}
+
+	released, ok := c.Ack("slow", "2")
+	if !ok || released != "2" {
+		t.Fail()
+	}
+
+	released, ok = c.Ack("slow", "3")
+	if !ok || released != "3" {
+		t.Fail()
+	}
+}
+
+func TestCoordinatorMergeOffset(t *testing.T) {
+	c := NewCommitCoordinator([]string{"default"})
+	c.Track("1", []string{"default"})
+	c.Track("1", []string{"default"})
+
+	if len(c.pending) != 1 {
+		t.Fail()
+	}
+}
+
+func TestCoordinatorAckUnknownOffset(t *testing.T) {
+	c := NewCommitCoordinator([]string{"default"})
+	c.Track("1", []string{"default"})
+
+	if _, ok := c.Ack("default", "42"); ok {
+		t.Fail()
+	}
+}
+
+func TestCoordinatorGetMeta(t *testing.T) {
+	c := NewCommitCoordinator([]string{"fast", "slow"})
+	c.Track("1", []string{"fast", "slow"})
+	c.Ack("fast", "1")
+
+	meta := c.GetMeta()
+	acked := meta["sinks_acked_offset"].Value.(map[string]string)
+	if acked["fast"] != "1" || acked["slow"] != "" {
+		t.Fail()
+	}
+	if meta["pending_offsets"].Value.(int) != 1 {
+		t.Fail()
+	}
+}
+
+func TestCoordinatorRemoveSink(t *testing.T) {
+	c := NewCommitCoordinator([]string{"fast", "slow"})
+	c.Track("1", []string{"fast", "slow"})
+
+	if _, ok := c.Ack("fast", "1"); ok {
+		t.Fail()
+	}
+
+	released, ok := c.RemoveSink("slow")
+	if !ok || released != "1" {
+		t.Fail()
+	}
+	if _, ok := c.acked["slow"]; ok {
+		t.Fail()
+	}
+}
+
+func TestCoordinatorAddSink(t *testing.T) {
+	c := NewCommitCoordinator([]string{"default"})
+	c.AddSink("new")
+	c.Track("1", []string{"default", "new"})
+
+	if _, ok := c.Ack("default", "1"); ok {
+		t.Fail()
+	}
+	released, ok := c.Ack("new", "1")
+	if !ok || released != "1" {
+		t.Fail()
+	}
+}
+
+func TestCoordinatorReleaseNoSink(t *testing.T) {
+	c := NewCommitCoordinator([]string{"default"})
+	c.Track("1", []string{"default"})
+	c.Track("2", nil)
+	if len(c.Releasable()) != 0 {
+		t.Error("offset waiting for sink releasable")
+	}
+
+	released, ok := c.Ack("default", "1")
+	if !ok || released != "2" {
+		t.Error(released)
+	}
+
+	// replayed events dropped by deduplication are released without waiting for new traffic
+	c.Track("3", nil)
+	select {
+	case <-c.Releasable():
+	default:
+		t.Fatal("offset sent to no sink not releasable")
+	}
+	released, ok = c.Release()
+	if !ok || released != "3" {
+		t.Error(released)
+	}
+}
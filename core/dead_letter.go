@@ -0,0 +1,87 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/juju/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Pirionfr/lookatch-agent/sinks"
+)
+
+// checkDeadLetters validate dead letter sinks of enabled sinks
+// a dead letter sink must exist and chains of dead letter sinks must not loop
+func (a *Agent) checkDeadLetters(sinkNames map[string]bool) error {
+	for sinkName := range sinkNames {
+		visited := map[string]bool{sinkName: true}
+		current := sinkName
+		for {
+			target := a.config.GetString("sinks." + current + ".dead_letter")
+			if target == "" {
+				break
+			}
+			if !sinkNames[target] {
+				return errors.Errorf("dead letter sink '%s' not found for '%s'", target, current)
+			}
+			if visited[target] {
+				return errors.Errorf("dead letter sinks of '%s' loop on '%s'", sinkName, target)
+			}
+			visited[target] = true
+			current = target
+		}
+	}
+	return nil
+}
+
+// linkDeadLetters send events rejected by each sink to its dead letter sink
+func (a *Agent) linkDeadLetters(sinksMap map[string]sinks.SinkI) {
+	for sinkName, aSink := range sinksMap {
+		target := a.config.GetString("sinks." + sinkName + ".dead_letter")
+		if target == "" {
+			aSink.SetDeadLetter("", nil)
+			continue
+		}
+		targetSink, ok := sinksMap[target]
+		if !ok {
+			log.WithField("sink", sinkName).WithField("deadLetter", target).Warn("Dead letter sink not found")
+			aSink.SetDeadLetter("", nil)
+			continue
+		}
+		aSink.SetDeadLetter(target, targetSink.GetInputChan())
+	}
+}
+
+// sinksStopOrder return names of sinks ordered so that each sink is stopped before its dead letter sink
+// events rejected by a sink while it flushes can still be sent to its dead letter sink
+func (a *Agent) sinksStopOrder(sinksMap map[string]sinks.SinkI) []string {
+	remaining := make(map[string]bool, len(sinksMap))
+	for sinkName := range sinksMap {
+		remaining[sinkName] = true
+	}
+
+	order := make([]string, 0, len(sinksMap))
+	for len(remaining) > 0 {
+		targets := make(map[string]bool)
+		for sinkName := range remaining {
+			targets[a.config.GetString("sinks."+sinkName+".dead_letter")] = true
+		}
+		next := make([]string, 0, len(remaining))
+		for sinkName := range remaining {
+			if !targets[sinkName] {
+				next = append(next, sinkName)
+			}
+		}
+		// dead letter loops are rejected by checkDeadLetters, stop remaining sinks anyway
+		if len(next) == 0 {
+			for sinkName := range remaining {
+				next = append(next, sinkName)
+			}
+		}
+		sort.Strings(next)
+		for _, sinkName := range next {
+			delete(remaining, sinkName)
+		}
+		order = append(order, next...)
+	}
+	return order
+}
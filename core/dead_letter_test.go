@@ -0,0 +1,87 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/Pirionfr/lookatch-agent/sinks"
+)
+
+const (
+	DeadLetterJSON     = `{"sinks":{"default":{"enabled":true,"type":"Stdout","dead_letter":"errors"},"errors":{"enabled":true,"type":"Stdout"}},"sources":{"default":{"enabled":true,"type":"Random","linked_sinks":["default"],"wait":"1s"}}}`
+	DeadLetterLoopJSON = `{"sinks":{"default":{"enabled":true,"type":"Stdout","dead_letter":"errors"},"errors":{"enabled":true,"type":"Stdout","dead_letter":"default"}},"sources":{"default":{"enabled":true,"type":"Random","linked_sinks":["default"],"wait":"1s"}}}`
+)
+
+func TestCheckDeadLetters(t *testing.T) {
+	config := viper.New()
+	config.SetConfigType("json")
+	err := config.ReadConfig(bytes.NewBufferString(DeadLetterLoopJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := newAgent(config, make(chan error))
+
+	if agent.checkDeadLetters(map[string]bool{"default": true, "errors": true}) == nil {
+		t.Error("loop not detected")
+	}
+	if agent.checkDeadLetters(map[string]bool{"default": true}) == nil {
+		t.Error("missing sink not detected")
+	}
+
+	config.Set("sinks.errors.dead_letter", "errors")
+	if agent.checkDeadLetters(map[string]bool{"errors": true}) == nil {
+		t.Error("sink used as its own dead letter")
+	}
+}
+
+func TestReloadDeadLetter(t *testing.T) {
+	agent := newReloadAgent(t)
+	sink, _ := agent.getSink("default")
+
+	err := reload(t, agent, DeadLetterJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := agent.getSink("default"); s != sink {
+		t.Error("sink restarted")
+	}
+	if _, ok := agent.getSink("errors"); !ok {
+		t.Fatal("dead letter sink not created")
+	}
+	if sink.(*sinks.Stdout).DeadLetter.GetTarget() != "errors" {
+		t.Error("dead letter sink not linked")
+	}
+
+	err = reload(t, agent, DeadLetterLoopJSON)
+	if err == nil {
+		t.Error("dead letter loop accepted")
+	}
+
+	err = reload(t, agent, ReloadJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sink.(*sinks.Stdout).DeadLetter.GetTarget() != "" {
+		t.Error("dead letter sink not unlinked")
+	}
+}
+
+func TestSinksStopOrder(t *testing.T) {
+	config := viper.New()
+	config.Set("sinks.default.dead_letter", "errors")
+	config.Set("sinks.errors.dead_letter", "backup")
+	agent := newAgent(config, make(chan error))
+
+	order := agent.sinksStopOrder(map[string]sinks.SinkI{
+		"backup":  nil,
+		"errors":  nil,
+		"default": nil,
+		"other":   nil,
+	})
+	if strings.Join(order, ",") != "default,other,errors,backup" {
+		t.Error(order)
+	}
+}
@@ -0,0 +1,303 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+// DefaultDedupPath default directory of deduplication windows
+const DefaultDedupPath = "dedup"
+
+// DefaultDedupMaxSize default number of keys kept in a deduplication window
+const DefaultDedupMaxSize = 100000
+
+// dedupFile name of the file holding the window of a source
+const dedupFile = "window.log"
+
+type (
+	// DedupConfig representation of the deduplication configuration of a source
+	// keys are kept for Window if set and at most MaxSize keys are kept
+	DedupConfig struct {
+		Enabled bool   `json:"enabled"`
+		Window  string `json:"window"`
+		MaxSize int    `json:"max_size" mapstructure:"max_size"`
+		Path    string `json:"path"`
+	}
+
+	// deduplicator drop events already sent, keyed on source offset and primary key values
+	// keys are written to disk once their offset is committed so events replayed after a restart are dropped
+	deduplicator struct {
+		sync.Mutex
+		window  time.Duration
+		maxSize int
+		path    string
+		file    *os.File
+		keys    map[string]int64
+		order   []dedupEntry
+		pending []*pendingKeys
+		sent    map[string]bool
+		counts  map[string]int
+		lines   int
+		dropped uint64
+		now     func() time.Time
+	}
+
+	// dedupEntry committed key and the time it was committed
+	dedupEntry struct {
+		key       string
+		timestamp int64
+	}
+
+	// pendingKeys keys of events sharing an offset, waiting for the offset to be committed
+	pendingKeys struct {
+		offset string
+		keys   []string
+	}
+)
+
+// newDeduplicator open deduplication window of config, keys committed by a previous run are loaded
+func newDeduplicator(config DedupConfig) (*deduplicator, error) {
+	d := &deduplicator{
+		maxSize: config.MaxSize,
+		path:    filepath.Join(config.Path, dedupFile),
+		keys:    make(map[string]int64),
+		sent:    make(map[string]bool),
+		counts:  make(map[string]int),
+		now:     time.Now,
+	}
+	if d.maxSize <= 0 {
+		d.maxSize = DefaultDedupMaxSize
+	}
+	if config.Window != "" {
+		window, err := time.ParseDuration(config.Window)
+		if err != nil {
+			return nil, errors.Annotate(err, "invalid deduplication window")
+		}
+		d.window = window
+	}
+
+	err := os.MkdirAll(config.Path, 0750)
+	if err != nil {
+		return nil, errors.Annotate(err, "error while creating deduplication directory")
+	}
+	err = d.load()
+	if err != nil {
+		return nil, err
+	}
+	d.evict()
+	err = d.compact()
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// load read keys of window file, one timestamp and key per line
+func (d *deduplicator) load() error {
+	file, err := os.Open(d.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Annotate(err, "error while opening deduplication window")
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		timestamp, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		key, err := strconv.Unquote(fields[1])
+		if err != nil {
+			continue
+		}
+		d.add(key, timestamp)
+	}
+	return errors.Annotate(scanner.Err(), "error while reading deduplication window")
+}
+
+// compact rewrite window file with keys of window only
+func (d *deduplicator) compact() error {
+	if d.file != nil {
+		d.file.Close()
+	}
+	tmp := d.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return errors.Annotate(err, "error while creating deduplication window")
+	}
+	writer := bufio.NewWriter(file)
+	for _, entry := range d.order {
+		if d.keys[entry.key] == entry.timestamp {
+			fmt.Fprintf(writer, "%d %s\n", entry.timestamp, strconv.Quote(entry.key))
+		}
+	}
+	err = writer.Flush()
+	file.Close()
+	if err != nil {
+		return errors.Annotate(err, "error while writing deduplication window")
+	}
+	err = os.Rename(tmp, d.path)
+	if err != nil {
+		return errors.Annotate(err, "error while writing deduplication window")
+	}
+
+	d.file, err = os.OpenFile(d.path, os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return errors.Annotate(err, "error while opening deduplication window")
+	}
+	d.lines = len(d.keys)
+	return nil
+}
+
+// seen return true if event was already sent, otherwise its key waits for its offset to be committed
+// events without offset are never dropped
+func (d *deduplicator) seen(event events.LookatchEvent) bool {
+	offset := event.GetOffset()
+	if offset == nil || offset.Source == "" {
+		return false
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	var current *pendingKeys
+	if n := len(d.pending); n > 0 && d.pending[n-1].offset == offset.Source {
+		current = d.pending[n-1]
+	} else {
+		current = &pendingKeys{offset: offset.Source}
+		d.pending = append(d.pending, current)
+		d.counts = make(map[string]int)
+	}
+
+	// events sharing offset and primary key values are told apart by their rank
+	key := dedupKey(offset.Source, event)
+	d.counts[key]++
+	key += "\x00" + strconv.Itoa(d.counts[key])
+
+	if _, ok := d.keys[key]; ok || d.sent[key] {
+		d.dropped++
+		return true
+	}
+	current.keys = append(current.keys, key)
+	d.sent[key] = true
+	return false
+}
+
+// dedupKey return key of event from its offset, table and primary key values
+func dedupKey(offset string, event events.LookatchEvent) string {
+	sqlEvent, ok := event.Payload.(events.SQLEvent)
+	if !ok {
+		return offset
+	}
+	parts := []string{offset, sqlEvent.Database, sqlEvent.Schema, sqlEvent.Table}
+	if sqlEvent.PrimaryKey != "" {
+		statement := sqlEvent.Statement
+		if len(statement) == 0 {
+			statement = sqlEvent.OldStatement
+		}
+		for _, column := range strings.Split(sqlEvent.PrimaryKey, ",") {
+			parts = append(parts, fmt.Sprint(statement[column]))
+		}
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// commit write keys of offsets up to offset to window file
+func (d *deduplicator) commit(offset string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	index := -1
+	for i, p := range d.pending {
+		if p.offset == offset {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return nil
+	}
+
+	timestamp := d.now().UnixNano()
+	var lines strings.Builder
+	for _, p := range d.pending[:index+1] {
+		for _, key := range p.keys {
+			delete(d.sent, key)
+			d.add(key, timestamp)
+			fmt.Fprintf(&lines, "%d %s\n", timestamp, strconv.Quote(key))
+			d.lines++
+		}
+	}
+	d.pending = d.pending[index+1:]
+	d.evict()
+
+	if d.file == nil {
+		return nil
+	}
+	if _, err := d.file.WriteString(lines.String()); err != nil {
+		return errors.Annotate(err, "error while writing deduplication window")
+	}
+	if d.lines > 2*len(d.keys)+1024 {
+		return d.compact()
+	}
+	return nil
+}
+
+// add insert key in window
+func (d *deduplicator) add(key string, timestamp int64) {
+	d.keys[key] = timestamp
+	d.order = append(d.order, dedupEntry{key: key, timestamp: timestamp})
+}
+
+// evict remove oldest keys out of window
+func (d *deduplicator) evict() {
+	limit := int64(0)
+	if d.window > 0 {
+		limit = d.now().Add(-d.window).UnixNano()
+	}
+	n := 0
+	for n < len(d.order) && (len(d.keys) > d.maxSize || d.order[n].timestamp < limit) {
+		if entry := d.order[n]; d.keys[entry.key] == entry.timestamp {
+			delete(d.keys, entry.key)
+		}
+		n++
+	}
+	d.order = d.order[n:]
+}
+
+// counters return number of keys in window and number of dropped events
+func (d *deduplicator) counters() (int, uint64) {
+	d.Lock()
+	defer d.Unlock()
+	return len(d.keys), d.dropped
+}
+
+// close close window file, keys are kept on disk
+func (d *deduplicator) close() error {
+	d.Lock()
+	defer d.Unlock()
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
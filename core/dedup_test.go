@@ -0,0 +1,97 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+func dedupEvent(offset string, id int) events.LookatchEvent {
+	return events.LookatchEvent{
+		Payload: events.SQLEvent{
+			Database:   "shop",
+			Table:      "orders",
+			PrimaryKey: "id",
+			Statement:  map[string]interface{}{"id": id},
+			Offset:     &events.Offset{Source: offset},
+		},
+	}
+}
+
+func TestDeduplicatorReplay(t *testing.T) {
+	config := DedupConfig{Enabled: true, Path: t.TempDir()}
+	dedup, err := newDeduplicator(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dedup.seen(dedupEvent("1", 1)) || dedup.seen(dedupEvent("1", 2)) || dedup.seen(dedupEvent("2", 1)) {
+		t.Error("first events must not be dropped")
+	}
+	if !dedup.seen(dedupEvent("1", 1)) {
+		t.Error("event sent twice must be dropped")
+	}
+	if err = dedup.commit("1"); err != nil {
+		t.Fatal(err)
+	}
+	dedup.close()
+
+	// only keys of committed offsets survive a restart
+	dedup, err = newDeduplicator(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dedup.close()
+	if !dedup.seen(dedupEvent("1", 1)) || !dedup.seen(dedupEvent("1", 2)) {
+		t.Error("committed events must be dropped after restart")
+	}
+	if dedup.seen(dedupEvent("2", 1)) {
+		t.Error("uncommitted event must be sent after restart")
+	}
+	if size, dropped := dedup.counters(); size != 2 || dropped != 2 {
+		t.Errorf("unexpected counters %d %d", size, dropped)
+	}
+}
+
+func TestDeduplicatorSameKey(t *testing.T) {
+	dedup, err := newDeduplicator(DedupConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dedup.close()
+	// a row updated twice in a transaction shares offset and primary key
+	if dedup.seen(dedupEvent("1", 1)) || dedup.seen(dedupEvent("1", 1)) {
+		t.Error("events of a same offset must not be dropped")
+	}
+}
+
+func TestDeduplicatorBounds(t *testing.T) {
+	now := time.Now()
+	dedup, err := newDeduplicator(DedupConfig{Path: t.TempDir(), MaxSize: 2, Window: "1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dedup.close()
+	dedup.now = func() time.Time { return now }
+
+	for i := 1; i <= 3; i++ {
+		dedup.seen(dedupEvent("1", i))
+	}
+	dedup.commit("1")
+	if size, _ := dedup.counters(); size != 2 {
+		t.Errorf("window expected to be bounded to 2 keys, got %d", size)
+	}
+
+	dedup.now = func() time.Time { return now.Add(2 * time.Minute) }
+	dedup.seen(dedupEvent("2", 1))
+	dedup.commit("2")
+	if size, _ := dedup.counters(); size != 1 {
+		t.Errorf("expired keys expected to be removed, got %d", size)
+	}
+}
+
+func TestDeduplicatorInvalidWindow(t *testing.T) {
+	if _, err := newDeduplicator(DedupConfig{Path: t.TempDir(), Window: "forever"}); err == nil {
+		t.Fail()
+	}
+}
@@ -1,32 +1,221 @@
 package core
 
-// DeMultiplexer is used to send offset from sink to source
-type DeMultiplexer struct {
-	ins []chan interface{}
-	out chan interface{}
-}
+import (
+	"context"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	// DeMultiplexer is used to send offset from sink to source
+	// offsets are only sent once acknowledged by all sinks
+	DeMultiplexer struct {
+		sync.RWMutex
+		ins         map[string]chan interface{}
+		stops       map[string]chan struct{}
+		out         chan interface{}
+		acks        chan *sinkAck
+		coordinator *CommitCoordinator
+		dedup       *deduplicator
+		done        chan struct{}
+	}
+
+	// sinkAck offset committed by a sink
+	// removed is set when sink is unlinked from source
+	sinkAck struct {
+		sinkName string
+		offset   interface{}
+		removed  bool
+	}
+)
 
 // NewDemultiplexer create new DeMultiplexer
 // return an initialized  DeMultiplexer object
-func NewDemultiplexer(ins []chan interface{}, out chan interface{}) (demux *DeMultiplexer) {
+func NewDemultiplexer(ins map[string]chan interface{}, out chan interface{}) (demux *DeMultiplexer) {
 	demux = &DeMultiplexer{
-		ins: ins,
-		out: out,
+		ins:         make(map[string]chan interface{}),
+		stops:       make(map[string]chan struct{}),
+		out:         out,
+		acks:        make(chan *sinkAck, len(ins)+1),
+		coordinator: NewCommitCoordinator(sinkNames(ins)),
+		done:        make(chan struct{}),
+	}
+	for sinkName, c := range ins {
+		demux.listen(sinkName, c)
 	}
 	demux.consumer()
 	return
 }
 
+// listen start an output goroutine forwarding offsets committed by a sink
+func (d *DeMultiplexer) listen(sinkName string, c chan interface{}) {
+	stop := make(chan struct{})
+	d.ins[sinkName] = c
+	d.stops[sinkName] = stop
+
+	go func() {
+		for {
+			select {
+			case <-d.done:
+				return
+			case <-stop:
+				return
+			case n := <-c:
+				select {
+				case d.acks <- &sinkAck{sinkName: sinkName, offset: n}:
+				case <-d.done:
+					return
+				}
+			}
+		}
+	}()
+}
+
 // consumer consume event from sinks and send it to source
 func (d *DeMultiplexer) consumer() {
+	// acks are handled by a single goroutine to keep released offsets ordered
+	go func() {
+		for {
+			var ack *sinkAck
+			select {
+			case <-d.done:
+				return
+			case <-d.coordinator.Releasable():
+				if released, ok := d.coordinator.Release(); ok {
+					d.release(released)
+				}
+				continue
+			case ack = <-d.acks:
+			}
+			if ack.removed {
+				if released, ok := d.coordinator.RemoveSink(ack.sinkName); ok {
+					d.release(released)
+				}
+				continue
+			}
+			offset, ok := ack.offset.(string)
+			if !ok {
+				d.send(ack.offset)
+				continue
+			}
+			if released, ok := d.coordinator.Ack(ack.sinkName, offset); ok {
+				d.release(released)
+			}
+		}
+	}()
+}
 
-	// Start an output goroutine for each input channel in cs.  output
-	output := func(c <-chan interface{}) {
-		for n := range c {
-			d.out <- n
+// release commit keys of deduplication window up to offset then send offset to source
+func (d *DeMultiplexer) release(offset string) {
+	d.RLock()
+	dedup := d.dedup
+	d.RUnlock()
+	if dedup != nil {
+		if err := dedup.commit(offset); err != nil {
+			log.WithError(err).Error("Error while committing deduplication window")
 		}
 	}
-	for _, c := range d.ins {
-		go output(c)
+	d.send(offset)
+}
+
+// SetDeduplicator commit keys of deduplication window when offsets are released
+func (d *DeMultiplexer) SetDeduplicator(dedup *deduplicator) {
+	d.Lock()
+	defer d.Unlock()
+	d.dedup = dedup
+}
+
+// send offset to source unless DeMultiplexer is stopped
+func (d *DeMultiplexer) send(offset interface{}) {
+	select {
+	case d.out <- offset:
+	case <-d.done:
+	}
+}
+
+// AddSink forward offsets committed by sink to source
+// commit channel is replaced if sink is already linked
+func (d *DeMultiplexer) AddSink(sinkName string, c chan interface{}) {
+	d.Lock()
+	defer d.Unlock()
+
+	if stop, ok := d.stops[sinkName]; ok {
+		if d.ins[sinkName] == c {
+			return
+		}
+		close(stop)
+	} else {
+		d.coordinator.AddSink(sinkName)
+	}
+	d.listen(sinkName, c)
+}
+
+// RemoveSink stop forwarding offsets committed by sink
+// pending offsets no longer wait for this sink
+func (d *DeMultiplexer) RemoveSink(sinkName string) {
+	d.Lock()
+	stop, ok := d.stops[sinkName]
+	if ok {
+		close(stop)
+		delete(d.stops, sinkName)
+		delete(d.ins, sinkName)
+	}
+	d.Unlock()
+
+	if !ok {
+		return
+	}
+	select {
+	case d.acks <- &sinkAck{sinkName: sinkName, removed: true}:
+	case <-d.done:
+	}
+}
+
+// Stop stop sending offsets from sinks to source
+// deduplication window is closed, its committed keys are kept on disk
+func (d *DeMultiplexer) Stop() {
+	select {
+	case <-d.done:
+	default:
+		close(d.done)
+	}
+	d.RLock()
+	defer d.RUnlock()
+	if d.dedup != nil {
+		if err := d.dedup.close(); err != nil {
+			log.WithError(err).Error("Error while closing deduplication window")
+		}
+	}
+}
+
+// Drain wait until every offset sent by source is acknowledged by its sinks
+// return an error if context is done before
+func (d *DeMultiplexer) Drain(ctx context.Context) error {
+	return waitUntil(ctx, func() bool {
+		d.RLock()
+		defer d.RUnlock()
+		for _, c := range d.ins {
+			if len(c) > 0 {
+				return false
+			}
+		}
+		return len(d.acks) == 0 && d.coordinator.Pending() == 0
+	})
+}
+
+// GetCoordinator return the commit coordinator of the source
+func (d *DeMultiplexer) GetCoordinator() *CommitCoordinator {
+	return d.coordinator
+}
+
+// sinkNames return sorted sink names
+func sinkNames(ins map[string]chan interface{}) []string {
+	names := make([]string, 0, len(ins))
+	for sinkName := range ins {
+		names = append(names, sinkName)
 	}
+	sort.Strings(names)
+	return names
 }
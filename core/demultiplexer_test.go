@@ -1,20 +1,200 @@
 package core
 
 import (
+	"context"
 	"reflect"
 	"testing"
+	"time"
 )
 
 var (
-	commitIn  []chan interface{}
+	commitIn  map[string]chan interface{}
 	commitOut chan interface{}
 )
 
 func TestNewDeMultiplexer(t *testing.T) {
 	commitOut = make(chan interface{}, 1)
-	commitIn = append(commitIn, make(chan interface{}, 1))
+	commitIn = map[string]chan interface{}{
+		"default": make(chan interface{}, 1),
+	}
 	multiplexer := NewDemultiplexer(commitIn, commitOut)
 	if reflect.TypeOf(multiplexer).String() != "*core.DeMultiplexer" {
 		t.Error("mistmatch")
 	}
 }
+
+func TestDeMultiplexerWaitAllSinks(t *testing.T) {
+	out := make(chan interface{}, 10)
+	ins := map[string]chan interface{}{
+		"fast": make(chan interface{}, 10),
+		"slow": make(chan interface{}, 10),
+	}
+	demux := NewDemultiplexer(ins, out)
+	demux.GetCoordinator().Track("1", []string{"fast", "slow"})
+	demux.GetCoordinator().Track("2", []string{"fast", "slow"})
+
+	ins["fast"] <- "2"
+	select {
+	case offset := <-out:
+		t.Errorf("offset %v released before slow sink ack", offset)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	ins["slow"] <- "1"
+	select {
+	case offset := <-out:
+		if offset != "1" {
+			t.Errorf("expected offset 1, got %v", offset)
+		}
+	case <-time.After(time.Second):
+		t.Error("offset not released")
+	}
+}
+
+func TestDeMultiplexerDrain(t *testing.T) {
+	out := make(chan interface{}, 10)
+	ins := map[string]chan interface{}{
+		"default": make(chan interface{}, 10),
+	}
+	demux := NewDemultiplexer(ins, out)
+	demux.GetCoordinator().Track("1", []string{"default"})
+	ins["default"] <- "1"
+
+	go func() {
+		for range out {
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := demux.Drain(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeMultiplexerDrainPending(t *testing.T) {
+	out := make(chan interface{}, 10)
+	ins := map[string]chan interface{}{
+		"default": make(chan interface{}, 10),
+	}
+	demux := NewDemultiplexer(ins, out)
+	demux.GetCoordinator().Track("1", []string{"default"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := demux.Drain(ctx); err == nil {
+		t.Error("drained before sink ack")
+	}
+
+	// released offsets don't need to be read by source
+	ins["default"] <- "1"
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := demux.Drain(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeMultiplexerReleaseNoSink(t *testing.T) {
+	out := make(chan interface{}, 10)
+	ins := map[string]chan interface{}{
+		"default": make(chan interface{}, 10),
+	}
+	demux := NewDemultiplexer(ins, out)
+	defer demux.Stop()
+	demux.GetCoordinator().Track("1", nil)
+
+	select {
+	case offset := <-out:
+		if offset != "1" {
+			t.Errorf("expected offset 1, got %v", offset)
+		}
+	case <-time.After(time.Second):
+		t.Error("offset sent to no sink not released")
+	}
+}
+
+func TestDeMultiplexerStop(t *testing.T) {
+	out := make(chan interface{})
+	ins := map[string]chan interface{}{
+		"default": make(chan interface{}, 10),
+	}
+	demux := NewDemultiplexer(ins, out)
+	demux.Stop()
+	demux.Stop()
+
+	ins["default"] <- "1"
+	select {
+	case offset := <-out:
+		t.Errorf("offset %v sent after stop", offset)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDeMultiplexerRemoveSink(t *testing.T) {
+	out := make(chan interface{}, 10)
+	ins := map[string]chan interface{}{
+		"fast": make(chan interface{}, 10),
+		"slow": make(chan interface{}, 10),
+	}
+	demux := NewDemultiplexer(ins, out)
+	demux.GetCoordinator().Track("1", []string{"fast", "slow"})
+
+	ins["fast"] <- "1"
+	demux.RemoveSink("slow")
+	select {
+	case offset := <-out:
+		if offset != "1" {
+			t.Errorf("expected offset 1, got %v", offset)
+		}
+	case <-time.After(time.Second):
+		t.Error("offset not released")
+	}
+}
+
+func TestDeMultiplexerAddSink(t *testing.T) {
+	out := make(chan interface{}, 10)
+	ins := map[string]chan interface{}{
+		"default": make(chan interface{}, 10),
+	}
+	demux := NewDemultiplexer(ins, out)
+	replaced := make(chan interface{}, 10)
+	demux.AddSink("default", replaced)
+	demux.GetCoordinator().Track("1", []string{"default"})
+
+	replaced <- "1"
+	select {
+	case offset := <-out:
+		if offset != "1" {
+			t.Errorf("expected offset 1, got %v", offset)
+		}
+	case <-time.After(time.Second):
+		t.Error("offset not released")
+	}
+}
+
+func TestDeMultiplexerDedupCommit(t *testing.T) {
+	out := make(chan interface{}, 10)
+	ins := map[string]chan interface{}{
+		"default": make(chan interface{}, 10),
+	}
+	demux := NewDemultiplexer(ins, out)
+	dedup, err := newDeduplicator(DedupConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	demux.SetDeduplicator(dedup)
+	defer demux.Stop()
+
+	dedup.seen(dedupEvent("1", 1))
+	demux.GetCoordinator().Track("1", []string{"default"})
+	ins["default"] <- "1"
+	select {
+	case <-out:
+	case <-time.After(time.Second):
+		t.Fatal("offset not released")
+	}
+	if size, _ := dedup.counters(); size != 1 {
+		t.Error("key of released offset expected in window")
+	}
+}
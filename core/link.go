@@ -0,0 +1,339 @@
+package core
+
+import (
+	"path/filepath"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+// Overflow policies of link queues
+const (
+	OverflowBlock      = "block"
+	OverflowDropOldest = "drop-oldest"
+	OverflowDropNewest = "drop-newest"
+	OverflowSpill      = "spill"
+)
+
+// DefaultLinkQueueSize default number of events buffered by a link
+const DefaultLinkQueueSize = 100
+
+// DefaultSpillPath default directory of spilled events
+const DefaultSpillPath = "spill"
+
+type (
+	// LinkConfig representation of the configuration of a link between a source and a sink
+	LinkConfig struct {
+		QueueSize        int         `json:"queue_size" mapstructure:"queue_size"`
+		OverflowPolicy   string      `json:"overflow_policy" mapstructure:"overflow_policy"`
+		SpillPath        string      `json:"spill_path" mapstructure:"spill_path"`
+		SpillMaxSize     int64       `json:"spill_max_size" mapstructure:"spill_max_size"`
+		SpillSegmentSize int64       `json:"spill_segment_size" mapstructure:"spill_segment_size"`
+		Routes           []RouteRule `json:"routes" mapstructure:"routes"`
+		ExcludeRoutes    []RouteRule `json:"exclude_routes" mapstructure:"exclude_routes"`
+	}
+
+	// link bounded queue of events sent by a source to a sink
+	// events are forwarded to sink by a dedicated goroutine so a slow sink does not stall others
+	link struct {
+		sync.Mutex
+		cond       *sync.Cond
+		sinkName   string
+		config     LinkConfig
+		out        chan events.LookatchEvent
+		outChanged chan struct{}
+		queue      []events.LookatchEvent
+		spill      *spillQueue
+		inFlight   int
+		dropped    uint64
+		spilled    uint64
+		closed     bool
+		abort      chan struct{}
+		stopped    chan struct{}
+	}
+)
+
+// withDefaults return config with default values for unset fields
+func (c LinkConfig) withDefaults() LinkConfig {
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultLinkQueueSize
+	}
+	if c.OverflowPolicy == "" {
+		c.OverflowPolicy = OverflowBlock
+	}
+	if c.SpillPath == "" {
+		c.SpillPath = DefaultSpillPath
+	}
+	return c
+}
+
+// newLink create link and start forwarding events to sink
+func newLink(sinkName string, out chan events.LookatchEvent, config LinkConfig) *link {
+	l := &link{
+		sinkName:   sinkName,
+		config:     config.withDefaults(),
+		out:        out,
+		outChanged: make(chan struct{}),
+		queue:      make([]events.LookatchEvent, 0, DefaultLinkQueueSize),
+		abort:      make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	l.cond = sync.NewCond(&l.Mutex)
+	if l.config.OverflowPolicy == OverflowSpill {
+		if err := l.openSpill(); err != nil {
+			log.WithError(err).WithField("sink", sinkName).Error("Error while opening spill queue")
+		}
+	}
+	go l.forward()
+	return l
+}
+
+// accept return true if event matches routes of link
+func (l *link) accept(event events.LookatchEvent) bool {
+	l.Lock()
+	defer l.Unlock()
+	return routeEvent(l.config.Routes, l.config.ExcludeRoutes, event)
+}
+
+// push add event to queue, overflow policy is applied if queue is full
+func (l *link) push(event events.LookatchEvent) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.closed {
+		return
+	}
+	if l.isFull() {
+		switch l.config.OverflowPolicy {
+		case OverflowDropNewest:
+			l.dropped++
+			return
+		case OverflowDropOldest:
+			if len(l.queue) > 0 {
+				l.queue = l.queue[1:]
+				l.dropped++
+			}
+		case OverflowSpill:
+			if l.pushSpill(event) {
+				return
+			}
+			// spill queue can't be written, wait for room in memory
+			l.waitRoom()
+		default:
+			l.waitRoom()
+		}
+		if l.closed {
+			return
+		}
+	}
+	l.queue = append(l.queue, event)
+	l.cond.Broadcast()
+}
+
+// isFull return true if event can't be added to memory queue
+// once events are spilled, next ones are spilled too to keep order
+func (l *link) isFull() bool {
+	return len(l.queue) >= l.config.QueueSize || l.spill != nil && l.spill.Len() > 0
+}
+
+// waitRoom wait until memory queue has room or link is closed
+func (l *link) waitRoom() {
+	for l.isFull() && !l.closed {
+		l.cond.Wait()
+	}
+}
+
+// openSpill open empty spill queue of link
+// events spilled by a previous run are discarded, the source replays them from its last committed offset
+func (l *link) openSpill() error {
+	spill, err := newSpillQueue(filepath.Join(l.config.SpillPath, l.sinkName), l.config.SpillSegmentSize, l.config.SpillMaxSize)
+	if err != nil {
+		return err
+	}
+	if spill.Len() > 0 {
+		log.WithField("sink", l.sinkName).WithField("events", spill.Len()).Info("Discarding events spilled by previous run")
+		if err = spill.clear(); err != nil {
+			return err
+		}
+	}
+	l.spill = spill
+	return nil
+}
+
+// pushSpill write event to disk, wait while spill size cap is reached
+// return false if spill queue can't be written
+func (l *link) pushSpill(event events.LookatchEvent) bool {
+	if l.spill == nil {
+		if err := l.openSpill(); err != nil {
+			log.WithError(err).WithField("sink", l.sinkName).Error("Error while opening spill queue")
+			return false
+		}
+	}
+	for !l.closed {
+		err := l.spill.Push(event)
+		if err == nil {
+			l.spilled++
+			l.cond.Broadcast()
+			return true
+		}
+		if err != errSpillFull {
+			log.WithError(err).WithField("sink", l.sinkName).Error("Error while spilling event")
+			return false
+		}
+		l.cond.Wait()
+	}
+	return true
+}
+
+// pop wait for next event
+// return false once link is closed and queue is empty
+func (l *link) pop() (event events.LookatchEvent, ok bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	for {
+		if len(l.queue) > 0 {
+			event = l.queue[0]
+			l.queue = l.queue[1:]
+			break
+		}
+		if l.spill != nil && l.spill.Len() > 0 {
+			length := l.spill.Len()
+			var err error
+			event, err = l.spill.Pop()
+			if err == nil {
+				break
+			}
+			log.WithError(err).WithField("sink", l.sinkName).Error("Error while reading spilled event")
+			l.dropped++
+			// spill file can't be read anymore, remaining events are lost
+			if l.spill.Len() == length {
+				l.dropped += uint64(length - 1)
+				l.spill.Remove()
+				l.spill = nil
+			}
+			continue
+		}
+		if l.closed {
+			if l.spill != nil {
+				l.spill.Close()
+			}
+			return event, false
+		}
+		l.cond.Wait()
+	}
+	l.inFlight++
+	l.cond.Broadcast()
+	return event, true
+}
+
+// forward send events of queue to sink until link is closed
+func (l *link) forward() {
+	defer close(l.stopped)
+	for {
+		event, ok := l.pop()
+		if !ok {
+			return
+		}
+		if !l.send(event) {
+			return
+		}
+		l.Lock()
+		l.inFlight--
+		l.Unlock()
+	}
+}
+
+// send event to sink
+// sink channel may be replaced while waiting, return false if link is aborted
+func (l *link) send(event events.LookatchEvent) bool {
+	for {
+		l.Lock()
+		out, changed := l.out, l.outChanged
+		l.Unlock()
+		select {
+		case out <- event:
+			return true
+		case <-changed:
+		case <-l.abort:
+			return false
+		}
+	}
+}
+
+// update replace sink channel and configuration
+func (l *link) update(out chan events.LookatchEvent, config LinkConfig) {
+	l.Lock()
+	defer l.Unlock()
+	if out != l.out {
+		l.out = out
+		close(l.outChanged)
+		l.outChanged = make(chan struct{})
+	}
+	l.config = config.withDefaults()
+	l.cond.Broadcast()
+}
+
+// close stop accepting events, remaining events are still sent to sink
+// spilled events are synced to disk, they are discarded on next start as source replays them
+func (l *link) close() {
+	l.Lock()
+	l.closed = true
+	if l.spill != nil {
+		if err := l.spill.Sync(); err != nil {
+			log.WithError(err).WithField("sink", l.sinkName).Error("Error while syncing spill queue")
+		}
+	}
+	l.cond.Broadcast()
+	l.Unlock()
+}
+
+// remove stop link and discard remaining events
+func (l *link) remove() {
+	l.Lock()
+	l.closed = true
+	select {
+	case <-l.abort:
+	default:
+		close(l.abort)
+	}
+	l.queue = nil
+	if l.spill != nil {
+		if err := l.spill.Remove(); err != nil {
+			log.WithError(err).WithField("sink", l.sinkName).Error("Error while removing spill queue")
+		}
+		l.spill = nil
+	}
+	l.cond.Broadcast()
+	l.Unlock()
+}
+
+// depth return number of events waiting to be sent to sink
+func (l *link) depth() int {
+	l.Lock()
+	defer l.Unlock()
+	depth := len(l.queue) + l.inFlight
+	if l.spill != nil {
+		depth += l.spill.Len()
+	}
+	return depth
+}
+
+// counters return number of dropped and spilled events
+func (l *link) counters() (uint64, uint64) {
+	l.Lock()
+	defer l.Unlock()
+	return l.dropped, l.spilled
+}
+
+// spillSize return size of spilled events on disk in bytes
+func (l *link) spillSize() int64 {
+	l.Lock()
+	defer l.Unlock()
+	if l.spill == nil {
+		return 0
+	}
+	return l.spill.Size()
+}
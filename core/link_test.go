@@ -0,0 +1,194 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+func newTestEvent(value int) events.LookatchEvent {
+	return events.LookatchEvent{
+		Payload: events.GenericEvent{
+			Value: value,
+		},
+	}
+}
+
+func eventValue(event events.LookatchEvent) interface{} {
+	return event.Payload.(events.GenericEvent).Value
+}
+
+// waitInFlight wait until first event is popped from queue
+func waitInFlight(l *link) {
+	l.Lock()
+	defer l.Unlock()
+	for l.inFlight == 0 {
+		l.cond.Wait()
+	}
+}
+
+func TestLinkDropNewest(t *testing.T) {
+	out := make(chan events.LookatchEvent)
+	l := newLink("default", out, LinkConfig{QueueSize: 1, OverflowPolicy: OverflowDropNewest})
+	defer l.remove()
+
+	l.push(newTestEvent(1))
+	waitInFlight(l)
+	l.push(newTestEvent(2))
+	l.push(newTestEvent(3))
+
+	if value := eventValue(<-out); value != 1 {
+		t.Errorf("expected 1, got %v", value)
+	}
+	if value := eventValue(<-out); value != 2 {
+		t.Errorf("expected 2, got %v", value)
+	}
+	if dropped, _ := l.counters(); dropped != 1 {
+		t.Errorf("expected 1 dropped event, got %d", dropped)
+	}
+}
+
+func TestLinkDropOldest(t *testing.T) {
+	out := make(chan events.LookatchEvent)
+	l := newLink("default", out, LinkConfig{QueueSize: 1, OverflowPolicy: OverflowDropOldest})
+	defer l.remove()
+
+	l.push(newTestEvent(1))
+	waitInFlight(l)
+	l.push(newTestEvent(2))
+	l.push(newTestEvent(3))
+
+	if value := eventValue(<-out); value != 1 {
+		t.Errorf("expected 1, got %v", value)
+	}
+	if value := eventValue(<-out); value != 3 {
+		t.Errorf("expected 3, got %v", value)
+	}
+	if dropped, _ := l.counters(); dropped != 1 {
+		t.Errorf("expected 1 dropped event, got %d", dropped)
+	}
+}
+
+func TestLinkBlock(t *testing.T) {
+	out := make(chan events.LookatchEvent)
+	l := newLink("default", out, LinkConfig{QueueSize: 1})
+	defer l.remove()
+
+	pushed := make(chan struct{})
+	go func() {
+		for i := 1; i <= 3; i++ {
+			l.push(newTestEvent(i))
+		}
+		close(pushed)
+	}()
+
+	select {
+	case <-pushed:
+		t.Error("push not blocked")
+	case <-time.After(100 * time.Millisecond):
+	}
+	for i := 1; i <= 3; i++ {
+		if value := eventValue(<-out); value != i {
+			t.Errorf("expected %d, got %v", i, value)
+		}
+	}
+	<-pushed
+}
+
+func TestLinkSpill(t *testing.T) {
+	out := make(chan events.LookatchEvent)
+	l := newLink("default", out, LinkConfig{QueueSize: 1, OverflowPolicy: OverflowSpill, SpillPath: t.TempDir()})
+	defer l.remove()
+
+	for i := 1; i <= 5; i++ {
+		l.push(newTestEvent(i))
+	}
+	if _, spilled := l.counters(); spilled == 0 {
+		t.Error("no event spilled")
+	}
+	if l.depth() != 5 {
+		t.Errorf("expected depth 5, got %d", l.depth())
+	}
+
+	for i := 1; i <= 5; i++ {
+		// spilled values are decoded as json.Number
+		value := eventValue(<-out)
+		if fmt.Sprint(value) != strconv.Itoa(i) {
+			t.Errorf("expected %d, got %v", i, value)
+		}
+	}
+}
+
+func TestLinkUpdate(t *testing.T) {
+	out := make(chan events.LookatchEvent)
+	replaced := make(chan events.LookatchEvent)
+	l := newLink("default", out, LinkConfig{})
+	defer l.remove()
+
+	l.push(newTestEvent(1))
+	l.update(replaced, LinkConfig{QueueSize: 10})
+
+	if value := eventValue(<-replaced); value != 1 {
+		t.Errorf("expected 1, got %v", value)
+	}
+	l.Lock()
+	defer l.Unlock()
+	if l.config.QueueSize != 10 {
+		t.Fail()
+	}
+}
+
+func TestLinkClose(t *testing.T) {
+	out := make(chan events.LookatchEvent, 2)
+	l := newLink("default", out, LinkConfig{})
+
+	l.push(newTestEvent(1))
+	l.push(newTestEvent(2))
+	l.close()
+	l.push(newTestEvent(3))
+
+	select {
+	case <-l.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("link not stopped")
+	}
+	if len(out) != 2 {
+		t.Errorf("expected 2 events, got %d", len(out))
+	}
+}
+
+func TestLinkSpillDiscarded(t *testing.T) {
+	dir := t.TempDir()
+	out := make(chan events.LookatchEvent)
+	l := newLink("default", out, LinkConfig{QueueSize: 1, OverflowPolicy: OverflowSpill, SpillPath: dir})
+	defer l.remove()
+
+	for i := 1; i <= 5; i++ {
+		l.push(newTestEvent(i))
+	}
+	// sink is down, spilled events are synced to disk
+	l.close()
+	spill, err := newSpillQueue(filepath.Join(dir, "default"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spill.Len() == 0 {
+		t.Error("spilled events not synced")
+	}
+	spill.Close()
+
+	// source replays events from its committed offset, spilled ones are not sent twice
+	other := newLink("default", out, LinkConfig{QueueSize: 1, OverflowPolicy: OverflowSpill, SpillPath: dir})
+	defer other.remove()
+	if depth := other.depth(); depth != 0 {
+		t.Errorf("expected no event, got %d", depth)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "default", "*"+spillSegmentExt))
+	if len(files) != 0 {
+		t.Error("spill segments not removed", files)
+	}
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/juju/errors"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+// Metadata fields which can be added to events
+const (
+	MetadataAgentUUID       = "agent_uuid"
+	MetadataHostname        = "hostname"
+	MetadataSourceName      = "source_name"
+	MetadataSourceType      = "source_type"
+	MetadataSequence        = "sequence"
+	MetadataIngestTimestamp = "ingest_timestamp"
+	MetadataAll             = "all"
+)
+
+// enricher add metadata of agent and pipeline to events of a source
+// sequence is kept in memory, it restarts at 1 with each enricher, identified by runID
+type enricher struct {
+	fields   map[string]bool
+	source   events.Metadata
+	runID    string
+	sequence uint64
+	now      func() time.Time
+}
+
+// newEnricher create enricher setting fields of metadata
+// source holds agent and source values of metadata
+func newEnricher(fields []string, source events.Metadata) (*enricher, error) {
+	e := &enricher{
+		fields: make(map[string]bool, len(fields)),
+		source: source,
+		runID:  uuid.New().String(),
+		now:    time.Now,
+	}
+	for _, field := range fields {
+		switch field {
+		case MetadataAgentUUID, MetadataHostname, MetadataSourceName, MetadataSourceType, MetadataSequence, MetadataIngestTimestamp:
+			e.fields[field] = true
+		case MetadataAll:
+			for _, f := range []string{MetadataAgentUUID, MetadataHostname, MetadataSourceName, MetadataSourceType, MetadataSequence, MetadataIngestTimestamp} {
+				e.fields[f] = true
+			}
+		default:
+			return nil, errors.Errorf("unknown metadata field '%s'", field)
+		}
+	}
+	return e, nil
+}
+
+// enrich set metadata of SQL and generic events
+// sequence is incremented for each enriched event
+func (e *enricher) enrich(event *events.LookatchEvent) {
+	switch payload := event.Payload.(type) {
+	case events.SQLEvent:
+		payload.Metadata = e.metadata()
+		event.Payload = payload
+	case events.GenericEvent:
+		payload.Metadata = e.metadata()
+		event.Payload = payload
+	}
+}
+
+// metadata return configured fields of metadata of next event
+func (e *enricher) metadata() *events.Metadata {
+	metadata := &events.Metadata{}
+	if e.fields[MetadataAgentUUID] {
+		metadata.AgentUUID = e.source.AgentUUID
+	}
+	if e.fields[MetadataHostname] {
+		metadata.Hostname = e.source.Hostname
+	}
+	if e.fields[MetadataSourceName] {
+		metadata.SourceName = e.source.SourceName
+	}
+	if e.fields[MetadataSourceType] {
+		metadata.SourceType = e.source.SourceType
+	}
+	if e.fields[MetadataSequence] {
+		metadata.Sequence = atomic.AddUint64(&e.sequence, 1)
+		metadata.RunID = e.runID
+	}
+	if e.fields[MetadataIngestTimestamp] {
+		metadata.IngestTimestamp = e.now().UnixNano()
+	}
+	return metadata
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+func TestEnricher(t *testing.T) {
+	enricher, err := newEnricher([]string{MetadataSourceName, MetadataSequence, MetadataIngestTimestamp}, events.Metadata{
+		AgentUUID:  "agent-uuid",
+		SourceName: "default",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enricher.now = func() time.Time { return time.Unix(0, 42) }
+
+	event := events.LookatchEvent{Payload: events.SQLEvent{}}
+	enricher.enrich(&event)
+	metadata := event.Payload.(events.SQLEvent).Metadata
+	if metadata == nil || metadata.SourceName != "default" || metadata.Sequence != 1 || metadata.IngestTimestamp != 42 {
+		t.Errorf("unexpected metadata %+v", metadata)
+	}
+	if metadata.AgentUUID != "" {
+		t.Error("agent uuid not configured")
+	}
+
+	event = events.LookatchEvent{Payload: events.GenericEvent{}}
+	enricher.enrich(&event)
+	if event.Payload.(events.GenericEvent).Metadata.Sequence != 2 {
+		t.Error("sequence expected to be incremented")
+	}
+	if metadata.RunID == "" || event.Payload.(events.GenericEvent).Metadata.RunID != metadata.RunID {
+		t.Error("run id expected to be kept")
+	}
+}
+
+func TestEnricherRunID(t *testing.T) {
+	first, err := newEnricher([]string{MetadataSequence}, events.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := newEnricher([]string{MetadataSequence}, events.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sequence restarts with a new run id
+	a, b := first.metadata(), second.metadata()
+	if a.Sequence != 1 || b.Sequence != 1 || a.RunID == b.RunID {
+		t.Errorf("unexpected metadata %+v %+v", a, b)
+	}
+}
+
+func TestEnricherAll(t *testing.T) {
+	enricher, err := newEnricher([]string{MetadataAll}, events.Metadata{AgentUUID: "agent-uuid", Hostname: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := enricher.metadata()
+	if metadata.AgentUUID != "agent-uuid" || metadata.Hostname != "host" || metadata.Sequence != 1 || metadata.IngestTimestamp == 0 {
+		t.Errorf("unexpected metadata %+v", metadata)
+	}
+}
+
+func TestEnricherInvalidField(t *testing.T) {
+	if _, err := newEnricher([]string{"unknown"}, events.Metadata{}); err == nil {
+		t.Fail()
+	}
+}
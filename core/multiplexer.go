@@ -1,28 +1,254 @@
 package core
 
-import "github.com/Pirionfr/lookatch-agent/events"
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+	"github.com/Pirionfr/lookatch-agent/processors"
+	"github.com/Pirionfr/lookatch-agent/utils"
+)
 
 // Multiplexer represent the Multiplexer of collector
 type Multiplexer struct {
-	in   chan events.LookatchEvent
-	outs []chan events.LookatchEvent
+	sync.RWMutex
+	in          chan events.LookatchEvent
+	sourceName  string
+	links       map[string]*link
+	sinks       []string
+	coordinator *CommitCoordinator
+	pipeline    processors.Pipeline
+	dedup       *deduplicator
+	enricher    *enricher
+	failed      uint64
+	done        chan struct{}
+	stopped     chan struct{}
 }
 
 // NewMultiplexer create a new multiplexer
-func NewMultiplexer(in chan events.LookatchEvent, outs []chan events.LookatchEvent) (multiplexer *Multiplexer) {
+// each sink gets its own queue configured by configs, default config is used for missing sinks
+// offsets of events are registered to the coordinator before being sent to sinks
+func NewMultiplexer(in chan events.LookatchEvent, outs map[string]chan events.LookatchEvent, configs map[string]LinkConfig, coordinator *CommitCoordinator) (multiplexer *Multiplexer) {
 	multiplexer = &Multiplexer{
-		in:   in,
-		outs: outs,
+		in:          in,
+		links:       make(map[string]*link),
+		coordinator: coordinator,
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
 	}
+	for sinkName, out := range outs {
+		multiplexer.links[sinkName] = newLink(sinkName, out, configs[sinkName])
+	}
+	multiplexer.sortSinks()
 	go multiplexer.consumer()
 	return
 }
 
 // consumer send event from source to sink
+// once stopped, events remaining in source channel and link queues are sent before returning
 func (a *Multiplexer) consumer() {
-	for event := range a.in {
-		for value := range a.outs {
-			a.outs[value] <- event
+	defer close(a.stopped)
+	for {
+		select {
+		case event := <-a.in:
+			a.send(event)
+		case <-a.done:
+			for {
+				select {
+				case event := <-a.in:
+					a.send(event)
+				default:
+					a.closeLinks()
+					return
+				}
+			}
 		}
 	}
 }
+
+// SetPipeline transform next events with pipeline before sending them to sinks
+func (a *Multiplexer) SetPipeline(pipeline processors.Pipeline) {
+	a.Lock()
+	defer a.Unlock()
+	a.pipeline = pipeline
+}
+
+// SetSourceName set name of source in header of next events
+func (a *Multiplexer) SetSourceName(sourceName string) {
+	a.Lock()
+	defer a.Unlock()
+	a.sourceName = sourceName
+}
+
+// SetDeduplicator drop next events already sent before being processed
+func (a *Multiplexer) SetDeduplicator(dedup *deduplicator) {
+	a.Lock()
+	defer a.Unlock()
+	a.dedup = dedup
+}
+
+// SetEnricher add metadata to next events before they are processed
+func (a *Multiplexer) SetEnricher(enricher *enricher) {
+	a.Lock()
+	defer a.Unlock()
+	a.enricher = enricher
+}
+
+// send process event, track its offset and push it to queues of sinks matching its routes
+// lock is released before pushing so a blocked queue can still be removed
+// duplicated events and events processors failed on are sent to no sink
+func (a *Multiplexer) send(event events.LookatchEvent) {
+	a.RLock()
+	if a.dedup != nil && a.dedup.seen(event) {
+		a.track(event, nil)
+		a.RUnlock()
+		return
+	}
+	if a.sourceName != "" {
+		event.Header.SourceName = a.sourceName
+	}
+	if a.enricher != nil {
+		a.enricher.enrich(&event)
+	}
+	// processors update event in place, a partly processed event is dropped
+	if err := a.pipeline.Process(&event); err != nil {
+		atomic.AddUint64(&a.failed, 1)
+		entry := log.WithError(err).WithField("source", a.sourceName)
+		if offset := event.GetOffset(); offset != nil {
+			entry = entry.WithField("offset", offset.Source)
+		}
+		entry.Error("Error while processing event, event is dropped")
+		a.track(event, nil)
+		a.RUnlock()
+		return
+	}
+	sinks := make([]string, 0, len(a.sinks))
+	links := make([]*link, 0, len(a.sinks))
+	for _, sinkName := range a.sinks {
+		if l := a.links[sinkName]; l.accept(event) {
+			sinks = append(sinks, sinkName)
+			links = append(links, l)
+		}
+	}
+	a.track(event, sinks)
+	a.RUnlock()
+
+	for _, l := range links {
+		l.push(event)
+	}
+}
+
+// track register offset of event sent to sinks
+// offset of an event sent to no sink is released as soon as previous offsets are acknowledged
+func (a *Multiplexer) track(event events.LookatchEvent, sinks []string) {
+	if offset := event.GetOffset(); a.coordinator != nil && offset != nil && offset.Source != "" {
+		a.coordinator.Track(offset.Source, sinks)
+	}
+}
+
+// closeLinks wait until all queued events are sent to sinks
+func (a *Multiplexer) closeLinks() {
+	a.RLock()
+	links := make([]*link, 0, len(a.links))
+	for _, l := range a.links {
+		l.close()
+		links = append(links, l)
+	}
+	a.RUnlock()
+	for _, l := range links {
+		<-l.stopped
+	}
+}
+
+// AddSink send next events to sink
+// input channel and queue configuration are replaced if sink is already linked
+func (a *Multiplexer) AddSink(sinkName string, out chan events.LookatchEvent, config LinkConfig) {
+	a.Lock()
+	defer a.Unlock()
+	if l, ok := a.links[sinkName]; ok {
+		l.update(out, config)
+		return
+	}
+	a.links[sinkName] = newLink(sinkName, out, config)
+	a.sortSinks()
+}
+
+// RemoveSink stop sending events to sink, queued events are discarded
+func (a *Multiplexer) RemoveSink(sinkName string) {
+	a.Lock()
+	defer a.Unlock()
+	if l, ok := a.links[sinkName]; ok {
+		l.remove()
+		delete(a.links, sinkName)
+	}
+	a.sortSinks()
+}
+
+// GetSinks return sorted names of linked sinks
+func (a *Multiplexer) GetSinks() []string {
+	a.RLock()
+	defer a.RUnlock()
+	sinks := make([]string, len(a.sinks))
+	copy(sinks, a.sinks)
+	return sinks
+}
+
+// GetMeta return queue depth, dropped and spilled events and spill size of each link,
+// and number of events dropped on processing error
+func (a *Multiplexer) GetMeta() map[string]utils.Meta {
+	a.RLock()
+	defer a.RUnlock()
+
+	depth := make(map[string]int, len(a.links))
+	dropped := make(map[string]uint64, len(a.links))
+	spilled := make(map[string]uint64, len(a.links))
+	spillSize := make(map[string]int64, len(a.links))
+	for sinkName, l := range a.links {
+		depth[sinkName] = l.depth()
+		dropped[sinkName], spilled[sinkName] = l.counters()
+		spillSize[sinkName] = l.spillSize()
+	}
+
+	meta := make(map[string]utils.Meta)
+	meta["links_queue_depth"] = utils.NewMeta("links_queue_depth", depth)
+	meta["links_dropped_events"] = utils.NewMeta("links_dropped_events", dropped)
+	meta["links_spilled_events"] = utils.NewMeta("links_spilled_events", spilled)
+	meta["links_spill_size"] = utils.NewMeta("links_spill_size", spillSize)
+	meta["processing_failed_events"] = utils.NewMeta("processing_failed_events", atomic.LoadUint64(&a.failed))
+	if a.dedup != nil {
+		size, dropped := a.dedup.counters()
+		meta["dedup_window_size"] = utils.NewMeta("dedup_window_size", size)
+		meta["dedup_dropped_events"] = utils.NewMeta("dedup_dropped_events", dropped)
+	}
+	return meta
+}
+
+// sortSinks update sorted sink names from links
+func (a *Multiplexer) sortSinks() {
+	sinks := make([]string, 0, len(a.links))
+	for sinkName := range a.links {
+		sinks = append(sinks, sinkName)
+	}
+	sort.Strings(sinks)
+	a.sinks = sinks
+}
+
+// Stop drain source channel and link queues then stop multiplexer
+// return an error if context is done before all events are sent to sinks
+func (a *Multiplexer) Stop(ctx context.Context) error {
+	select {
+	case <-a.done:
+	default:
+		close(a.done)
+	}
+	select {
+	case <-a.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,22 +1,295 @@
 package core
 
 import (
+	"context"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/Pirionfr/lookatch-agent/events"
+	"github.com/Pirionfr/lookatch-agent/processors"
 )
 
 var (
-	sinksChan []chan events.LookatchEvent
+	sinksChan map[string]chan events.LookatchEvent
 	in        chan events.LookatchEvent
 )
 
 func TestNewMultiplexer(t *testing.T) {
 	in = make(chan events.LookatchEvent, 1)
-	sinksChan = append(sinksChan, make(chan events.LookatchEvent, 1))
-	multiplexer := NewMultiplexer(in, sinksChan)
+	sinksChan = map[string]chan events.LookatchEvent{
+		"default": make(chan events.LookatchEvent, 1),
+	}
+	multiplexer := NewMultiplexer(in, sinksChan, nil, nil)
 	if reflect.TypeOf(multiplexer).String() != "*core.Multiplexer" {
 		t.Error("mistmatch")
 	}
 }
+
+func TestMultiplexerTrackOffset(t *testing.T) {
+	source := make(chan events.LookatchEvent, 1)
+	sink := make(chan events.LookatchEvent, 1)
+	coordinator := NewCommitCoordinator([]string{"default"})
+	NewMultiplexer(source, map[string]chan events.LookatchEvent{"default": sink}, nil, coordinator)
+
+	source <- events.LookatchEvent{
+		Payload: events.GenericEvent{
+			Offset: &events.Offset{Source: "1"},
+		},
+	}
+	<-sink
+
+	released, ok := coordinator.Ack("default", "1")
+	if !ok || released != "1" {
+		t.Fail()
+	}
+}
+
+func TestMultiplexerStop(t *testing.T) {
+	source := make(chan events.LookatchEvent, 2)
+	sink := make(chan events.LookatchEvent, 2)
+	multiplexer := NewMultiplexer(source, map[string]chan events.LookatchEvent{"default": sink}, nil, nil)
+
+	source <- events.LookatchEvent{}
+	source <- events.LookatchEvent{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := multiplexer.Stop(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(source) != 0 || len(sink) != 2 {
+		t.Fail()
+	}
+}
+
+func TestMultiplexerStopTimeout(t *testing.T) {
+	source := make(chan events.LookatchEvent, 2)
+	sink := make(chan events.LookatchEvent)
+	multiplexer := NewMultiplexer(source, map[string]chan events.LookatchEvent{"default": sink}, nil, nil)
+
+	source <- events.LookatchEvent{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if multiplexer.Stop(ctx) == nil {
+		t.Fail()
+	}
+}
+
+func TestMultiplexerAddRemoveSink(t *testing.T) {
+	source := make(chan events.LookatchEvent, 1)
+	sink := make(chan events.LookatchEvent, 1)
+	other := make(chan events.LookatchEvent, 1)
+	multiplexer := NewMultiplexer(source, map[string]chan events.LookatchEvent{"default": sink}, nil, nil)
+
+	multiplexer.AddSink("other", other, LinkConfig{})
+	if !reflect.DeepEqual(multiplexer.GetSinks(), []string{"default", "other"}) {
+		t.Fail()
+	}
+	source <- events.LookatchEvent{}
+	<-sink
+	<-other
+
+	multiplexer.RemoveSink("default")
+	if !reflect.DeepEqual(multiplexer.GetSinks(), []string{"other"}) {
+		t.Fail()
+	}
+	source <- events.LookatchEvent{}
+	<-other
+	if len(sink) != 0 {
+		t.Fail()
+	}
+}
+
+func TestMultiplexerGetMeta(t *testing.T) {
+	source := make(chan events.LookatchEvent, 1)
+	sink := make(chan events.LookatchEvent)
+	configs := map[string]LinkConfig{"default": {QueueSize: 1, OverflowPolicy: OverflowDropNewest}}
+	multiplexer := NewMultiplexer(source, map[string]chan events.LookatchEvent{"default": sink}, configs, nil)
+
+	for i := 0; i < 3; i++ {
+		source <- events.LookatchEvent{}
+	}
+	for len(source) != 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// first event may be dropped or in flight depending on forwarder timing
+	meta := multiplexer.GetMeta()
+	depth := meta["links_queue_depth"].Value.(map[string]int)["default"]
+	dropped := meta["links_dropped_events"].Value.(map[string]uint64)["default"]
+	if dropped == 0 || depth+int(dropped) != 3 {
+		t.Errorf("unexpected queue depth %d and dropped events %d", depth, dropped)
+	}
+}
+
+func TestMultiplexerSpillCommit(t *testing.T) {
+	source := make(chan events.LookatchEvent, 5)
+	sink := make(chan events.LookatchEvent)
+	coordinator := NewCommitCoordinator([]string{"default"})
+	configs := map[string]LinkConfig{"default": {QueueSize: 1, OverflowPolicy: OverflowSpill, SpillPath: t.TempDir()}}
+	NewMultiplexer(source, map[string]chan events.LookatchEvent{"default": sink}, configs, coordinator)
+
+	for i := 1; i <= 5; i++ {
+		source <- events.LookatchEvent{
+			Payload: events.GenericEvent{
+				Offset: &events.Offset{Source: strconv.Itoa(i)},
+			},
+		}
+	}
+	for len(source) != 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// offsets are released only once replayed events are acknowledged
+	for i := 1; i <= 5; i++ {
+		event := <-sink
+		offset := event.GetOffset().Source
+		if offset != strconv.Itoa(i) {
+			t.Fatalf("expected offset %d, got %s", i, offset)
+		}
+		if len(coordinator.pending) != 6-i {
+			t.Errorf("expected %d pending offsets, got %d", 6-i, len(coordinator.pending))
+		}
+		released, ok := coordinator.Ack("default", offset)
+		if !ok || released != offset {
+			t.Errorf("offset %s not released", offset)
+		}
+	}
+}
+
+func TestMultiplexerRoutes(t *testing.T) {
+	source := make(chan events.LookatchEvent, 2)
+	billing := make(chan events.LookatchEvent, 2)
+	other := make(chan events.LookatchEvent, 2)
+	coordinator := NewCommitCoordinator([]string{"billing", "other"})
+	rules := []RouteRule{{Database: []string{"billing"}}}
+	NewMultiplexer(source, map[string]chan events.LookatchEvent{"billing": billing, "other": other}, map[string]LinkConfig{
+		"billing": {Routes: rules},
+		"other":   {ExcludeRoutes: rules},
+	}, coordinator)
+
+	source <- events.LookatchEvent{
+		Payload: events.SQLEvent{Database: "billing", Offset: &events.Offset{Source: "1"}},
+	}
+	source <- events.LookatchEvent{
+		Payload: events.SQLEvent{Database: "shop", Offset: &events.Offset{Source: "2"}},
+	}
+
+	if (<-billing).Payload.(events.SQLEvent).Database != "billing" {
+		t.Fail()
+	}
+	if (<-other).Payload.(events.SQLEvent).Database != "shop" {
+		t.Fail()
+	}
+
+	if _, ok := coordinator.Ack("billing", "1"); !ok {
+		t.Error("offset only sent to billing not released")
+	}
+	released, ok := coordinator.Ack("other", "2")
+	if !ok || released != "2" {
+		t.Fail()
+	}
+}
+
+func TestMultiplexerPipeline(t *testing.T) {
+	source := make(chan events.LookatchEvent, 1)
+	sink := make(chan events.LookatchEvent, 1)
+	multiplexer := NewMultiplexer(source, map[string]chan events.LookatchEvent{"default": sink}, nil, nil)
+	pipeline, err := processors.NewPipeline([]processors.Config{{Type: processors.RenameType, Field: "a", To: "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiplexer.SetPipeline(pipeline)
+
+	source <- events.LookatchEvent{
+		Payload: events.SQLEvent{Statement: map[string]interface{}{"a": 1}},
+	}
+	if (<-sink).Payload.(events.SQLEvent).Statement["b"] != 1 {
+		t.Fail()
+	}
+}
+
+func TestMultiplexerPipelineError(t *testing.T) {
+	source := make(chan events.LookatchEvent, 2)
+	sink := make(chan events.LookatchEvent, 2)
+	coordinator := NewCommitCoordinator([]string{"default"})
+	multiplexer := NewMultiplexer(source, map[string]chan events.LookatchEvent{"default": sink}, nil, coordinator)
+	pipeline, err := processors.NewPipeline([]processors.Config{
+		{Type: processors.RenameType, Field: "a", To: "b"},
+		{Type: processors.CastType, Field: "c", To: "int"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiplexer.SetPipeline(pipeline)
+
+	source <- events.LookatchEvent{
+		Payload: events.SQLEvent{Statement: map[string]interface{}{"a": 1, "c": "x"}, Offset: &events.Offset{Source: "1"}},
+	}
+	source <- events.LookatchEvent{
+		Payload: events.SQLEvent{Statement: map[string]interface{}{"a": 2, "c": "3"}, Offset: &events.Offset{Source: "2"}},
+	}
+	// partly processed event is not sent
+	if (<-sink).Payload.(events.SQLEvent).Statement["b"] != 2 {
+		t.Error("event failed to process sent")
+	}
+	if released, ok := coordinator.Ack("default", "2"); !ok || released != "2" {
+		t.Error("offset of dropped event not tracked", released)
+	}
+	if multiplexer.GetMeta()["processing_failed_events"].Value != uint64(1) {
+		t.Error(multiplexer.GetMeta()["processing_failed_events"])
+	}
+}
+
+func TestMultiplexerSourceName(t *testing.T) {
+	source := make(chan events.LookatchEvent, 1)
+	sink := make(chan events.LookatchEvent, 1)
+	multiplexer := NewMultiplexer(source, map[string]chan events.LookatchEvent{"default": sink}, nil, nil)
+	multiplexer.SetSourceName("mysql")
+
+	source <- events.LookatchEvent{
+		Payload: events.SQLEvent{},
+	}
+	if (<-sink).Header.SourceName != "mysql" {
+		t.Fail()
+	}
+}
+
+func TestMultiplexerDedup(t *testing.T) {
+	source := make(chan events.LookatchEvent, 2)
+	sink := make(chan events.LookatchEvent, 2)
+	coordinator := NewCommitCoordinator([]string{"default"})
+	multiplexer := NewMultiplexer(source, map[string]chan events.LookatchEvent{"default": sink}, nil, coordinator)
+	dedup, err := newDeduplicator(DedupConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dedup.close()
+	multiplexer.SetDeduplicator(dedup)
+
+	event := events.LookatchEvent{
+		Payload: events.SQLEvent{Offset: &events.Offset{Source: "1"}},
+	}
+	source <- event
+	<-sink
+	event.Payload = events.SQLEvent{Offset: &events.Offset{Source: "2"}}
+	source <- event
+	<-sink
+	// replay of first event
+	event.Payload = events.SQLEvent{Offset: &events.Offset{Source: "1"}}
+	source <- event
+	multiplexer.Stop(context.Background())
+
+	if len(sink) != 0 {
+		t.Error("duplicated event sent")
+	}
+	if _, dropped := dedup.counters(); dropped != 1 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,409 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/juju/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Pirionfr/lookatch-agent/sinks"
+	"github.com/Pirionfr/lookatch-agent/sources"
+)
+
+// componentConfig wanted state of a source or a sink
+type componentConfig struct {
+	componentType string
+	snapshot      string
+	linkedSinks   []string
+}
+
+// Reload read configuration again and apply it to running agent
+// in connected mode configuration from controller is merged on top of config file,
+// then configuration set by tasks
+func (a *Agent) Reload() (err error) {
+	a.reloadMutex.Lock()
+	defer a.reloadMutex.Unlock()
+
+	if a.config.ConfigFileUsed() != "" {
+		err = a.config.ReadInConfig()
+		if err != nil {
+			return errors.Annotate(err, "error while reading config file")
+		}
+	}
+	return a.mergeAndApplyConfig()
+}
+
+// WatchConfig reload agent each time config file is written
+func (a *Agent) WatchConfig() {
+	if a.config.ConfigFileUsed() == "" {
+		log.Warn("No config file to watch")
+		return
+	}
+	a.config.OnConfigChange(a.onConfigChange)
+	a.config.WatchConfig()
+}
+
+// onConfigChange apply config file read again by viper, as Reload does
+func (a *Agent) onConfigChange(e fsnotify.Event) {
+	log.WithField("file", e.Name).Info("Config file changed")
+	a.reloadMutex.Lock()
+	defer a.reloadMutex.Unlock()
+	if err := a.mergeAndApplyConfig(); err != nil {
+		log.WithError(err).Error("Error while reloading configuration")
+	}
+}
+
+// mergeAndApplyConfig merge configuration from controller on top of config file just read, then apply it
+// components added, removed or linked by tasks are merged last
+// caller must hold reloadMutex
+func (a *Agent) mergeAndApplyConfig() error {
+	if a.controller != nil {
+		binconf, err := a.controller.GetConfiguration()
+		if err != nil {
+			return errors.Annotate(err, "error while getting configuration")
+		}
+		err = a.config.MergeConfig(bytes.NewReader(binconf))
+		if err != nil {
+			return errors.Annotate(err, "error while merging configuration")
+		}
+	}
+	if err := a.mergeTaskConfig(); err != nil {
+		return err
+	}
+	return a.applyConfig()
+}
+
+// applyConfig compare sources and sinks configuration with running ones
+// only components whose configuration changed are stopped, created or restarted
+// sources left untouched keep streaming, only their links to sinks are updated
+// caller must hold reloadMutex
+func (a *Agent) applyConfig() (err error) {
+	if a.status != AgentStatusOnline {
+		return errors.Errorf("agent is not online, status is '%s'", a.status)
+	}
+
+	wantedSinks, wantedSources, err := a.wantedConfig()
+	if err != nil {
+		return errors.Annotate(err, "invalid configuration")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.ShutdownTimeout())
+	defer cancel()
+
+	a.srcMutex.RLock()
+	currentSources := copyConfigs(a.sourceConfigs)
+	a.srcMutex.RUnlock()
+	a.sinksMutex.RLock()
+	currentSinks := copyConfigs(a.sinkConfigs)
+	a.sinksMutex.RUnlock()
+
+	// create and start new or changed sinks first, nothing is modified on failure
+	created := make(map[string]sinks.SinkI)
+	for _, sinkName := range sortedNames(wantedSinks) {
+		wanted := wantedSinks[sinkName]
+		if snapshot, ok := currentSinks[sinkName]; ok && snapshot == wanted.snapshot {
+			continue
+		}
+		var aSink sinks.SinkI
+		aSink, err = sinks.New(sinkName, wanted.componentType, a.config, a.stopper)
+		if err == nil {
+			aSink.SetSchemaProvider(a.getSourceSchema)
+			err = aSink.Start()
+		}
+		if err != nil {
+			for _, s := range created {
+				s.Stop()
+			}
+			return errors.Annotatef(err, "error while creating sink '%s'", sinkName)
+		}
+		created[sinkName] = aSink
+	}
+
+	// rejected events are sent to new sinks before old ones are stopped
+	deadLetterSinks := a.getSinks()
+	for sinkName, aSink := range created {
+		deadLetterSinks[sinkName] = aSink
+	}
+	a.linkDeadLetters(deadLetterSinks)
+
+	// stop removed and changed sources
+	for sourceName, snapshot := range currentSources {
+		if wanted, ok := wantedSources[sourceName]; ok && wanted.snapshot == snapshot {
+			continue
+		}
+		log.WithField("source", sourceName).Info("Removing source")
+		a.removeSource(ctx, sourceName)
+	}
+
+	// update links of sources left running
+	replaced := make(map[string][]string)
+	for sourceName, multiplexer := range a.getMultiplexers() {
+		demux, _ := a.getDeMultiplexer(sourceName)
+		a.relink(sourceName, multiplexer, demux, wantedSources[sourceName].linkedSinks, created, replaced)
+	}
+
+	// swap changed sinks once old ones have flushed their events
+	for sinkName, sourceNames := range replaced {
+		old, _ := a.getSink(sinkName)
+		log.WithField("sink", sinkName).Info("Replacing sink")
+		a.stopSink(ctx, sinkName, old)
+		if errWait := waitUntil(ctx, func() bool { return a.pendingCommits(old) == 0 }); errWait != nil {
+			log.WithError(errWait).WithField("sink", sinkName).Error("Error while waiting for commits")
+		}
+		for _, sourceName := range sourceNames {
+			if demux, ok := a.getDeMultiplexer(sourceName); ok {
+				demux.AddSink(sinkName, a.commitChan(sourceName, created[sinkName]))
+			}
+		}
+		a.stopCommitRouter(old)
+	}
+
+	// stop removed sinks and changed sinks no longer linked
+	for sinkName := range currentSinks {
+		_, isWanted := wantedSinks[sinkName]
+		_, isCreated := created[sinkName]
+		_, isReplaced := replaced[sinkName]
+		if isWanted && !isCreated || isReplaced {
+			continue
+		}
+		old, _ := a.getSink(sinkName)
+		log.WithField("sink", sinkName).Info("Removing sink")
+		a.stopSink(ctx, sinkName, old)
+		a.deleteSink(sinkName)
+	}
+
+	for sinkName, aSink := range created {
+		a.setSink(sinkName, aSink)
+		a.setSinkConfig(sinkName, wantedSinks[sinkName].snapshot)
+	}
+
+	// create new and changed sources
+	for _, sourceName := range sortedNames(wantedSources) {
+		if _, ok := a.getSource(sourceName); ok {
+			continue
+		}
+		log.WithField("source", sourceName).Info("Adding source")
+		err = a.addSource(sourceName, wantedSources[sourceName])
+		if err != nil {
+			return errors.Annotatef(err, "error while adding source '%s'", sourceName)
+		}
+	}
+
+	log.Info("Configuration reloaded")
+	return nil
+}
+
+// wantedConfig read enabled sinks and sources from configuration
+// return an error if a type is unknown or a source is linked to a missing sink
+func (a *Agent) wantedConfig() (map[string]*componentConfig, map[string]*componentConfig, error) {
+	wantedSinks := make(map[string]*componentConfig)
+	for sinkName := range a.config.GetStringMap("sinks") {
+		if !a.config.GetBool("sinks." + sinkName + ".enabled") {
+			continue
+		}
+		sinkType := a.config.GetString("sinks." + sinkName + ".type")
+		if _, found := sinks.Factory[sinkType]; !found {
+			return nil, nil, errors.Errorf("sink type not found for '%s'", sinkName)
+		}
+		wantedSinks[sinkName] = &componentConfig{
+			componentType: sinkType,
+			snapshot:      a.configSnapshot("sinks", sinkName),
+		}
+	}
+
+	wantedSources := make(map[string]*componentConfig)
+	for srcName := range a.config.GetStringMap("sources") {
+		if !a.config.GetBool("sources." + srcName + ".enabled") {
+			continue
+		}
+		sourceType := a.config.GetString("sources." + srcName + ".type")
+		if _, found := sources.Factory[sourceType]; !found {
+			return nil, nil, errors.Errorf("source type not found for '%s'", srcName)
+		}
+		linkedSinks := a.config.GetStringSlice("sources." + srcName + ".linked_sinks")
+		if linkedSinks == nil {
+			return nil, nil, errors.Errorf("Linked sinks not set for '%s'", srcName)
+		}
+		for _, sinkName := range linkedSinks {
+			if _, found := wantedSinks[sinkName]; !found {
+				return nil, nil, errors.Errorf("sink name '%s' not found for '%s'", sinkName, srcName)
+			}
+		}
+		if _, err := a.getPipeline(srcName); err != nil {
+			return nil, nil, errors.Annotatef(err, "invalid processors for '%s'", srcName)
+		}
+		if _, err := a.getDedupConfig(srcName); err != nil {
+			return nil, nil, errors.Annotatef(err, "invalid deduplication for '%s'", srcName)
+		}
+		if _, err := a.getEnricher(srcName); err != nil {
+			return nil, nil, errors.Annotatef(err, "invalid metadata for '%s'", srcName)
+		}
+		wantedSources[srcName] = &componentConfig{
+			componentType: sourceType,
+			snapshot:      a.configSnapshot("sources", srcName),
+			linkedSinks:   linkedSinks,
+		}
+	}
+
+	if len(wantedSinks) == 0 {
+		return nil, nil, errors.New("No sinks found")
+	}
+	sinkNames := make(map[string]bool, len(wantedSinks))
+	for sinkName := range wantedSinks {
+		sinkNames[sinkName] = true
+	}
+	if err := a.checkDeadLetters(sinkNames); err != nil {
+		return nil, nil, err
+	}
+	if len(wantedSources) == 0 {
+		return nil, nil, errors.New("No sources found")
+	}
+	return wantedSinks, wantedSources, nil
+}
+
+// configSnapshot return a comparable representation of a component configuration
+// linked sinks, links and dead letter are excluded as they are updated without restarting components
+func (a *Agent) configSnapshot(kind string, name string) string {
+	conf := make(map[string]interface{})
+	for k, v := range a.config.GetStringMap(kind + "." + name) {
+		if k != "linked_sinks" && k != "links" && k != "dead_letter" {
+			conf[k] = v
+		}
+	}
+	return fmt.Sprint(conf)
+}
+
+// relink update sinks linked to a running source
+// changed sinks receive next events, their commits are switched once old sink is stopped
+func (a *Agent) relink(sourceName string, multiplexer *Multiplexer, demux *DeMultiplexer, linkedSinks []string, created map[string]sinks.SinkI, replaced map[string][]string) {
+	wanted := make(map[string]bool, len(linkedSinks))
+	for _, sinkName := range linkedSinks {
+		wanted[sinkName] = true
+	}
+
+	current := make(map[string]bool)
+	for _, sinkName := range multiplexer.GetSinks() {
+		current[sinkName] = true
+		if !wanted[sinkName] {
+			log.WithFields(log.Fields{
+				"sourceName": sourceName,
+				"sinkName":   sinkName,
+			}).Debug("remove link")
+			multiplexer.RemoveSink(sinkName)
+			demux.RemoveSink(sinkName)
+			if aSink, ok := a.getSink(sinkName); ok {
+				a.removeCommitChan(sourceName, aSink)
+			}
+		}
+	}
+
+	for _, sinkName := range linkedSinks {
+		aSink, isCreated := created[sinkName]
+		if !isCreated {
+			aSink, _ = a.getSink(sinkName)
+			if current[sinkName] {
+				// queue configuration may have changed
+				multiplexer.AddSink(sinkName, aSink.GetInputChan(), a.getLinkConfig(sourceName, sinkName))
+				continue
+			}
+		}
+		if current[sinkName] {
+			replaced[sinkName] = append(replaced[sinkName], sourceName)
+		} else {
+			demux.AddSink(sinkName, a.commitChan(sourceName, aSink))
+		}
+		log.WithFields(log.Fields{
+			"sourceName": sourceName,
+			"sinkName":   sinkName,
+		}).Debug("create link")
+		multiplexer.AddSink(sinkName, aSink.GetInputChan(), a.getLinkConfig(sourceName, sinkName))
+	}
+}
+
+// removeSource stop source and its multiplexers
+// checkpoint is saved so a recreated source resumes from it
+func (a *Agent) removeSource(ctx context.Context, sourceName string) {
+	source, ok := a.getSource(sourceName)
+	if !ok {
+		return
+	}
+	if err := stopWithContext(ctx, source.Stop); err != nil {
+		log.WithError(err).WithField("source", sourceName).Error("Error while stopping source")
+	}
+	if multiplexer, ok := a.getMultiplexer(sourceName); ok {
+		if err := multiplexer.Stop(ctx); err != nil {
+			log.WithError(err).WithField("source", sourceName).Error("Error while draining multiplexer")
+		}
+	}
+	if demux, ok := a.getDeMultiplexer(sourceName); ok {
+		if err := demux.Drain(ctx); err != nil {
+			log.WithError(err).WithField("source", sourceName).Error("Error while waiting for commits")
+		}
+		demux.Stop()
+	}
+	for _, aSink := range a.getSinks() {
+		a.removeCommitChan(sourceName, aSink)
+	}
+	if a.offsetStore != nil {
+		if err := a.offsetStore.Save(sourceName, source.GetCheckpoint()); err != nil {
+			log.WithError(err).WithField("source", sourceName).Error("Error while saving checkpoint")
+		}
+	}
+	a.deleteSource(sourceName)
+}
+
+// addSource create source, link it to its sinks and start it
+func (a *Agent) addSource(sourceName string, wanted *componentConfig) error {
+	err := a.LoadSource(sourceName, wanted.componentType)
+	if err != nil {
+		return err
+	}
+	links := map[string][]string{sourceName: wanted.linkedSinks}
+	err = a.LoadDeMultiplexer(&links)
+	if err != nil {
+		return err
+	}
+	err = a.LoadMultiplexer(&links)
+	if err != nil {
+		return err
+	}
+	source, _ := a.getSource(sourceName)
+	return source.Start()
+}
+
+// stopSink wait for sink to consume pending events then stop it
+func (a *Agent) stopSink(ctx context.Context, sinkName string, sink sinks.SinkI) {
+	in := sink.GetInputChan()
+	err := waitUntil(ctx, func() bool {
+		return len(in) == 0
+	})
+	if err == nil {
+		err = stopWithContext(ctx, sink.Stop)
+	}
+	if err != nil {
+		log.WithError(err).WithField("sink", sinkName).Error("Error while stopping sink")
+	}
+}
+
+// copyConfigs return a copy of config snapshots
+func copyConfigs(configs map[string]string) map[string]string {
+	c := make(map[string]string, len(configs))
+	for k, v := range configs {
+		c[k] = v
+	}
+	return c
+}
+
+// sortedNames return sorted component names
+func sortedNames(configs map[string]*componentConfig) []string {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
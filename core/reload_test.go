@@ -0,0 +1,184 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+const (
+	ReloadJSON        = `{"sinks":{"default":{"enabled":true,"type":"Stdout"}},"sources":{"default":{"enabled":true,"type":"Random","linked_sinks":["default"],"wait":"1s"}}}`
+	ReloadLinkJSON    = `{"sinks":{"default":{"enabled":true,"type":"Stdout"},"other":{"enabled":true,"type":"Stdout"}},"sources":{"default":{"enabled":true,"type":"Random","linked_sinks":["default","other"],"wait":"1s"}}}`
+	ReloadSourceJSON  = `{"sinks":{"default":{"enabled":true,"type":"Stdout"}},"sources":{"default":{"enabled":true,"type":"Random","linked_sinks":["default"],"wait":"2s"}}}`
+	ReloadInvalidJSON = `{"sinks":{"default":{"enabled":true,"type":"Stdout"}},"sources":{"default":{"enabled":true,"type":"Random","linked_sinks":["missing"],"wait":"1s"}}}`
+)
+
+func newReloadAgent(t *testing.T) *Agent {
+	config := viper.New()
+	config.SetConfigType("json")
+	err := config.ReadConfig(bytes.NewBufferString(ReloadJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := newAgent(config, make(chan error))
+	err = agent.InitAgent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = agent.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.status = AgentStatusOnline
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		agent.Stop(ctx)
+	})
+	return agent
+}
+
+func reload(t *testing.T, agent *Agent, conf string) error {
+	err := agent.config.ReadConfig(bytes.NewBufferString(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.reloadMutex.Lock()
+	defer agent.reloadMutex.Unlock()
+	return agent.applyConfig()
+}
+
+func TestReloadLinkSink(t *testing.T) {
+	agent := newReloadAgent(t)
+	source, _ := agent.getSource("default")
+	sink, _ := agent.getSink("default")
+
+	err := reload(t, agent, ReloadLinkJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ := agent.getSource("default"); s != source {
+		t.Error("source restarted")
+	}
+	if s, _ := agent.getSink("default"); s != sink {
+		t.Error("sink restarted")
+	}
+	if _, ok := agent.getSink("other"); !ok {
+		t.Error("sink not created")
+	}
+	multiplexer, _ := agent.getMultiplexer("default")
+	if len(multiplexer.GetSinks()) != 2 {
+		t.Error("sink not linked")
+	}
+
+	err = reload(t, agent, ReloadJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := agent.getSink("other"); ok {
+		t.Error("sink not removed")
+	}
+	if len(multiplexer.GetSinks()) != 1 {
+		t.Error("sink not unlinked")
+	}
+}
+
+func TestReloadChangedSource(t *testing.T) {
+	agent := newReloadAgent(t)
+	source, _ := agent.getSource("default")
+	sink, _ := agent.getSink("default")
+
+	err := reload(t, agent, ReloadSourceJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ := agent.getSource("default"); s == source {
+		t.Error("source not restarted")
+	}
+	if s, _ := agent.getSink("default"); s != sink {
+		t.Error("sink restarted")
+	}
+	if _, ok := agent.getMultiplexer("default"); !ok {
+		t.Error("multiplexer not created")
+	}
+}
+
+func TestReloadInvalidConfig(t *testing.T) {
+	agent := newReloadAgent(t)
+	source, _ := agent.getSource("default")
+
+	err := reload(t, agent, ReloadInvalidJSON)
+	if err == nil {
+		t.Error("invalid configuration applied")
+	}
+	if s, _ := agent.getSource("default"); s != source {
+		t.Error("source restarted")
+	}
+}
+
+func TestWatchConfigMergeController(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, `{"sinks":{"remote":{"enabled":true,"type":"Stdout"}}}`)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(ReloadJSON), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := viper.New()
+	config.SetConfigFile(path)
+	err = config.ReadInConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vRemote := viper.New()
+	vRemote.Set("base_url", server.URL)
+
+	agent := newAgent(config, make(chan error))
+	agent.controller = NewControllerClient(vRemote, &Auth{uuid: UUID, token: token})
+	agent.status = AgentStatusOnline
+	err = agent.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		agent.Stop(ctx)
+	})
+	if _, ok := agent.getSink("remote"); !ok {
+		t.Fatal("controller sink not created")
+	}
+
+	// viper reads config file again before calling onConfigChange
+	err = os.WriteFile(path, []byte(ReloadLinkJSON), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = config.ReadInConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.onConfigChange(fsnotify.Event{Name: path})
+	if _, ok := agent.getSink("other"); !ok {
+		t.Error("config file sink not created")
+	}
+	if _, ok := agent.getSink("remote"); !ok {
+		t.Error("controller sink removed")
+	}
+}
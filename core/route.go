@@ -0,0 +1,66 @@
+package core
+
+import (
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+// RouteRule match events on their header and payload
+// an event matches if every set field contains its value, unset fields match any value
+type RouteRule struct {
+	Database  []string `json:"database" mapstructure:"database"`
+	Schema    []string `json:"schema" mapstructure:"schema"`
+	Table     []string `json:"table" mapstructure:"table"`
+	Method    []string `json:"method" mapstructure:"method"`
+	EventType []string `json:"event_type" mapstructure:"event_type"`
+}
+
+// match return true if event matches rule
+// rules on database, schema, table or method only match sql events
+func (r RouteRule) match(event events.LookatchEvent) bool {
+	if !matchValue(r.EventType, event.Header.EventType) {
+		return false
+	}
+	if len(r.Database) == 0 && len(r.Schema) == 0 && len(r.Table) == 0 && len(r.Method) == 0 {
+		return true
+	}
+	sqlEvent, ok := event.Payload.(events.SQLEvent)
+	if !ok {
+		return false
+	}
+	return matchValue(r.Database, sqlEvent.Database) &&
+		matchValue(r.Schema, sqlEvent.Schema) &&
+		matchValue(r.Table, sqlEvent.Table) &&
+		matchValue(r.Method, sqlEvent.Method)
+}
+
+// matchValue return true if values is empty or contains value
+func matchValue(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// routeEvent return true if event must be sent on link
+// event is sent if it matches one of routes, or if routes are empty, and none of excluded routes
+func routeEvent(routes []RouteRule, excludes []RouteRule, event events.LookatchEvent) bool {
+	for _, rule := range excludes {
+		if rule.match(event) {
+			return false
+		}
+	}
+	if len(routes) == 0 {
+		return true
+	}
+	for _, rule := range routes {
+		if rule.match(event) {
+			return true
+		}
+	}
+	return false
+}
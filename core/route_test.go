@@ -0,0 +1,69 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+func TestRouteRuleMatch(t *testing.T) {
+	event := events.LookatchEvent{
+		Header: events.LookatchHeader{EventType: "MysqlCDC"},
+		Payload: events.SQLEvent{
+			Database: "billing",
+			Table:    "invoice",
+			Method:   "insert",
+		},
+	}
+
+	if !(RouteRule{}).match(event) {
+		t.Error("empty rule must match")
+	}
+	if !(RouteRule{Database: []string{"billing"}, Table: []string{"payment", "invoice"}}).match(event) {
+		t.Error("rule must match")
+	}
+	if (RouteRule{Database: []string{"billing"}, Method: []string{"delete"}}).match(event) {
+		t.Error("rule must not match method")
+	}
+	if (RouteRule{EventType: []string{"Random"}}).match(event) {
+		t.Error("rule must not match event type")
+	}
+	if (RouteRule{Table: []string{"invoice"}}).match(events.LookatchEvent{Payload: events.GenericEvent{}}) {
+		t.Error("table rule must not match generic event")
+	}
+}
+
+func TestRouteEvent(t *testing.T) {
+	billing := []RouteRule{{Database: []string{"billing"}}}
+	event := events.LookatchEvent{Payload: events.SQLEvent{Database: "billing"}}
+	other := events.LookatchEvent{Payload: events.SQLEvent{Database: "shop"}}
+
+	if !routeEvent(nil, nil, event) {
+		t.Error("event must be sent without routes")
+	}
+	if !routeEvent(billing, nil, event) || routeEvent(billing, nil, other) {
+		t.Error("routes not applied")
+	}
+	if routeEvent(nil, billing, event) || !routeEvent(nil, billing, other) {
+		t.Error("excluded routes not applied")
+	}
+}
+
+func TestGetLinkConfigRoutes(t *testing.T) {
+	config := viper.New()
+	config.Set("sources.default.links.billing.routes", []map[string]interface{}{
+		{"database": "billing", "table": []string{"invoice", "payment"}},
+	})
+	agent := newAgent(config, make(chan error))
+
+	linkConfig := agent.getLinkConfig("default", "billing")
+	if len(linkConfig.Routes) != 1 {
+		t.Fatal("routes not read")
+	}
+	rule := linkConfig.Routes[0]
+	if len(rule.Database) != 1 || rule.Database[0] != "billing" || len(rule.Table) != 2 {
+		t.Error("route not decoded")
+	}
+}
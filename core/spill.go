@@ -0,0 +1,338 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+// spillSegmentExt extension of spill segment files
+const spillSegmentExt = ".seg"
+
+// DefaultSpillSegmentSize default size of a spill segment file in bytes
+const DefaultSpillSegmentSize = 64 * 1024 * 1024
+
+// DefaultSpillMaxSize default size cap of spilled events of a link in bytes
+const DefaultSpillMaxSize = 1024 * 1024 * 1024
+
+// errSpillFull returned when size cap of spill queue is reached
+var errSpillFull = errors.New("spill queue is full")
+
+type (
+	// spillQueue fifo of events written to segment files
+	// a segment is removed once all its events have been read
+	spillQueue struct {
+		dir         string
+		segmentSize int64
+		maxSize     int64
+		segments    []*spillSegment
+		writer      *bufio.Writer
+		reader      *bufio.Reader
+		size        int64
+		length      int
+	}
+
+	// spillSegment file holding a part of spilled events, one encoded event per line
+	spillSegment struct {
+		id     int64
+		path   string
+		file   *os.File
+		input  *os.File
+		size   int64
+		length int
+	}
+)
+
+// newSpillQueue open spill queue in dir
+// events left by a previous run are loaded, they are read first unless queue is cleared
+func newSpillQueue(dir string, segmentSize int64, maxSize int64) (*spillQueue, error) {
+	if segmentSize <= 0 {
+		segmentSize = DefaultSpillSegmentSize
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultSpillMaxSize
+	}
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		return nil, errors.Annotate(err, "error while creating spill directory")
+	}
+
+	s := &spillQueue{
+		dir:         dir,
+		segmentSize: segmentSize,
+		maxSize:     maxSize,
+	}
+	err = s.load()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load read existing segments of spill directory
+func (s *spillQueue) load() error {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return errors.Annotate(err, "error while reading spill directory")
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), spillSegmentExt) {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSuffix(file.Name(), spillSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segment := &spillSegment{
+			id:   id,
+			path: filepath.Join(s.dir, file.Name()),
+			size: file.Size(),
+		}
+		segment.length, err = countLines(segment.path)
+		if err != nil {
+			return errors.Annotatef(err, "error while reading spill segment '%s'", segment.path)
+		}
+		if segment.length == 0 {
+			os.Remove(segment.path)
+			continue
+		}
+		s.segments = append(s.segments, segment)
+		s.size += segment.size
+		s.length += segment.length
+	}
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i].id < s.segments[j].id
+	})
+	return nil
+}
+
+// countLines return number of events in a segment file
+// a partially written last event is counted
+func countLines(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	count := 0
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			count++
+		}
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+}
+
+// Len return number of events in queue
+func (s *spillQueue) Len() int {
+	return s.length
+}
+
+// Size return size of segment files in bytes
+func (s *spillQueue) Size() int64 {
+	return s.size
+}
+
+// Push append event to last segment, a new segment is created when it is full
+// return errSpillFull if size cap is reached
+func (s *spillQueue) Push(event events.LookatchEvent) error {
+	data, err := events.MarshalEvent(event)
+	if err != nil {
+		return errors.Annotate(err, "error while encoding event")
+	}
+	data = append(data, '\n')
+	if s.size+int64(len(data)) > s.maxSize && s.length > 0 {
+		return errSpillFull
+	}
+
+	segment := s.last()
+	if segment == nil || segment.file == nil || segment.size+int64(len(data)) > s.segmentSize && segment.length > 0 {
+		segment, err = s.roll()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := s.writer.Write(data)
+	if err != nil {
+		return errors.Annotate(err, "error while writing spill segment")
+	}
+	segment.size += int64(n)
+	segment.length++
+	s.size += int64(n)
+	s.length++
+	return nil
+}
+
+// Pop read oldest event of queue
+// segments are removed once read
+func (s *spillQueue) Pop() (event events.LookatchEvent, err error) {
+	if s.length == 0 {
+		return event, io.EOF
+	}
+	segment := s.segments[0]
+	if segment.file != nil {
+		if err = s.writer.Flush(); err != nil {
+			return event, errors.Annotate(err, "error while writing spill segment")
+		}
+	}
+	if segment.input == nil {
+		segment.input, err = os.Open(segment.path)
+		if err != nil {
+			return event, errors.Annotate(err, "error while opening spill segment")
+		}
+		s.reader = bufio.NewReader(segment.input)
+	}
+
+	line, err := s.reader.ReadBytes('\n')
+	if len(line) == 0 {
+		if err == nil || err == io.EOF {
+			err = errors.Errorf("spill segment '%s' is truncated", segment.path)
+		}
+		return event, errors.Annotate(err, "error while reading spill segment")
+	}
+	segment.length--
+	s.length--
+	if segment.length == 0 {
+		err = s.removeSegment(segment)
+		if err != nil {
+			return
+		}
+	}
+	return events.UnmarshalEvent(line)
+}
+
+// last return segment being written
+func (s *spillQueue) last() *spillSegment {
+	if len(s.segments) == 0 {
+		return nil
+	}
+	return s.segments[len(s.segments)-1]
+}
+
+// roll close segment being written and create a new one
+func (s *spillQueue) roll() (*spillSegment, error) {
+	var id int64
+	if last := s.last(); last != nil {
+		if last.file != nil {
+			if err := s.Sync(); err != nil {
+				return nil, err
+			}
+			last.file.Close()
+			last.file = nil
+		}
+		id = last.id + 1
+	}
+
+	segment := &spillSegment{
+		id:   id,
+		path: filepath.Join(s.dir, fmt.Sprintf("%020d%s", id, spillSegmentExt)),
+	}
+	file, err := os.OpenFile(segment.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return nil, errors.Annotate(err, "error while creating spill segment")
+	}
+	segment.file = file
+	s.writer = bufio.NewWriter(file)
+	s.segments = append(s.segments, segment)
+	return segment, nil
+}
+
+// removeSegment close and remove oldest segment
+func (s *spillQueue) removeSegment(segment *spillSegment) error {
+	segment.close()
+	s.segments = s.segments[1:]
+	s.size -= segment.size
+	s.reader = nil
+	if err := os.Remove(segment.path); err != nil {
+		return errors.Annotate(err, "error while removing spill segment")
+	}
+	return nil
+}
+
+// Flush write buffered events to segment file
+func (s *spillQueue) Flush() error {
+	if last := s.last(); last != nil && last.file != nil {
+		return s.writer.Flush()
+	}
+	return nil
+}
+
+// Sync write buffered events to segment file and commit it to disk
+func (s *spillQueue) Sync() error {
+	last := s.last()
+	if last == nil || last.file == nil {
+		return nil
+	}
+	if err := s.writer.Flush(); err != nil {
+		return errors.Annotate(err, "error while writing spill segment")
+	}
+	if err := last.file.Sync(); err != nil {
+		return errors.Annotate(err, "error while syncing spill segment")
+	}
+	return nil
+}
+
+// Close sync and close segment files, remaining events are kept on disk
+func (s *spillQueue) Close() (err error) {
+	err = s.Sync()
+	for _, segment := range s.segments {
+		segment.close()
+	}
+	return
+}
+
+// clear close queue and remove all its segments, spill directory is kept
+func (s *spillQueue) clear() error {
+	for _, segment := range s.segments {
+		segment.close()
+		if err := os.Remove(segment.path); err != nil && !os.IsNotExist(err) {
+			return errors.Annotate(err, "error while removing spill segment")
+		}
+	}
+	s.segments = nil
+	s.writer = nil
+	s.reader = nil
+	s.size = 0
+	s.length = 0
+	return nil
+}
+
+// Remove close queue and remove all its segments
+func (s *spillQueue) Remove() error {
+	s.Close()
+	s.segments = nil
+	s.size = 0
+	s.length = 0
+	return os.RemoveAll(s.dir)
+}
+
+// close close files of segment
+func (s *spillSegment) close() {
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	if s.input != nil {
+		s.input.Close()
+		s.input = nil
+	}
+}
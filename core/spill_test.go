@@ -0,0 +1,144 @@
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+func spilledValue(t *testing.T, spill *spillQueue) string {
+	event, err := spill.Pop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event.Payload.(events.GenericEvent).Value.(json.Number).String()
+}
+
+func TestSpillQueue(t *testing.T) {
+	dir := t.TempDir()
+	spill, err := newSpillQueue(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		err = spill.Push(newTestEvent(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if spill.Len() != 3 {
+		t.Fail()
+	}
+
+	for i := 1; i <= 3; i++ {
+		if value := spilledValue(t, spill); value != strconv.Itoa(i) {
+			t.Errorf("expected %d, got %s", i, value)
+		}
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 0 || spill.Size() != 0 {
+		t.Error("segment not removed")
+	}
+
+	err = spill.Remove()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSpillQueueSegments(t *testing.T) {
+	dir := t.TempDir()
+	spill, err := newSpillQueue(dir, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 10; i++ {
+		err = spill.Push(newTestEvent(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) < 2 {
+		t.Errorf("expected several segments, got %d", len(files))
+	}
+
+	for i := 1; i <= 10; i++ {
+		if value := spilledValue(t, spill); value != strconv.Itoa(i) {
+			t.Errorf("expected %d, got %s", i, value)
+		}
+	}
+	files, _ = ioutil.ReadDir(dir)
+	if len(files) != 0 {
+		t.Error("segments not removed")
+	}
+}
+
+func TestSpillQueueMaxSize(t *testing.T) {
+	spill, err := newSpillQueue(t.TempDir(), 0, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for err == nil {
+		err = spill.Push(newTestEvent(1))
+	}
+	if err != errSpillFull {
+		t.Error(err)
+	}
+	if spill.Size() > 200 {
+		t.Errorf("size cap exceeded: %d", spill.Size())
+	}
+
+	for spill.Len() > 0 {
+		spilledValue(t, spill)
+	}
+	if err = spill.Push(newTestEvent(1)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSpillQueueReload(t *testing.T) {
+	dir := t.TempDir()
+	spill, err := newSpillQueue(dir, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		err = spill.Push(newTestEvent(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	spilledValue(t, spill)
+	err = spill.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spill, err = newSpillQueue(dir, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// events of a partially read segment are replayed
+	if spill.Len() < 4 {
+		t.Errorf("expected at least 4 events, got %d", spill.Len())
+	}
+	err = spill.Push(newTestEvent(6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := ""
+	for spill.Len() > 0 {
+		last = spilledValue(t, spill)
+	}
+	if last != "6" {
+		t.Errorf("expected 6, got %s", last)
+	}
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Payload types of encoded events
+const (
+	SQLEventType     = "sql"
+	GenericEventType = "generic"
+)
+
+// encodedEvent representation of a LookatchEvent keeping its payload type
+type encodedEvent struct {
+	Header      LookatchHeader  `json:"header"`
+	PayloadType string          `json:"payload_type"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+// MarshalEvent encode event with its payload type so it can be decoded back
+func MarshalEvent(event LookatchEvent) ([]byte, error) {
+	encoded := encodedEvent{
+		Header: event.Header,
+	}
+	switch event.Payload.(type) {
+	case SQLEvent:
+		encoded.PayloadType = SQLEventType
+	case GenericEvent:
+		encoded.PayloadType = GenericEventType
+	default:
+		return nil, fmt.Errorf("unsupported payload type %T", event.Payload)
+	}
+
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return nil, err
+	}
+	encoded.Payload = payload
+	return json.Marshal(encoded)
+}
+
+// UnmarshalEvent decode event encoded with MarshalEvent
+// numbers are decoded as json.Number to keep their precision
+func UnmarshalEvent(data []byte) (event LookatchEvent, err error) {
+	encoded := encodedEvent{}
+	err = json.Unmarshal(data, &encoded)
+	if err != nil {
+		return
+	}
+	event.Header = encoded.Header
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded.Payload))
+	decoder.UseNumber()
+	switch encoded.PayloadType {
+	case SQLEventType:
+		payload := SQLEvent{}
+		err = decoder.Decode(&payload)
+		event.Payload = payload
+	case GenericEventType:
+		payload := GenericEvent{}
+		err = decoder.Decode(&payload)
+		event.Payload = payload
+	default:
+		err = fmt.Errorf("unsupported payload type '%s'", encoded.PayloadType)
+	}
+	return
+}
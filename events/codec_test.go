@@ -0,0 +1,46 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMarshalEvent(t *testing.T) {
+	event := LookatchEvent{
+		Header: LookatchHeader{
+			EventType: "sql",
+		},
+		Payload: SQLEvent{
+			Table:     "table",
+			Offset:    &Offset{Source: "1"},
+			Statement: map[string]interface{}{"id": int64(9007199254740993)},
+		},
+	}
+
+	data, err := MarshalEvent(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalEvent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, ok := decoded.Payload.(SQLEvent)
+	if !ok {
+		t.Fatalf("unexpected payload type %T", decoded.Payload)
+	}
+	if decoded.Header.EventType != "sql" || payload.Table != "table" || payload.Offset.Source != "1" {
+		t.Fail()
+	}
+	if payload.Statement["id"] != json.Number("9007199254740993") {
+		t.Errorf("number precision lost: %v", payload.Statement["id"])
+	}
+}
+
+func TestMarshalEventUnsupported(t *testing.T) {
+	_, err := MarshalEvent(LookatchEvent{Payload: "raw"})
+	if err == nil {
+		t.Fail()
+	}
+}
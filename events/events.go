@@ -27,6 +27,7 @@ type (
 		ColumnsMeta  map[string]ColumnsMeta `json:"columns_meta,omitempty"`
 		Statement    map[string]interface{} `json:"statement"`
 		OldStatement map[string]interface{} `json:"old_statement,omitempty"`
+		Metadata     *Metadata              `json:"metadata,omitempty"`
 	}
 
 	// GenericEvent events format
@@ -35,6 +36,29 @@ type (
 		Timestamp   string      `json:"timestamp"`
 		Offset      *Offset     `json:"offset,omitempty"`
 		Value       interface{} `json:"value"`
+		Metadata    *Metadata   `json:"metadata,omitempty"`
+	}
+
+	// Metadata agent and pipeline which produced an event
+	// only configured fields are set
+	Metadata struct {
+		AgentUUID       string `json:"agent_uuid,omitempty"`
+		Hostname        string `json:"hostname,omitempty"`
+		SourceName      string `json:"source_name,omitempty"`
+		SourceType      string `json:"source_type,omitempty"`
+		Sequence        uint64 `json:"sequence,omitempty"`
+		IngestTimestamp int64  `json:"ingest_timestamp,omitempty"`
+		RunID           string `json:"run_id,omitempty"`
+	}
+
+	// DeadLetterEvent event rejected by a sink
+	// original event is kept so it can be re-injected
+	DeadLetterEvent struct {
+		Reason    string        `json:"reason"`
+		Sink      string        `json:"sink"`
+		Timestamp int64         `json:"timestamp"`
+		Offset    *Offset       `json:"offset,omitempty"`
+		Event     LookatchEvent `json:"event"`
 	}
 
 	// LookatchTenantInfo tenant info header
@@ -44,9 +68,11 @@ type (
 	}
 
 	// LookatchHeader header format
+	// SourceName is set by the agent when the event leaves its source
 	LookatchHeader struct {
-		EventType string
-		Tenant    LookatchTenantInfo
+		EventType  string
+		Tenant     LookatchTenantInfo
+		SourceName string `json:"SourceName,omitempty"`
 	}
 
 	// LookatchEvent wire message format
@@ -64,3 +90,15 @@ type (
 		FullMsg   string
 	}
 )
+
+// GetOffset return the offset of the event payload, nil if the payload has no offset
+func (e *LookatchEvent) GetOffset() *Offset {
+	switch typedMsg := e.Payload.(type) {
+	case SQLEvent:
+		return typedMsg.Offset
+	case GenericEvent:
+		return typedMsg.Offset
+	default:
+		return nil
+	}
+}
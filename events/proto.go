@@ -0,0 +1,562 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ProtoVersion version of the protobuf wire format, messages are defined in proto/v1/lookatch.proto
+const ProtoVersion = "v1"
+
+// protoField field read from a protobuf message
+// bytes holds value of length delimited fields, number the value of varint and fixed fields
+type protoField struct {
+	num    protowire.Number
+	typ    protowire.Type
+	bytes  []byte
+	number uint64
+}
+
+// MarshalProto encode event in protobuf as a LookatchEvent message
+// values of statements and generic events are encoded as typed values
+func MarshalProto(event LookatchEvent) ([]byte, error) {
+	return appendEvent(nil, event)
+}
+
+// UnmarshalProto decode LookatchEvent message encoded with MarshalProto
+// integers are decoded as int64 or uint64, floats as float64, decimals as json.Number
+func UnmarshalProto(data []byte) (event LookatchEvent, err error) {
+	err = walkProto(data, func(f protoField) error {
+		switch f.num {
+		case 1:
+			return walkProto(f.bytes, func(f protoField) error {
+				return consumeHeader(f, &event.Header)
+			})
+		case 2:
+			payload := SQLEvent{}
+			err := walkProto(f.bytes, func(f protoField) error {
+				return consumeSQLEvent(f, &payload)
+			})
+			event.Payload = payload
+			return err
+		case 3:
+			payload := GenericEvent{}
+			err := walkProto(f.bytes, func(f protoField) error {
+				return consumeGenericEvent(f, &payload)
+			})
+			event.Payload = payload
+			return err
+		case 4:
+			payload := DeadLetterEvent{}
+			err := walkProto(f.bytes, func(f protoField) error {
+				return consumeDeadLetterEvent(f, &payload)
+			})
+			event.Payload = payload
+			return err
+		}
+		return nil
+	})
+	if err == nil && event.Payload == nil {
+		err = fmt.Errorf("event has no payload")
+	}
+	return
+}
+
+// appendEvent append LookatchEvent message to b
+func appendEvent(b []byte, event LookatchEvent) ([]byte, error) {
+	b = appendMessage(b, 1, appendHeader(nil, event.Header))
+	switch payload := event.Payload.(type) {
+	case SQLEvent:
+		m, err := appendSQLEvent(nil, payload)
+		if err != nil {
+			return nil, err
+		}
+		return appendMessage(b, 2, m), nil
+	case GenericEvent:
+		m, err := appendGenericEvent(nil, payload)
+		if err != nil {
+			return nil, err
+		}
+		return appendMessage(b, 3, m), nil
+	case DeadLetterEvent:
+		m, err := appendDeadLetterEvent(nil, payload)
+		if err != nil {
+			return nil, err
+		}
+		return appendMessage(b, 4, m), nil
+	default:
+		return nil, fmt.Errorf("unsupported payload type %T", event.Payload)
+	}
+}
+
+// appendHeader append LookatchHeader message to b
+func appendHeader(b []byte, header LookatchHeader) []byte {
+	b = appendString(b, 1, header.EventType)
+	if header.Tenant.ID != "" || header.Tenant.Env != "" {
+		tenant := appendString(nil, 1, header.Tenant.ID)
+		tenant = appendString(tenant, 2, header.Tenant.Env)
+		b = appendMessage(b, 2, tenant)
+	}
+	return appendString(b, 3, header.SourceName)
+}
+
+// appendSQLEvent append SQLEvent message to b
+func appendSQLEvent(b []byte, event SQLEvent) ([]byte, error) {
+	b = appendString(b, 1, event.Tenant)
+	b = appendString(b, 2, event.Environment)
+	b = appendString(b, 3, event.Timestamp)
+	b = appendString(b, 4, event.Database)
+	b = appendString(b, 5, event.Schema)
+	b = appendString(b, 6, event.Table)
+	b = appendString(b, 7, event.Method)
+	b = appendString(b, 8, event.PrimaryKey)
+	b = appendOffset(b, 9, event.Offset)
+	for _, name := range sortedKeys(event.ColumnsMeta) {
+		meta := appendString(nil, 1, event.ColumnsMeta[name].Type)
+		meta = appendVarint(meta, 2, uint64(int64(event.ColumnsMeta[name].Position)))
+		b = appendMessage(b, 10, appendMessage(appendString(nil, 1, name), 2, meta))
+	}
+	var err error
+	b, err = appendValues(b, 11, event.Statement)
+	if err != nil {
+		return nil, err
+	}
+	b, err = appendValues(b, 12, event.OldStatement)
+	if err != nil {
+		return nil, err
+	}
+	return appendMetadata(b, 13, event.Metadata), nil
+}
+
+// appendGenericEvent append GenericEvent message to b
+func appendGenericEvent(b []byte, event GenericEvent) ([]byte, error) {
+	b = appendString(b, 1, event.Environment)
+	b = appendString(b, 2, event.Timestamp)
+	b = appendOffset(b, 3, event.Offset)
+	value, err := appendValue(nil, event.Value)
+	if err != nil {
+		return nil, err
+	}
+	b = appendMessage(b, 4, value)
+	return appendMetadata(b, 5, event.Metadata), nil
+}
+
+// appendDeadLetterEvent append DeadLetterEvent message to b
+func appendDeadLetterEvent(b []byte, event DeadLetterEvent) ([]byte, error) {
+	b = appendString(b, 1, event.Reason)
+	b = appendString(b, 2, event.Sink)
+	b = appendVarint(b, 3, uint64(event.Timestamp))
+	b = appendOffset(b, 4, event.Offset)
+	m, err := appendEvent(nil, event.Event)
+	if err != nil {
+		return nil, err
+	}
+	return appendMessage(b, 5, m), nil
+}
+
+// appendOffset append Offset message to b, nothing if offset is nil
+func appendOffset(b []byte, num protowire.Number, offset *Offset) []byte {
+	if offset == nil {
+		return b
+	}
+	m := appendString(nil, 1, offset.Source)
+	m = appendString(m, 2, offset.Agent)
+	return appendMessage(b, num, m)
+}
+
+// appendMetadata append Metadata message to b, nothing if metadata is nil
+func appendMetadata(b []byte, num protowire.Number, metadata *Metadata) []byte {
+	if metadata == nil {
+		return b
+	}
+	m := appendString(nil, 1, metadata.AgentUUID)
+	m = appendString(m, 2, metadata.Hostname)
+	m = appendString(m, 3, metadata.SourceName)
+	m = appendString(m, 4, metadata.SourceType)
+	m = appendVarint(m, 5, metadata.Sequence)
+	m = appendVarint(m, 6, uint64(metadata.IngestTimestamp))
+	m = appendString(m, 7, metadata.RunID)
+	return appendMessage(b, num, m)
+}
+
+// appendValues append map of values to b, one entry per key
+func appendValues(b []byte, num protowire.Number, values map[string]interface{}) ([]byte, error) {
+	for _, key := range sortedKeys(values) {
+		value, err := appendValue(nil, values[key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of '%s': %w", key, err)
+		}
+		b = appendMessage(b, num, appendMessage(appendString(nil, 1, key), 2, value))
+	}
+	return b, nil
+}
+
+// appendValue append Value message of value to b
+func appendValue(b []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		return protowire.AppendVarint(b, 0), nil
+	case bool:
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(v)), nil
+	case int:
+		return appendInt(b, int64(v)), nil
+	case int8:
+		return appendInt(b, int64(v)), nil
+	case int16:
+		return appendInt(b, int64(v)), nil
+	case int32:
+		return appendInt(b, int64(v)), nil
+	case int64:
+		return appendInt(b, v), nil
+	case uint:
+		return appendUint(b, uint64(v)), nil
+	case uint8:
+		return appendUint(b, uint64(v)), nil
+	case uint16:
+		return appendUint(b, uint64(v)), nil
+	case uint32:
+		return appendUint(b, uint64(v)), nil
+	case uint64:
+		return appendUint(b, v), nil
+	case float32:
+		return appendDouble(b, float64(v)), nil
+	case float64:
+		return appendDouble(b, v), nil
+	case string:
+		b = protowire.AppendTag(b, 6, protowire.BytesType)
+		return protowire.AppendString(b, v), nil
+	case []byte:
+		b = protowire.AppendTag(b, 7, protowire.BytesType)
+		return protowire.AppendBytes(b, v), nil
+	case json.Number:
+		b = protowire.AppendTag(b, 8, protowire.BytesType)
+		return protowire.AppendString(b, string(v)), nil
+	case time.Time:
+		b = protowire.AppendTag(b, 6, protowire.BytesType)
+		return protowire.AppendString(b, v.Format(time.RFC3339Nano)), nil
+	case []interface{}:
+		var list []byte
+		for _, item := range v {
+			m, err := appendValue(nil, item)
+			if err != nil {
+				return nil, err
+			}
+			list = appendMessage(list, 1, m)
+		}
+		return appendMessage(b, 9, list), nil
+	case map[string]interface{}:
+		fields, err := appendValues(nil, 1, v)
+		if err != nil {
+			return nil, err
+		}
+		return appendMessage(b, 10, fields), nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+// appendInt append int_value of Value message to b
+func appendInt(b []byte, v int64) []byte {
+	b = protowire.AppendTag(b, 3, protowire.VarintType)
+	return protowire.AppendVarint(b, protowire.EncodeZigZag(v))
+}
+
+// appendUint append uint_value of Value message to b
+func appendUint(b []byte, v uint64) []byte {
+	b = protowire.AppendTag(b, 4, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// appendDouble append double_value of Value message to b
+func appendDouble(b []byte, v float64) []byte {
+	b = protowire.AppendTag(b, 5, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+// appendString append string field to b, nothing if it is empty
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// appendVarint append varint field to b, nothing if it is zero
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// appendMessage append embedded message field to b
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+// sortedKeys return keys of map sorted, so events are always encoded the same way
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// walkProto call f for each field of message b, unknown wire types are skipped
+func walkProto(b []byte, f func(protoField) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		field := protoField{num: num, typ: typ}
+		switch typ {
+		case protowire.BytesType:
+			field.bytes, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			field.number, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			field.number, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			field.number = uint64(v)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := f(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consumeHeader set field of LookatchHeader message
+func consumeHeader(f protoField, header *LookatchHeader) error {
+	switch f.num {
+	case 1:
+		header.EventType = string(f.bytes)
+	case 2:
+		return walkProto(f.bytes, func(f protoField) error {
+			switch f.num {
+			case 1:
+				header.Tenant.ID = string(f.bytes)
+			case 2:
+				header.Tenant.Env = string(f.bytes)
+			}
+			return nil
+		})
+	case 3:
+		header.SourceName = string(f.bytes)
+	}
+	return nil
+}
+
+// consumeSQLEvent set field of SQLEvent message
+func consumeSQLEvent(f protoField, event *SQLEvent) (err error) {
+	switch f.num {
+	case 1:
+		event.Tenant = string(f.bytes)
+	case 2:
+		event.Environment = string(f.bytes)
+	case 3:
+		event.Timestamp = string(f.bytes)
+	case 4:
+		event.Database = string(f.bytes)
+	case 5:
+		event.Schema = string(f.bytes)
+	case 6:
+		event.Table = string(f.bytes)
+	case 7:
+		event.Method = string(f.bytes)
+	case 8:
+		event.PrimaryKey = string(f.bytes)
+	case 9:
+		event.Offset, err = consumeOffset(f.bytes)
+	case 10:
+		var name string
+		meta := ColumnsMeta{}
+		err = walkProto(f.bytes, func(f protoField) error {
+			switch f.num {
+			case 1:
+				name = string(f.bytes)
+			case 2:
+				return walkProto(f.bytes, func(f protoField) error {
+					switch f.num {
+					case 1:
+						meta.Type = string(f.bytes)
+					case 2:
+						meta.Position = int(int32(f.number))
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+		if event.ColumnsMeta == nil {
+			event.ColumnsMeta = make(map[string]ColumnsMeta)
+		}
+		event.ColumnsMeta[name] = meta
+	case 11:
+		if event.Statement == nil {
+			event.Statement = make(map[string]interface{})
+		}
+		err = consumeEntry(f.bytes, event.Statement)
+	case 12:
+		if event.OldStatement == nil {
+			event.OldStatement = make(map[string]interface{})
+		}
+		err = consumeEntry(f.bytes, event.OldStatement)
+	case 13:
+		event.Metadata, err = consumeMetadata(f.bytes)
+	}
+	return
+}
+
+// consumeGenericEvent set field of GenericEvent message
+func consumeGenericEvent(f protoField, event *GenericEvent) (err error) {
+	switch f.num {
+	case 1:
+		event.Environment = string(f.bytes)
+	case 2:
+		event.Timestamp = string(f.bytes)
+	case 3:
+		event.Offset, err = consumeOffset(f.bytes)
+	case 4:
+		event.Value, err = consumeValue(f.bytes)
+	case 5:
+		event.Metadata, err = consumeMetadata(f.bytes)
+	}
+	return
+}
+
+// consumeDeadLetterEvent set field of DeadLetterEvent message
+func consumeDeadLetterEvent(f protoField, event *DeadLetterEvent) (err error) {
+	switch f.num {
+	case 1:
+		event.Reason = string(f.bytes)
+	case 2:
+		event.Sink = string(f.bytes)
+	case 3:
+		event.Timestamp = int64(f.number)
+	case 4:
+		event.Offset, err = consumeOffset(f.bytes)
+	case 5:
+		event.Event, err = UnmarshalProto(f.bytes)
+	}
+	return
+}
+
+// consumeOffset decode Offset message
+func consumeOffset(b []byte) (*Offset, error) {
+	offset := &Offset{}
+	err := walkProto(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			offset.Source = string(f.bytes)
+		case 2:
+			offset.Agent = string(f.bytes)
+		}
+		return nil
+	})
+	return offset, err
+}
+
+// consumeMetadata decode Metadata message
+func consumeMetadata(b []byte) (*Metadata, error) {
+	metadata := &Metadata{}
+	err := walkProto(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			metadata.AgentUUID = string(f.bytes)
+		case 2:
+			metadata.Hostname = string(f.bytes)
+		case 3:
+			metadata.SourceName = string(f.bytes)
+		case 4:
+			metadata.SourceType = string(f.bytes)
+		case 5:
+			metadata.Sequence = f.number
+		case 6:
+			metadata.IngestTimestamp = int64(f.number)
+		case 7:
+			metadata.RunID = string(f.bytes)
+		}
+		return nil
+	})
+	return metadata, err
+}
+
+// consumeEntry decode map entry of values into values
+func consumeEntry(b []byte, values map[string]interface{}) error {
+	var key string
+	var value interface{}
+	err := walkProto(b, func(f protoField) (err error) {
+		switch f.num {
+		case 1:
+			key = string(f.bytes)
+		case 2:
+			value, err = consumeValue(f.bytes)
+		}
+		return
+	})
+	values[key] = value
+	return err
+}
+
+// consumeValue decode Value message
+func consumeValue(b []byte) (value interface{}, err error) {
+	err = walkProto(b, func(f protoField) (err error) {
+		switch f.num {
+		case 1:
+			value = nil
+		case 2:
+			value = protowire.DecodeBool(f.number)
+		case 3:
+			value = protowire.DecodeZigZag(f.number)
+		case 4:
+			value = f.number
+		case 5:
+			value = math.Float64frombits(f.number)
+		case 6:
+			value = string(f.bytes)
+		case 7:
+			value = append([]byte{}, f.bytes...)
+		case 8:
+			value = json.Number(f.bytes)
+		case 9:
+			list := []interface{}{}
+			err = walkProto(f.bytes, func(f protoField) error {
+				item, err := consumeValue(f.bytes)
+				list = append(list, item)
+				return err
+			})
+			value = list
+		case 10:
+			fields := make(map[string]interface{})
+			err = walkProto(f.bytes, func(f protoField) error {
+				return consumeEntry(f.bytes, fields)
+			})
+			value = fields
+		}
+		return
+	})
+	return
+}
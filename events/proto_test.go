@@ -0,0 +1,342 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestMarshalProto(t *testing.T) {
+	event := LookatchEvent{
+		Header: LookatchHeader{
+			EventType:  "MysqlCDC",
+			Tenant:     LookatchTenantInfo{ID: "agent", Env: "prod"},
+			SourceName: "mysql",
+		},
+		Payload: SQLEvent{
+			Environment: "prod",
+			Timestamp:   "1600000000000000000",
+			Database:    "shop",
+			Table:       "orders",
+			Method:      "update",
+			PrimaryKey:  "id",
+			Offset:      &Offset{Source: "mysql-bin.000003:154:", Agent: "1"},
+			ColumnsMeta: map[string]ColumnsMeta{"id": {Type: "int", Position: 1}},
+			Statement: map[string]interface{}{
+				"id":       int64(-1),
+				"big":      uint64(18446744073709551615),
+				"amount":   json.Number("12.50"),
+				"rate":     0.5,
+				"paid":     true,
+				"note":     nil,
+				"blob":     []byte{0, 1},
+				"tags":     []interface{}{"a", int64(2)},
+				"document": map[string]interface{}{"key": "value"},
+			},
+			OldStatement: map[string]interface{}{"id": int64(-1)},
+			Metadata:     &Metadata{SourceName: "mysql", Sequence: 3, RunID: "run"},
+		},
+	}
+
+	data, err := MarshalProto(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalProto(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, event) {
+		t.Errorf("%#v", decoded)
+	}
+}
+
+func TestMarshalProtoWireFormat(t *testing.T) {
+	data, err := MarshalProto(LookatchEvent{
+		Header:  LookatchHeader{EventType: "a"},
+		Payload: GenericEvent{Value: int64(1)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header {event_type: "a"}, generic_event {value {int_value: 1}}
+	expected := []byte{0x0a, 0x03, 0x0a, 0x01, 'a', 0x1a, 0x04, 0x22, 0x02, 0x18, 0x02}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("% x", data)
+	}
+}
+
+func TestMarshalProtoDeadLetter(t *testing.T) {
+	event := LookatchEvent{
+		Payload: DeadLetterEvent{
+			Reason:    "too large",
+			Sink:      "kafka",
+			Timestamp: 1600000000,
+			Offset:    &Offset{Source: "1"},
+			Event: LookatchEvent{
+				Header:  LookatchHeader{EventType: "Syslog"},
+				Payload: GenericEvent{Value: "message", Offset: &Offset{Source: "1"}},
+			},
+		},
+	}
+
+	data, err := MarshalProto(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalProto(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, event) {
+		t.Errorf("%#v", decoded)
+	}
+}
+
+func TestMarshalProtoUnsupported(t *testing.T) {
+	_, err := MarshalProto(LookatchEvent{Payload: "raw"})
+	if err == nil {
+		t.Fail()
+	}
+	_, err = MarshalProto(LookatchEvent{Payload: GenericEvent{Value: struct{}{}}})
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestUnmarshalProtoInvalid(t *testing.T) {
+	_, err := UnmarshalProto([]byte{0x0a, 0x05, 0x0a})
+	if err == nil {
+		t.Fail()
+	}
+	_, err = UnmarshalProto(nil)
+	if err == nil {
+		t.Fail()
+	}
+}
+
+// descriptorEvents events setting every field of messages of proto/v1/lookatch.proto, with their protojson encoding
+var descriptorEvents = []struct {
+	event LookatchEvent
+	json  string
+}{
+	{
+		event: LookatchEvent{
+			Header: LookatchHeader{
+				EventType:  "MysqlCDC",
+				Tenant:     LookatchTenantInfo{ID: "agent", Env: "prod"},
+				SourceName: "mysql",
+			},
+			Payload: SQLEvent{
+				Tenant:      "tenant",
+				Environment: "prod",
+				Timestamp:   "1600000000000000000",
+				Database:    "shop",
+				Schema:      "public",
+				Table:       "orders",
+				Method:      "update",
+				PrimaryKey:  "id",
+				Offset:      &Offset{Source: "mysql-bin.000003:154:", Agent: "1"},
+				ColumnsMeta: map[string]ColumnsMeta{"id": {Type: "int", Position: 1}},
+				Statement: map[string]interface{}{
+					"id":       int64(-1),
+					"big":      uint64(18446744073709551615),
+					"amount":   json.Number("12.50"),
+					"rate":     0.5,
+					"paid":     true,
+					"note":     nil,
+					"blob":     []byte{0, 1},
+					"tags":     []interface{}{"a", int64(2)},
+					"document": map[string]interface{}{"key": "value"},
+				},
+				OldStatement: map[string]interface{}{"id": int64(-1)},
+				Metadata: &Metadata{
+					AgentUUID:       "agent",
+					Hostname:        "host",
+					SourceName:      "mysql",
+					SourceType:      "MysqlCDC",
+					Sequence:        3,
+					IngestTimestamp: 1600000000000000000,
+					RunID:           "run",
+				},
+			},
+		},
+		json: `{
+			"header": {"event_type": "MysqlCDC", "tenant": {"id": "agent", "env": "prod"}, "source_name": "mysql"},
+			"sql_event": {
+				"tenant": "tenant",
+				"environment": "prod",
+				"timestamp": "1600000000000000000",
+				"database": "shop",
+				"schema": "public",
+				"table": "orders",
+				"method": "update",
+				"primary_key": "id",
+				"offset": {"source": "mysql-bin.000003:154:", "agent": "1"},
+				"columns_meta": {"id": {"type": "int", "position": 1}},
+				"statement": {
+					"id": {"int_value": "-1"},
+					"big": {"uint_value": "18446744073709551615"},
+					"amount": {"decimal_value": "12.50"},
+					"rate": {"double_value": 0.5},
+					"paid": {"bool_value": true},
+					"note": {"null_value": "NULL_VALUE"},
+					"blob": {"bytes_value": "AAE="},
+					"tags": {"list_value": {"values": [{"string_value": "a"}, {"int_value": "2"}]}},
+					"document": {"map_value": {"fields": {"key": {"string_value": "value"}}}}
+				},
+				"old_statement": {"id": {"int_value": "-1"}},
+				"metadata": {
+					"agent_uuid": "agent",
+					"hostname": "host",
+					"source_name": "mysql",
+					"source_type": "MysqlCDC",
+					"sequence": "3",
+					"ingest_timestamp": "1600000000000000000",
+					"run_id": "run"
+				}
+			}
+		}`,
+	},
+	{
+		event: LookatchEvent{
+			Header: LookatchHeader{EventType: "Syslog"},
+			Payload: DeadLetterEvent{
+				Reason:    "too large",
+				Sink:      "kafka",
+				Timestamp: 1600000000,
+				Offset:    &Offset{Source: "1", Agent: "2"},
+				Event: LookatchEvent{
+					Header: LookatchHeader{EventType: "Syslog"},
+					Payload: GenericEvent{
+						Environment: "prod",
+						Timestamp:   "1600000000",
+						Offset:      &Offset{Source: "1", Agent: "2"},
+						Value:       "message",
+						Metadata:    &Metadata{Sequence: 1, RunID: "run"},
+					},
+				},
+			},
+		},
+		json: `{
+			"header": {"event_type": "Syslog"},
+			"dead_letter_event": {
+				"reason": "too large",
+				"sink": "kafka",
+				"timestamp": "1600000000",
+				"offset": {"source": "1", "agent": "2"},
+				"event": {
+					"header": {"event_type": "Syslog"},
+					"generic_event": {
+						"environment": "prod",
+						"timestamp": "1600000000",
+						"offset": {"source": "1", "agent": "2"},
+						"value": {"string_value": "message"},
+						"metadata": {"sequence": "1", "run_id": "run"}
+					}
+				}
+			}
+		}`,
+	},
+}
+
+// lookatchEventDescriptor compile proto/v1/lookatch.proto and return descriptor of LookatchEvent message
+func lookatchEventDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	compiler := protocompile.Compiler{
+		Resolver: &protocompile.SourceResolver{ImportPaths: []string{"proto/" + ProtoVersion}},
+	}
+	files, err := compiler.Compile(context.Background(), "lookatch.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	descriptor, ok := files[0].FindDescriptorByName("lookatch.events.v1.LookatchEvent").(protoreflect.MessageDescriptor)
+	if !ok {
+		t.Fatal("LookatchEvent message not found")
+	}
+	return descriptor
+}
+
+// hasUnknownFields return true if message or one of its embedded messages holds fields missing from descriptor
+func hasUnknownFields(m protoreflect.Message) bool {
+	unknown := len(m.GetUnknown()) != 0
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+				unknown = unknown || hasUnknownFields(v.Message())
+				return !unknown
+			})
+		case fd.IsList() && fd.Message() != nil:
+			for i := 0; i < v.List().Len() && !unknown; i++ {
+				unknown = hasUnknownFields(v.List().Get(i).Message())
+			}
+		case !fd.IsMap() && !fd.IsList() && fd.Message() != nil:
+			unknown = unknown || hasUnknownFields(v.Message())
+		}
+		return !unknown
+	})
+	return unknown
+}
+
+func TestMarshalProtoDescriptor(t *testing.T) {
+	descriptor := lookatchEventDescriptor(t)
+	for _, test := range descriptorEvents {
+		data, err := MarshalProto(test.event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		message := dynamicpb.NewMessage(descriptor)
+		err = proto.Unmarshal(data, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hasUnknownFields(message) {
+			t.Error("fields missing from descriptor", message)
+		}
+
+		decoded, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual, expected interface{}
+		if err = json.Unmarshal(decoded, &actual); err != nil {
+			t.Fatal(err)
+		}
+		if err = json.Unmarshal([]byte(test.json), &expected); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s", decoded)
+		}
+	}
+}
+
+func TestUnmarshalProtoDescriptor(t *testing.T) {
+	descriptor := lookatchEventDescriptor(t)
+	for _, test := range descriptorEvents {
+		message := dynamicpb.NewMessage(descriptor)
+		err := protojson.Unmarshal([]byte(test.json), message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		event, err := UnmarshalProto(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(event, test.event) {
+			t.Errorf("%#v", event)
+		}
+	}
+}
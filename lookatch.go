@@ -10,11 +10,13 @@ in real time to the changes in your configured source data.
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
 	"runtime"
 	"strconv"
+	"syscall"
 
 	"github.com/google/uuid"
 	"github.com/spf13/viper"
@@ -28,6 +30,7 @@ import (
 
 var (
 	closing          chan error
+	signals          chan os.Signal
 	cfgPath, cfgFile string
 	v                *viper.Viper
 )
@@ -65,17 +68,12 @@ var (
 
 // init collector
 // notifications for commands. This channel will send a message for every
-// interrupt received.
+// interrupt, termination or hangup signal received.
 func init() {
 	closing = make(chan error)
 
-	signalCh := make(chan os.Signal, 4)
-	signal.Notify(signalCh, os.Interrupt)
-	go func() {
-		<-signalCh
-		log.Info("Got SIGINT signal, I quit")
-		close(closing)
-	}()
+	signals = make(chan os.Signal, 4)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 
 	app.AddCommand(agentCmd, versionCmd)
 	agentCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file (default is $PWD/config.json)")
@@ -170,6 +168,7 @@ func initializeConfig() (*viper.Viper, error) {
 // runAgent run an instance of the collector
 func runAgent() {
 	var err error
+	agents := make(chan *core.Agent, 1)
 	go func() {
 		config, err := initializeConfig()
 		if err != nil {
@@ -185,17 +184,54 @@ func runAgent() {
 		log.SetLevel(logLevel)
 		log.WithField("level", log.DebugLevel).Info("log level")
 
-		err = core.Run(config, closing)
+		agent, err := core.Run(config, closing)
 		if err != nil {
 			closing <- err
 			return
 		}
+		agents <- agent
 		log.Info("Agent started")
 	}()
 
-	err = <-closing
-	if err != nil {
-		log.WithError(err).Error("Error running agent")
+	// SIGHUP reload configuration, other signals stop agent
+	var agent *core.Agent
+loop:
+	for {
+		select {
+		case err = <-closing:
+			if err != nil {
+				log.WithError(err).Error("Error running agent")
+			}
+			break loop
+		case agent = <-agents:
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				log.WithField("signal", sig).Info("Got signal, I quit")
+				break loop
+			}
+			if agent == nil {
+				log.WithField("signal", sig).Warn("Agent not started, reload ignored")
+				continue
+			}
+			log.WithField("signal", sig).Info("Got signal, reloading configuration")
+			if err := agent.Reload(); err != nil {
+				log.WithError(err).Error("Error while reloading configuration")
+			}
+		}
+	}
+
+	if agent == nil {
+		select {
+		case agent = <-agents:
+		default:
+		}
+	}
+	if agent != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), agent.ShutdownTimeout())
+		if err := agent.Stop(ctx); err != nil {
+			log.WithError(err).Error("Error while stopping agent")
+		}
+		cancel()
 	}
 	log.Info("Closing, Bye !")
 }
@@ -0,0 +1,117 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+// CastType type of processor converting fields
+const CastType = "cast"
+
+// Types fields can be cast to
+const (
+	CastString = "string"
+	CastInt    = "int"
+	CastFloat  = "float"
+	CastBool   = "bool"
+)
+
+// Cast processor converting fields to a type
+type Cast struct {
+	fields []string
+	to     string
+}
+
+// newCast create a new cast processor
+func newCast(config Config) (Processor, error) {
+	fields := config.fields()
+	if len(fields) == 0 {
+		return nil, errors.New("cast processor requires field or fields")
+	}
+	switch config.To {
+	case CastString, CastInt, CastFloat, CastBool:
+	default:
+		return nil, errors.Errorf("cast processor can't cast to '%s'", config.To)
+	}
+	return &Cast{fields: fields, to: config.To}, nil
+}
+
+// Process convert fields, null values are kept
+func (c *Cast) Process(event *events.LookatchEvent) error {
+	return processValues(event, func(values map[string]interface{}) error {
+		for _, field := range c.fields {
+			value, ok := values[field]
+			if !ok || value == nil {
+				continue
+			}
+			casted, err := castValue(value, c.to)
+			if err != nil {
+				return errors.Annotatef(err, "error while casting field '%s' to %s", field, c.to)
+			}
+			values[field] = casted
+		}
+		return nil
+	})
+}
+
+// castValue convert value to type
+func castValue(value interface{}, to string) (interface{}, error) {
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	switch to {
+	case CastString:
+		return fmt.Sprint(value), nil
+	case CastInt:
+		switch v := value.(type) {
+		case bool:
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case float32:
+			return int64(v), nil
+		case float64:
+			return int64(v), nil
+		}
+		s := strings.TrimSpace(fmt.Sprint(value))
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return int64(f), nil
+	case CastFloat:
+		switch v := value.(type) {
+		case bool:
+			if v {
+				return float64(1), nil
+			}
+			return float64(0), nil
+		case json.Number:
+			return v.Float64()
+		}
+		return strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(value)), 64)
+	case CastBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(strings.TrimSpace(v))
+		}
+		f, err := strconv.ParseFloat(fmt.Sprint(value), 64)
+		if err != nil {
+			return nil, err
+		}
+		return f != 0, nil
+	}
+	return nil, errors.Errorf("unknown type '%s'", to)
+}
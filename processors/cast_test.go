@@ -0,0 +1,71 @@
+package processors
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+func TestNewCast(t *testing.T) {
+	if _, err := New(Config{Type: CastType, Field: "a", To: "date"}); err == nil {
+		t.Fail()
+	}
+	if _, err := New(Config{Type: CastType, To: CastInt}); err == nil {
+		t.Fail()
+	}
+}
+
+func TestCastValue(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		to       string
+		expected interface{}
+	}{
+		{[]byte("12"), CastInt, int64(12)},
+		{"12.7", CastInt, int64(12)},
+		{float64(3.2), CastInt, int64(3)},
+		{true, CastInt, int64(1)},
+		{"1.5", CastFloat, 1.5},
+		{json.Number("2.5"), CastFloat, 2.5},
+		{int64(4), CastFloat, float64(4)},
+		{int64(4), CastString, "4"},
+		{[]byte("text"), CastString, "text"},
+		{"true", CastBool, true},
+		{int64(0), CastBool, false},
+	}
+	for _, test := range tests {
+		result, err := castValue(test.value, test.to)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("cast %v to %s: got %#v", test.value, test.to, result)
+		}
+	}
+
+	if _, err := castValue("abc", CastInt); err == nil {
+		t.Fail()
+	}
+}
+
+func TestCast(t *testing.T) {
+	values := map[string]interface{}{"a": "1", "b": nil}
+	err := process(t, Config{Type: CastType, Fields: []string{"a", "b", "c"}, To: CastInt}, &events.LookatchEvent{Payload: events.GenericEvent{Value: values}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["a"] != int64(1) || values["b"] != nil {
+		t.Fail()
+	}
+	if _, ok := values["c"]; ok {
+		t.Fail()
+	}
+
+	values = map[string]interface{}{"a": "abc"}
+	err = process(t, Config{Type: CastType, Field: "a", To: CastFloat}, &events.LookatchEvent{Payload: events.GenericEvent{Value: values}})
+	if err == nil {
+		t.Fail()
+	}
+}
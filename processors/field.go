@@ -0,0 +1,132 @@
+package processors
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+// Types of processors updating fields
+const (
+	RenameType     = "rename"
+	DropType       = "drop"
+	AddType        = "add"
+	PrimaryKeyType = "primary_key"
+)
+
+type (
+	// Rename processor renaming a field
+	Rename struct {
+		field string
+		to    string
+	}
+
+	// Drop processor removing fields
+	Drop struct {
+		fields []string
+	}
+
+	// Add processor setting a field to a static value
+	Add struct {
+		field string
+		value interface{}
+	}
+
+	// PrimaryKey processor copying values of fields into PrimaryKey of sql events
+	PrimaryKey struct {
+		fields []string
+	}
+)
+
+// newRename create a new rename processor
+func newRename(config Config) (Processor, error) {
+	if config.Field == "" || config.To == "" {
+		return nil, errors.New("rename processor requires field and to")
+	}
+	return &Rename{field: config.Field, to: config.To}, nil
+}
+
+// Process rename field, existing field with the new name is replaced
+func (r *Rename) Process(event *events.LookatchEvent) error {
+	return processValues(event, func(values map[string]interface{}) error {
+		if value, ok := values[r.field]; ok {
+			delete(values, r.field)
+			values[r.to] = value
+		}
+		return nil
+	})
+}
+
+// newDrop create a new drop processor
+func newDrop(config Config) (Processor, error) {
+	fields := config.fields()
+	if len(fields) == 0 {
+		return nil, errors.New("drop processor requires field or fields")
+	}
+	return &Drop{fields: fields}, nil
+}
+
+// Process remove fields
+func (d *Drop) Process(event *events.LookatchEvent) error {
+	return processValues(event, func(values map[string]interface{}) error {
+		for _, field := range d.fields {
+			delete(values, field)
+		}
+		return nil
+	})
+}
+
+// newAdd create a new add processor
+func newAdd(config Config) (Processor, error) {
+	if config.Field == "" {
+		return nil, errors.New("add processor requires field")
+	}
+	return &Add{field: config.Field, value: config.Value}, nil
+}
+
+// Process set field to static value
+func (a *Add) Process(event *events.LookatchEvent) error {
+	return processValues(event, func(values map[string]interface{}) error {
+		values[a.field] = a.value
+		return nil
+	})
+}
+
+// newPrimaryKey create a new primary key processor
+func newPrimaryKey(config Config) (Processor, error) {
+	fields := config.fields()
+	if len(fields) == 0 {
+		return nil, errors.New("primary_key processor requires field or fields")
+	}
+	return &PrimaryKey{fields: fields}, nil
+}
+
+// Process set PrimaryKey to comma separated values of fields
+// values are read from Statement, or from OldStatement for deleted rows
+// generic events are left unchanged
+func (p *PrimaryKey) Process(event *events.LookatchEvent) error {
+	sqlEvent, ok := event.Payload.(events.SQLEvent)
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(p.fields))
+	for _, field := range p.fields {
+		value, found := sqlEvent.Statement[field]
+		if !found {
+			value, found = sqlEvent.OldStatement[field]
+		}
+		if !found {
+			return errors.Errorf("primary key field '%s' not found", field)
+		}
+		if b, isBytes := value.([]byte); isBytes {
+			value = string(b)
+		}
+		keys = append(keys, fmt.Sprint(value))
+	}
+	sqlEvent.PrimaryKey = strings.Join(keys, ",")
+	event.Payload = sqlEvent
+	return nil
+}
@@ -0,0 +1,83 @@
+package processors
+
+import (
+	"testing"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+func process(t *testing.T, config Config, event *events.LookatchEvent) error {
+	p, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Process(event)
+}
+
+func TestRename(t *testing.T) {
+	values := map[string]interface{}{"a": 1}
+	err := process(t, Config{Type: RenameType, Field: "a", To: "b"}, &events.LookatchEvent{Payload: events.GenericEvent{Value: values}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := values["a"]; ok || values["b"] != 1 {
+		t.Fail()
+	}
+
+	if _, err = New(Config{Type: RenameType, Field: "a"}); err == nil {
+		t.Fail()
+	}
+}
+
+func TestDrop(t *testing.T) {
+	values := map[string]interface{}{"a": 1, "b": 2, "c": 3}
+	err := process(t, Config{Type: DropType, Field: "a", Fields: []string{"b"}}, &events.LookatchEvent{Payload: events.GenericEvent{Value: values}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 1 || values["c"] != 3 {
+		t.Fail()
+	}
+}
+
+func TestAdd(t *testing.T) {
+	values := map[string]interface{}{"a": 1}
+	err := process(t, Config{Type: AddType, Field: "origin", Value: "lookatch"}, &events.LookatchEvent{Payload: events.GenericEvent{Value: values}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["origin"] != "lookatch" || values["a"] != 1 {
+		t.Fail()
+	}
+}
+
+func TestPrimaryKey(t *testing.T) {
+	event := events.LookatchEvent{
+		Payload: events.SQLEvent{
+			PrimaryKey: "id",
+			Statement:  map[string]interface{}{"id": int64(42), "region": []byte("eu")},
+		},
+	}
+	err := process(t, Config{Type: PrimaryKeyType, Fields: []string{"region", "id"}}, &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Payload.(events.SQLEvent).PrimaryKey != "eu,42" {
+		t.Error(event.Payload.(events.SQLEvent).PrimaryKey)
+	}
+
+	deleted := events.LookatchEvent{
+		Payload: events.SQLEvent{
+			OldStatement: map[string]interface{}{"id": 7},
+		},
+	}
+	err = process(t, Config{Type: PrimaryKeyType, Field: "id"}, &deleted)
+	if err != nil || deleted.Payload.(events.SQLEvent).PrimaryKey != "7" {
+		t.Fail()
+	}
+
+	err = process(t, Config{Type: PrimaryKeyType, Field: "missing"}, &deleted)
+	if err == nil {
+		t.Fail()
+	}
+}
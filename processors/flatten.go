@@ -0,0 +1,90 @@
+package processors
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+// FlattenType type of processor flattening nested objects
+const FlattenType = "flatten"
+
+// DefaultFlattenSeparator default separator of flattened keys
+const DefaultFlattenSeparator = "."
+
+// Flatten processor replacing nested objects by their fields
+// keys of nested fields are joined with separator
+type Flatten struct {
+	fields    []string
+	separator string
+}
+
+// newFlatten create a new flatten processor
+// all fields are flattened if none is set
+func newFlatten(config Config) (Processor, error) {
+	separator := config.Separator
+	if separator == "" {
+		separator = DefaultFlattenSeparator
+	}
+	return &Flatten{fields: config.fields(), separator: separator}, nil
+}
+
+// Process flatten objects and JSON encoded objects
+func (f *Flatten) Process(event *events.LookatchEvent) error {
+	return processValues(event, func(values map[string]interface{}) error {
+		fields := f.fields
+		if len(fields) == 0 {
+			fields = make([]string, 0, len(values))
+			for field := range values {
+				fields = append(fields, field)
+			}
+		}
+		for _, field := range fields {
+			nested, ok := toObject(values[field])
+			if !ok {
+				continue
+			}
+			delete(values, field)
+			f.flatten(values, field, nested)
+		}
+		return nil
+	})
+}
+
+// flatten add nested fields to values with prefix
+func (f *Flatten) flatten(values map[string]interface{}, prefix string, nested map[string]interface{}) {
+	for key, value := range nested {
+		key = prefix + f.separator + key
+		if object, ok := toMap(value); ok {
+			f.flatten(values, key, object)
+			continue
+		}
+		values[key] = value
+	}
+}
+
+// toObject return value as a map if it is a map or a JSON encoded object
+func toObject(value interface{}) (map[string]interface{}, bool) {
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return toMap(value)
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return nil, false
+	}
+	object := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return nil, false
+	}
+	return object, true
+}
@@ -0,0 +1,48 @@
+package processors
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+func TestFlatten(t *testing.T) {
+	values := map[string]interface{}{
+		"id":      1,
+		"address": map[string]interface{}{"city": "Paris", "geo": map[string]interface{}{"lat": 48.8}},
+		"doc":     []byte(`{"a": {"b": 2}, "c": [1, 2]}`),
+		"text":    "not json",
+	}
+	err := process(t, Config{Type: FlattenType}, &events.LookatchEvent{Payload: events.GenericEvent{Value: values}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values["address.city"] != "Paris" || values["address.geo.lat"] != 48.8 {
+		t.Error("nested map not flattened")
+	}
+	if values["doc.a.b"] != json.Number("2") || len(values["doc.c"].([]interface{})) != 2 {
+		t.Error("JSON object not flattened")
+	}
+	if values["text"] != "not json" || values["id"] != 1 {
+		t.Fail()
+	}
+	if _, ok := values["address"]; ok {
+		t.Fail()
+	}
+}
+
+func TestFlattenField(t *testing.T) {
+	values := map[string]interface{}{
+		"a": `{"b": 1}`,
+		"c": `{"d": 2}`,
+	}
+	err := process(t, Config{Type: FlattenType, Field: "a", Separator: "_"}, &events.LookatchEvent{Payload: events.GenericEvent{Value: values}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["a_b"] != json.Number("1") || values["c"] != `{"d": 2}` {
+		t.Fail()
+	}
+}
@@ -0,0 +1,121 @@
+package processors
+
+import (
+	"reflect"
+
+	"github.com/juju/errors"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+type (
+	// Processor transform an event before it is sent to sinks
+	Processor interface {
+		Process(event *events.LookatchEvent) error
+	}
+
+	// Config representation of a processor configuration
+	Config struct {
+		Type      string      `json:"type" mapstructure:"type"`
+		Field     string      `json:"field" mapstructure:"field"`
+		Fields    []string    `json:"fields" mapstructure:"fields"`
+		To        string      `json:"to" mapstructure:"to"`
+		Value     interface{} `json:"value" mapstructure:"value"`
+		Separator string      `json:"separator" mapstructure:"separator"`
+	}
+
+	// Pipeline ordered chain of processors applied to events of a source
+	Pipeline []Processor
+)
+
+// processorCreatorFunc create processor from its configuration
+type processorCreatorFunc func(Config) (Processor, error)
+
+// Factory list of available processors
+var Factory = map[string]processorCreatorFunc{
+	RenameType:     newRename,
+	DropType:       newDrop,
+	AddType:        newAdd,
+	CastType:       newCast,
+	FlattenType:    newFlatten,
+	PrimaryKeyType: newPrimaryKey,
+}
+
+// mapType type of values processed
+var mapType = reflect.TypeOf(map[string]interface{}{})
+
+// New create a new processor
+func New(config Config) (Processor, error) {
+	processorCreatorFunc, found := Factory[config.Type]
+	if !found {
+		return nil, errors.Errorf("processor type not found '%s'", config.Type)
+	}
+	return processorCreatorFunc(config)
+}
+
+// NewPipeline create processors in configuration order
+func NewPipeline(configs []Config) (Pipeline, error) {
+	pipeline := make(Pipeline, 0, len(configs))
+	for i, config := range configs {
+		processor, err := New(config)
+		if err != nil {
+			return nil, errors.Annotatef(err, "invalid processor %d", i)
+		}
+		pipeline = append(pipeline, processor)
+	}
+	return pipeline, nil
+}
+
+// Process apply processors to event in order
+// processing stops at first error
+func (p Pipeline) Process(event *events.LookatchEvent) error {
+	for _, processor := range p {
+		if err := processor.Process(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fields return field and fields of configuration
+func (c Config) fields() []string {
+	if c.Field == "" {
+		return c.Fields
+	}
+	return append([]string{c.Field}, c.Fields...)
+}
+
+// processValues call fn on values of event
+// values are Statement and OldStatement of sql events and Value of generic events if it is a map
+// fn may update values in place
+func processValues(event *events.LookatchEvent, fn func(map[string]interface{}) error) error {
+	switch payload := event.Payload.(type) {
+	case events.SQLEvent:
+		if payload.Statement != nil {
+			if err := fn(payload.Statement); err != nil {
+				return err
+			}
+		}
+		if payload.OldStatement != nil {
+			return fn(payload.OldStatement)
+		}
+	case events.GenericEvent:
+		if values, ok := toMap(payload.Value); ok {
+			return fn(values)
+		}
+	}
+	return nil
+}
+
+// toMap return value as a map if it is a map of values
+// named map types share their storage with the returned map
+func toMap(value interface{}) (map[string]interface{}, bool) {
+	if values, ok := value.(map[string]interface{}); ok {
+		return values, true
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Map || v.IsNil() || !v.Type().ConvertibleTo(mapType) {
+		return nil, false
+	}
+	return v.Convert(mapType).Interface().(map[string]interface{}), true
+}
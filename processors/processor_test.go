@@ -0,0 +1,77 @@
+package processors
+
+import (
+	"testing"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+type logParts map[string]interface{}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Type: "unknown"})
+	if err == nil {
+		t.Fail()
+	}
+
+	p, err := New(Config{Type: RenameType, Field: "a", To: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.(*Rename); !ok {
+		t.Fail()
+	}
+}
+
+func TestNewPipeline(t *testing.T) {
+	_, err := NewPipeline([]Config{{Type: DropType, Field: "a"}, {Type: RenameType}})
+	if err == nil {
+		t.Fail()
+	}
+
+	pipeline, err := NewPipeline([]Config{
+		{Type: RenameType, Field: "a", To: "b"},
+		{Type: CastType, Field: "b", To: CastInt},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := events.LookatchEvent{
+		Payload: events.SQLEvent{
+			Statement:    map[string]interface{}{"a": "1"},
+			OldStatement: map[string]interface{}{"a": "2"},
+		},
+	}
+	err = pipeline.Process(&event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlEvent := event.Payload.(events.SQLEvent)
+	if sqlEvent.Statement["b"] != int64(1) || sqlEvent.OldStatement["b"] != int64(2) {
+		t.Fail()
+	}
+}
+
+func TestProcessGenericEvent(t *testing.T) {
+	pipeline, err := NewPipeline([]Config{{Type: DropType, Field: "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := logParts{"a": 1, "b": 2}
+	event := events.LookatchEvent{Payload: events.GenericEvent{Value: values}}
+	err = pipeline.Process(&event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := values["a"]; ok || values["b"] != 2 {
+		t.Fail()
+	}
+
+	event = events.LookatchEvent{Payload: events.GenericEvent{Value: "text"}}
+	err = pipeline.Process(&event)
+	if err != nil || event.Payload.(events.GenericEvent).Value != "text" {
+		t.Fail()
+	}
+}
@@ -0,0 +1,441 @@
+package sinks
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/linkedin/goavro/v2"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+	"github.com/Pirionfr/lookatch-agent/sources"
+)
+
+// AvroNamespace namespace of Avro records, followed by database and schema of tables
+const AvroNamespace = "lookatch"
+
+// AvroMagicByte first byte of messages framed with their schema id
+const AvroMagicByte = 0
+
+// avroRefreshInterval interval between two lookups of the schema of a table to detect changed column types
+const avroRefreshInterval = time.Minute
+
+// avroInvalidChars characters not allowed in Avro names
+var avroInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+type (
+	// SchemaProvider return schema of source, columns by table as returned by GetSchema of sources
+	SchemaProvider func(sourceName string) map[string]map[string]*sources.Column
+
+	// avroSerializer serialize SQL events in Avro with a record schema per table
+	// schemas are built from source schemas and registered in a schema registry
+	avroSerializer struct {
+		sync.Mutex
+		sink     *Sink
+		registry *schemaRegistry
+		tables   map[string]*avroTable
+		now      func() time.Time
+	}
+
+	// avroTable registered Avro schema of a table
+	avroTable struct {
+		schema  string
+		id      uint32
+		codec   *goavro.Codec
+		name    string
+		columns []avroColumn
+		checked time.Time
+	}
+
+	// avroColumn column of a table and its Avro field
+	avroColumn struct {
+		column   *sources.Column
+		field    string
+		avroType interface{}
+		branch   string
+	}
+)
+
+// newAvroSerializer create Avro serializer of sink, schema registry is read from sink config
+func newAvroSerializer(s *Sink) (*avroSerializer, error) {
+	registry, err := newSchemaRegistry(s.Conf)
+	if err != nil {
+		return nil, err
+	}
+	return &avroSerializer{
+		sink:     s,
+		registry: registry,
+		tables:   make(map[string]*avroTable),
+		now:      time.Now,
+	}, nil
+}
+
+// Serialize encode SQL event in Avro, prefixed by magic byte and schema id
+func (a *avroSerializer) Serialize(event events.LookatchEvent) ([]byte, map[string]string, error) {
+	sqlEvent, ok := event.Payload.(events.SQLEvent)
+	if !ok {
+		return nil, nil, errors.Errorf("avro format does not support %T", event.Payload)
+	}
+
+	a.Lock()
+	table, err := a.getTable(event.Header.SourceName, sqlEvent, false)
+	a.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	record, err := table.record(sqlEvent)
+	if err != nil {
+		// type of a column may have changed since schema was checked
+		a.Lock()
+		table, err = a.getTable(event.Header.SourceName, sqlEvent, true)
+		a.Unlock()
+		if err != nil {
+			return nil, nil, err
+		}
+		record, err = table.record(sqlEvent)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	payload := make([]byte, 5, 5+256)
+	payload[0] = AvroMagicByte
+	binary.BigEndian.PutUint32(payload[1:], table.id)
+	payload, err = table.codec.BinaryFromNative(payload, record)
+	if err != nil {
+		return nil, nil, errors.Annotatef(err, "error while encoding event of table '%s'", table.name)
+	}
+	return payload, nil, nil
+}
+
+// getTable return Avro schema of table of event
+// source schema is looked up at once when event holds columns unknown to the current one or refresh is set,
+// and every avroRefreshInterval otherwise to detect changed column types. A new version is registered if it changed.
+// An error is returned if event still holds unknown columns, so it isn't sent without their values
+func (a *avroSerializer) getTable(sourceName string, sqlEvent events.SQLEvent, refresh bool) (*avroTable, error) {
+	key := strings.Join([]string{sourceName, sqlEvent.Database, sqlEvent.Schema, sqlEvent.Table}, ".")
+	table := a.tables[key]
+	unknown := table == nil || table.hasUnknownColumns(sqlEvent)
+	if !unknown && !refresh && a.now().Sub(table.checked) < avroRefreshInterval {
+		return table, nil
+	}
+
+	columns := a.getColumns(sourceName, sqlEvent)
+	if len(columns) == 0 {
+		if !unknown {
+			table.checked = a.now()
+			return table, nil
+		}
+		return nil, errors.Errorf("no schema found for table '%s' of source '%s'", sqlEvent.Table, sourceName)
+	}
+	if !unknown && !table.hasChangedColumns(columns) {
+		table.checked = a.now()
+		return table, nil
+	}
+
+	name, schema, avroColumns, err := avroSchema(sqlEvent, columns)
+	if err != nil {
+		return nil, err
+	}
+	if table == nil || table.schema != schema {
+		codec, err := goavro.NewCodec(schema)
+		if err != nil {
+			return nil, errors.Annotatef(err, "invalid avro schema of table '%s'", name)
+		}
+		id, err := a.registry.register(name, schema)
+		if err != nil {
+			return nil, err
+		}
+		table = &avroTable{
+			schema:  schema,
+			id:      id,
+			codec:   codec,
+			name:    name,
+			columns: avroColumns,
+		}
+		a.tables[key] = table
+	}
+	table.checked = a.now()
+
+	if table.hasUnknownColumns(sqlEvent) {
+		return nil, errors.Errorf("event holds columns missing from schema of table '%s' of source '%s'", sqlEvent.Table, sourceName)
+	}
+	return table, nil
+}
+
+// getColumns return columns of table of event from source schema
+// tables are keyed by schema of PostgreSQL and SQL Server, by database of MySQL
+func (a *avroSerializer) getColumns(sourceName string, sqlEvent events.SQLEvent) map[string]*sources.Column {
+	if a.sink.Schemas == nil {
+		return nil
+	}
+	schema := a.sink.Schemas(sourceName)
+	if sqlEvent.Schema != "" {
+		if columns, ok := schema[sqlEvent.Schema+"."+sqlEvent.Table]; ok {
+			return columns
+		}
+	}
+	return schema[sqlEvent.Database+"."+sqlEvent.Table]
+}
+
+// hasUnknownColumns return true if event holds columns missing from schema
+func (t *avroTable) hasUnknownColumns(sqlEvent events.SQLEvent) bool {
+	known := make(map[string]bool, len(t.columns))
+	for _, column := range t.columns {
+		known[column.column.Column] = true
+	}
+	for _, values := range []map[string]interface{}{sqlEvent.Statement, sqlEvent.OldStatement} {
+		for name := range values {
+			if !known[name] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasChangedColumns return true if columns were added, removed or changed of Avro type since schema was built
+func (t *avroTable) hasChangedColumns(columns map[string]*sources.Column) bool {
+	if len(columns) != len(t.columns) {
+		return true
+	}
+	for _, cached := range t.columns {
+		column, ok := columns[cached.column.Column]
+		if !ok {
+			return true
+		}
+		avroType, _ := avroColumnType(column)
+		if !reflect.DeepEqual(avroType, cached.avroType) {
+			return true
+		}
+	}
+	return false
+}
+
+// avroName replace characters not allowed in Avro names by _
+func avroName(name string) string {
+	name = avroInvalidChars.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// avroSchema build record schema of table of event from its columns
+// every column is optional as events may hold part of a row, such as keys of deleted rows
+func avroSchema(sqlEvent events.SQLEvent, columns map[string]*sources.Column) (string, string, []avroColumn, error) {
+	namespace := AvroNamespace + "." + avroName(sqlEvent.Database)
+	if sqlEvent.Schema != "" {
+		namespace += "." + avroName(sqlEvent.Schema)
+	}
+	name := avroName(sqlEvent.Table)
+
+	avroColumns := make([]avroColumn, 0, len(columns))
+	for _, column := range columns {
+		avroColumns = append(avroColumns, avroColumn{column: column, field: avroName(column.Column)})
+	}
+	sort.Slice(avroColumns, func(i, j int) bool {
+		if avroColumns[i].column.ColumnOrdPos != avroColumns[j].column.ColumnOrdPos {
+			return avroColumns[i].column.ColumnOrdPos < avroColumns[j].column.ColumnOrdPos
+		}
+		return avroColumns[i].column.Column < avroColumns[j].column.Column
+	})
+
+	fields := make([]interface{}, 0, len(avroColumns))
+	for i := range avroColumns {
+		avroType, branch := avroColumnType(avroColumns[i].column)
+		avroColumns[i].avroType = avroType
+		avroColumns[i].branch = branch
+		fields = append(fields, map[string]interface{}{
+			"name":    avroColumns[i].field,
+			"type":    []interface{}{"null", avroType},
+			"default": nil,
+		})
+	}
+
+	optional := func(name string, avroType interface{}) map[string]interface{} {
+		return map[string]interface{}{"name": name, "type": []interface{}{"null", avroType}, "default": nil}
+	}
+	record := map[string]interface{}{
+		"type":      "record",
+		"name":      name,
+		"namespace": namespace,
+		"fields": []interface{}{
+			map[string]interface{}{"name": "environment", "type": "string"},
+			map[string]interface{}{"name": "timestamp", "type": "string"},
+			map[string]interface{}{"name": "database", "type": "string"},
+			map[string]interface{}{"name": "schema", "type": "string"},
+			map[string]interface{}{"name": "table", "type": "string"},
+			map[string]interface{}{"name": "method", "type": "string"},
+			map[string]interface{}{"name": "primary_key", "type": "string"},
+			optional("offset", map[string]interface{}{
+				"type": "record",
+				"name": "Offset",
+				"fields": []interface{}{
+					map[string]interface{}{"name": "source", "type": "string"},
+					map[string]interface{}{"name": "agent", "type": "string"},
+				},
+			}),
+			optional("statement", map[string]interface{}{
+				"type":   "record",
+				"name":   name + "_values",
+				"fields": fields,
+			}),
+			optional("old_statement", name+"_values"),
+		},
+	}
+	schema, err := json.Marshal(record)
+	if err != nil {
+		return "", "", nil, errors.Annotatef(err, "error while building avro schema of table '%s'", name)
+	}
+	return namespace + "." + name, string(schema), avroColumns, nil
+}
+
+// avroColumnType return Avro type of column and its name in unions
+// decimals without precision are strings
+func avroColumnType(column *sources.Column) (interface{}, string) {
+	switch column.Kind() {
+	case sources.KindInteger:
+		return "long", "long"
+	case sources.KindFloat:
+		return "double", "double"
+	case sources.KindDecimal:
+		if column.NumericPrecision.Valid && column.NumericPrecision.Int64 > 0 {
+			return map[string]interface{}{
+				"type":        "bytes",
+				"logicalType": "decimal",
+				"precision":   column.NumericPrecision.Int64,
+				"scale":       column.NumericScale.Int64,
+			}, "bytes.decimal"
+		}
+	case sources.KindBoolean:
+		return "boolean", "boolean"
+	case sources.KindDate:
+		return map[string]interface{}{"type": "int", "logicalType": "date"}, "int.date"
+	case sources.KindTimestamp:
+		return map[string]interface{}{"type": "long", "logicalType": "timestamp-micros"}, "long.timestamp-micros"
+	case sources.KindBinary:
+		return "bytes", "bytes"
+	}
+	return "string", "string"
+}
+
+// record convert SQL event to native Avro record of table
+func (t *avroTable) record(sqlEvent events.SQLEvent) (map[string]interface{}, error) {
+	record := map[string]interface{}{
+		"environment":   sqlEvent.Environment,
+		"timestamp":     sqlEvent.Timestamp,
+		"database":      sqlEvent.Database,
+		"schema":        sqlEvent.Schema,
+		"table":         sqlEvent.Table,
+		"method":        sqlEvent.Method,
+		"primary_key":   sqlEvent.PrimaryKey,
+		"offset":        nil,
+		"statement":     nil,
+		"old_statement": nil,
+	}
+	namespace := t.name[:strings.LastIndex(t.name, ".")]
+	if sqlEvent.Offset != nil {
+		record["offset"] = goavro.Union(namespace+".Offset", map[string]interface{}{
+			"source": sqlEvent.Offset.Source,
+			"agent":  sqlEvent.Offset.Agent,
+		})
+	}
+	for field, values := range map[string]map[string]interface{}{"statement": sqlEvent.Statement, "old_statement": sqlEvent.OldStatement} {
+		if values == nil {
+			continue
+		}
+		nativeValues := make(map[string]interface{}, len(t.columns))
+		for _, column := range t.columns {
+			value, err := avroValue(column.branch, values[column.column.Column])
+			if err != nil {
+				return nil, errors.Annotatef(err, "invalid value of column '%s'", column.column.Column)
+			}
+			nativeValues[column.field] = nil
+			if value != nil {
+				nativeValues[column.field] = goavro.Union(column.branch, value)
+			}
+		}
+		record[field] = goavro.Union(t.name+"_values", nativeValues)
+	}
+	return record, nil
+}
+
+// avroValue convert normalized value of column to native Avro value of its branch in unions
+func avroValue(branch string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch branch {
+	case "long":
+		switch v := value.(type) {
+		case int64:
+			return v, nil
+		case uint64:
+			return int64(v), nil
+		case int:
+			return int64(v), nil
+		case float64:
+			return int64(v), nil
+		}
+		return strconv.ParseInt(fmt.Sprint(value), 10, 64)
+	case "double":
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int64:
+			return float64(v), nil
+		case uint64:
+			return float64(v), nil
+		}
+		return strconv.ParseFloat(fmt.Sprint(value), 64)
+	case "bytes.decimal":
+		rat, ok := new(big.Rat).SetString(fmt.Sprint(value))
+		if !ok {
+			return nil, errors.Errorf("invalid decimal '%v'", value)
+		}
+		return rat, nil
+	case "boolean":
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+		return strconv.ParseBool(fmt.Sprint(value))
+	case "int.date":
+		if v, ok := value.(time.Time); ok {
+			return v, nil
+		}
+		return time.Parse(sources.DateLayout, fmt.Sprint(value))
+	case "long.timestamp-micros":
+		if v, ok := value.(time.Time); ok {
+			return v, nil
+		}
+		return time.Parse(time.RFC3339Nano, fmt.Sprint(value))
+	case "bytes":
+		switch v := value.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			return base64.StdEncoding.DecodeString(v)
+		}
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+	return fmt.Sprint(value), nil
+}
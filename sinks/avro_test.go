@@ -0,0 +1,293 @@
+package sinks
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/spf13/viper"
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+	"github.com/Pirionfr/lookatch-agent/sources"
+)
+
+// newTestRegistry start schema registry registering schemas with incremented ids
+func newTestRegistry(t *testing.T, schemas *[]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subjects/lookatch.shop.orders/versions" || r.Header.Get("Content-Type") != SchemaRegistryContentType {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		*schemas = append(*schemas, body["schema"])
+		json.NewEncoder(w).Encode(map[string]int{"id": len(*schemas)})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestAvroSerializer create avro serializer of sink using registry and schema
+func newTestAvroSerializer(t *testing.T, registryURL string, schema map[string]map[string]*sources.Column) *avroSerializer {
+	conf := viper.New()
+	conf.Set("format", "avro")
+	conf.Set("schema_registry.url", registryURL)
+	s := &Sink{Name: "kafka", Conf: conf}
+	s.SetSchemaProvider(func(sourceName string) map[string]map[string]*sources.Column {
+		if sourceName != "mysql" {
+			return nil
+		}
+		return schema
+	})
+	a, err := newAvroSerializer(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// decodeAvro check framing of payload and decode it with schema
+func decodeAvro(t *testing.T, payload []byte, schema string, id uint32) map[string]interface{} {
+	if len(payload) < 5 || payload[0] != AvroMagicByte || binary.BigEndian.Uint32(payload[1:5]) != id {
+		t.Fatal("invalid framing", payload)
+	}
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	native, _, err := codec.NativeFromBinary(payload[5:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return native.(map[string]interface{})
+}
+
+func TestAvroMarshal(t *testing.T) {
+	var schemas []string
+	registry := newTestRegistry(t, &schemas)
+	a := newTestAvroSerializer(t, registry.URL, map[string]map[string]*sources.Column{
+		"shop.orders": {
+			"id":      {Column: "id", ColumnOrdPos: 1, DataType: "int"},
+			"amount":  {Column: "amount", ColumnOrdPos: 2, DataType: "decimal", NumericPrecision: null.IntFrom(10), NumericScale: null.IntFrom(2), Nullable: true},
+			"created": {Column: "created", ColumnOrdPos: 3, DataType: "datetime"},
+			"note":    {Column: "note", ColumnOrdPos: 4, DataType: "varchar", Nullable: true},
+		},
+	})
+
+	payload, _, err := a.Serialize(events.LookatchEvent{
+		Header: events.LookatchHeader{EventType: "MysqlCDC", SourceName: "mysql"},
+		Payload: events.SQLEvent{
+			Environment: "prod",
+			Database:    "shop",
+			Table:       "orders",
+			Method:      "insert",
+			Statement: map[string]interface{}{
+				"id":      int64(1),
+				"amount":  "12.50",
+				"created": "2020-09-13T12:26:40Z",
+			},
+			Offset: &events.Offset{Source: "mysql-bin.000003:154:", Agent: "1"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) != 1 {
+		t.Fatal(schemas)
+	}
+
+	record := decodeAvro(t, payload, schemas[0], 1)
+	if record["table"] != "orders" || record["method"] != "insert" || record["old_statement"] != nil {
+		t.Error(record)
+	}
+	statement := record["statement"].(map[string]interface{})["lookatch.shop.orders_values"].(map[string]interface{})
+	if statement["id"].(map[string]interface{})["long"] != int64(1) {
+		t.Error(statement["id"])
+	}
+	if statement["amount"].(map[string]interface{})["bytes.decimal"].(*big.Rat).Cmp(big.NewRat(25, 2)) != 0 {
+		t.Error(statement["amount"])
+	}
+	if !statement["created"].(map[string]interface{})["long.timestamp-micros"].(time.Time).Equal(time.Unix(1600000000, 0)) {
+		t.Error(statement["created"])
+	}
+	if statement["note"] != nil {
+		t.Error(statement["note"])
+	}
+}
+
+func TestAvroSchemaEvolution(t *testing.T) {
+	var schemas []string
+	registry := newTestRegistry(t, &schemas)
+	schema := map[string]map[string]*sources.Column{
+		"shop.orders": {
+			"id": {Column: "id", ColumnOrdPos: 1, DataType: "int"},
+		},
+	}
+	a := newTestAvroSerializer(t, registry.URL, schema)
+	event := events.LookatchEvent{
+		Header: events.LookatchHeader{SourceName: "mysql"},
+		Payload: events.SQLEvent{
+			Database:  "shop",
+			Table:     "orders",
+			Method:    "insert",
+			Statement: map[string]interface{}{"id": int64(1)},
+		},
+	}
+
+	_, _, err := a.Serialize(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = a.Serialize(event)
+	if err != nil || len(schemas) != 1 {
+		t.Fatal(err, schemas)
+	}
+
+	// column added to table
+	schema["shop.orders"]["status"] = &sources.Column{Column: "status", ColumnOrdPos: 2, DataType: "varchar"}
+	event.Payload = events.SQLEvent{
+		Database:  "shop",
+		Table:     "orders",
+		Method:    "insert",
+		Statement: map[string]interface{}{"id": int64(2), "status": "paid"},
+	}
+	payload, _, err := a.Serialize(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) != 2 {
+		t.Fatal(schemas)
+	}
+	record := decodeAvro(t, payload, schemas[1], 2)
+	statement := record["statement"].(map[string]interface{})["lookatch.shop.orders_values"].(map[string]interface{})
+	if statement["status"].(map[string]interface{})["string"] != "paid" {
+		t.Error(statement)
+	}
+}
+
+func TestAvroUnknownColumn(t *testing.T) {
+	var schemas []string
+	registry := newTestRegistry(t, &schemas)
+	a := newTestAvroSerializer(t, registry.URL, map[string]map[string]*sources.Column{
+		"shop.orders": {
+			"id": {Column: "id", ColumnOrdPos: 1, DataType: "int"},
+		},
+	})
+
+	// column missing from source schema, its value can't be sent
+	_, _, err := a.Serialize(events.LookatchEvent{
+		Header: events.LookatchHeader{SourceName: "mysql"},
+		Payload: events.SQLEvent{
+			Database:  "shop",
+			Table:     "orders",
+			Method:    "insert",
+			Statement: map[string]interface{}{"id": int64(1), "status": "paid"},
+		},
+	})
+	if err == nil || len(schemas) != 1 {
+		t.Error(err, schemas)
+	}
+}
+
+func TestAvroColumnTypeChanged(t *testing.T) {
+	var schemas []string
+	registry := newTestRegistry(t, &schemas)
+	schema := map[string]map[string]*sources.Column{
+		"shop.orders": {
+			"id":     {Column: "id", ColumnOrdPos: 1, DataType: "int"},
+			"status": {Column: "status", ColumnOrdPos: 2, DataType: "int"},
+		},
+	}
+	a := newTestAvroSerializer(t, registry.URL, schema)
+	event := events.LookatchEvent{
+		Header: events.LookatchHeader{SourceName: "mysql"},
+		Payload: events.SQLEvent{
+			Database:  "shop",
+			Table:     "orders",
+			Method:    "insert",
+			Statement: map[string]interface{}{"id": int64(1), "status": int64(1)},
+		},
+	}
+	_, _, err := a.Serialize(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// type changed, detected at once by value not matching cached type
+	schema["shop.orders"]["status"] = &sources.Column{Column: "status", ColumnOrdPos: 2, DataType: "boolean"}
+	event.Payload = events.SQLEvent{
+		Database:  "shop",
+		Table:     "orders",
+		Method:    "insert",
+		Statement: map[string]interface{}{"id": int64(2), "status": true},
+	}
+	payload, _, err := a.Serialize(event)
+	if err != nil || len(schemas) != 2 {
+		t.Fatal(err, schemas)
+	}
+	record := decodeAvro(t, payload, schemas[1], 2)
+	statement := record["statement"].(map[string]interface{})["lookatch.shop.orders_values"].(map[string]interface{})
+	if statement["status"].(map[string]interface{})["boolean"] != true {
+		t.Error(statement)
+	}
+
+	// type changed, detected on next lookup of source schema
+	schema["shop.orders"]["status"] = &sources.Column{Column: "status", ColumnOrdPos: 2, DataType: "varchar"}
+	a.now = func() time.Time { return time.Now().Add(avroRefreshInterval) }
+	payload, _, err = a.Serialize(event)
+	if err != nil || len(schemas) != 3 {
+		t.Fatal(err, schemas)
+	}
+	record = decodeAvro(t, payload, schemas[2], 3)
+	statement = record["statement"].(map[string]interface{})["lookatch.shop.orders_values"].(map[string]interface{})
+	if statement["status"].(map[string]interface{})["string"] != "true" {
+		t.Error(statement)
+	}
+}
+
+func TestAvroMarshalErrors(t *testing.T) {
+	var schemas []string
+	registry := newTestRegistry(t, &schemas)
+	a := newTestAvroSerializer(t, registry.URL, map[string]map[string]*sources.Column{
+		"shop.orders": {
+			"id": {Column: "id", ColumnOrdPos: 1, DataType: "int"},
+		},
+	})
+
+	_, _, err := a.Serialize(events.LookatchEvent{Payload: events.GenericEvent{Value: "test"}})
+	if err == nil {
+		t.Error("generic event serialized")
+	}
+
+	_, _, err = a.Serialize(events.LookatchEvent{
+		Header:  events.LookatchHeader{SourceName: "mysql"},
+		Payload: events.SQLEvent{Database: "shop", Table: "unknown"},
+	})
+	if err == nil {
+		t.Error("event of unknown table serialized")
+	}
+
+	_, _, err = a.Serialize(events.LookatchEvent{
+		Header:  events.LookatchHeader{SourceName: "mysql"},
+		Payload: events.SQLEvent{Database: "shop", Table: "orders", Statement: map[string]interface{}{"id": "REDACTED"}},
+	})
+	if err == nil {
+		t.Error("invalid value serialized")
+	}
+}
+
+func TestNewAvroSerializerWithoutRegistry(t *testing.T) {
+	_, err := newAvroSerializer(&Sink{Conf: viper.New()})
+	if err == nil {
+		t.Fail()
+	}
+}
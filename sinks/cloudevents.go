@@ -0,0 +1,102 @@
+package sinks
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+// CloudEventsSpecVersion version of CloudEvents specification
+const CloudEventsSpecVersion = "1.0"
+
+// Content types of CloudEvents
+const (
+	CloudEventsContentType = "application/cloudevents+json"
+	JSONContentType        = "application/json"
+)
+
+// CloudEventsHeaderPrefix prefix of attributes sent as headers or properties in binary mode
+const CloudEventsHeaderPrefix = "ce_"
+
+// ContentTypeHeader header of content type of messages
+const ContentTypeHeader = "content-type"
+
+// CloudEvent CloudEvents event, data is the payload of the Lookatch event
+type CloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            string      `json:"time,omitempty"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            interface{} `json:"data"`
+}
+
+// NewCloudEvent convert event to CloudEvent
+// source is /lookatch/<agent uuid>/<source name>, id is the source offset followed by the agent offset
+// events without offset get a random id
+func NewCloudEvent(event events.LookatchEvent) CloudEvent {
+	cloudEvent := CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		Source:          "/lookatch/" + event.Header.Tenant.ID + "/" + event.Header.SourceName,
+		Type:            event.Header.EventType,
+		DataContentType: JSONContentType,
+		Data:            event.Payload,
+	}
+
+	// timestamps of SQL events are in nanoseconds, timestamps of generic events in seconds
+	switch payload := event.Payload.(type) {
+	case events.SQLEvent:
+		cloudEvent.Subject = payload.Database + "." + payload.Table
+		if ts, err := strconv.ParseInt(payload.Timestamp, 10, 64); err == nil {
+			cloudEvent.Time = time.Unix(0, ts).UTC().Format(time.RFC3339Nano)
+		}
+	case events.GenericEvent:
+		if ts, err := strconv.ParseInt(payload.Timestamp, 10, 64); err == nil {
+			cloudEvent.Time = time.Unix(ts, 0).UTC().Format(time.RFC3339Nano)
+		}
+	}
+
+	if offset := event.GetOffset(); offset != nil && (offset.Source != "" || offset.Agent != "") {
+		cloudEvent.ID = offset.Source + "-" + offset.Agent
+	} else {
+		cloudEvent.ID = uuid.New().String()
+	}
+	return cloudEvent
+}
+
+// Binary return data of event and its attributes as headers, for binary mode
+func (c CloudEvent) Binary() ([]byte, map[string]string, error) {
+	data, err := json.Marshal(c.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+	headers := map[string]string{
+		ContentTypeHeader:                       c.DataContentType,
+		CloudEventsHeaderPrefix + "specversion": c.SpecVersion,
+		CloudEventsHeaderPrefix + "id":          c.ID,
+		CloudEventsHeaderPrefix + "source":      c.Source,
+		CloudEventsHeaderPrefix + "type":        c.Type,
+	}
+	if c.Subject != "" {
+		headers[CloudEventsHeaderPrefix+"subject"] = c.Subject
+	}
+	if c.Time != "" {
+		headers[CloudEventsHeaderPrefix+"time"] = c.Time
+	}
+	return data, headers, nil
+}
+
+// Structured return event encoded in JSON and its content type header, for structured mode
+func (c CloudEvent) Structured() ([]byte, map[string]string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, map[string]string{ContentTypeHeader: CloudEventsContentType}, nil
+}
@@ -0,0 +1,111 @@
+package sinks
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+var cloudEventsSQLEvent = events.LookatchEvent{
+	Header: events.LookatchHeader{
+		EventType:  "MysqlCDC",
+		Tenant:     events.LookatchTenantInfo{ID: "agent-uuid", Env: "prod"},
+		SourceName: "mysql",
+	},
+	Payload: events.SQLEvent{
+		Timestamp: "1600000000000000000",
+		Database:  "shop",
+		Table:     "orders",
+		Method:    "insert",
+		Statement: map[string]interface{}{"id": 1},
+		Offset:    &events.Offset{Source: "mysql-bin.000003:154:", Agent: "12"},
+	},
+}
+
+func TestNewCloudEvent(t *testing.T) {
+	cloudEvent := NewCloudEvent(cloudEventsSQLEvent)
+
+	if cloudEvent.SpecVersion != "1.0" || cloudEvent.Type != "MysqlCDC" {
+		t.Error(cloudEvent)
+	}
+	if cloudEvent.Source != "/lookatch/agent-uuid/mysql" {
+		t.Error(cloudEvent.Source)
+	}
+	if cloudEvent.Subject != "shop.orders" {
+		t.Error(cloudEvent.Subject)
+	}
+	if cloudEvent.ID != "mysql-bin.000003:154:-12" {
+		t.Error(cloudEvent.ID)
+	}
+	if cloudEvent.Time != "2020-09-13T12:26:40Z" {
+		t.Error(cloudEvent.Time)
+	}
+}
+
+func TestNewCloudEventWithoutOffset(t *testing.T) {
+	cloudEvent := NewCloudEvent(events.LookatchEvent{
+		Header:  events.LookatchHeader{EventType: "Syslog"},
+		Payload: events.GenericEvent{Value: "test"},
+	})
+
+	if cloudEvent.ID == "" || cloudEvent.Subject != "" || cloudEvent.Time != "" {
+		t.Error(cloudEvent)
+	}
+}
+
+func TestNewCloudEventGenericTimestamp(t *testing.T) {
+	cloudEvent := NewCloudEvent(events.LookatchEvent{
+		Header:  events.LookatchHeader{EventType: "Syslog"},
+		Payload: events.GenericEvent{Timestamp: "1600000000", Value: "test"},
+	})
+
+	// generic events are timestamped in seconds
+	if cloudEvent.Time != "2020-09-13T12:26:40Z" {
+		t.Error(cloudEvent.Time)
+	}
+}
+
+func TestMarshalCloudEventsStructured(t *testing.T) {
+	payload, headers, err := serializeCloudEvents(cloudEventsSQLEvent)
+	if err != nil {
+		t.Error(err)
+	}
+	if headers[ContentTypeHeader] != CloudEventsContentType {
+		t.Error(headers)
+	}
+
+	var structured map[string]interface{}
+	err = json.Unmarshal(payload, &structured)
+	if err != nil {
+		t.Error(err)
+	}
+	if structured["specversion"] != "1.0" || structured["subject"] != "shop.orders" {
+		t.Error(structured)
+	}
+	if structured["data"].(map[string]interface{})["table"] != "orders" {
+		t.Error(structured["data"])
+	}
+}
+
+func TestMarshalCloudEventsBinary(t *testing.T) {
+	payload, headers, err := serializeCloudEventsBinary(cloudEventsSQLEvent)
+	if err != nil {
+		t.Error(err)
+	}
+	if headers[ContentTypeHeader] != JSONContentType || headers["ce_type"] != "MysqlCDC" || headers["ce_source"] != "/lookatch/agent-uuid/mysql" {
+		t.Error(headers)
+	}
+	if headers["ce_id"] != "mysql-bin.000003:154:-12" || headers["ce_subject"] != "shop.orders" {
+		t.Error(headers)
+	}
+
+	var data map[string]interface{}
+	err = json.Unmarshal(payload, &data)
+	if err != nil {
+		t.Error(err)
+	}
+	if data["table"] != "orders" {
+		t.Error(data)
+	}
+}
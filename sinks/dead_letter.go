@@ -0,0 +1,129 @@
+package sinks
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+// DefaultDeadLetterTimeout time given to dead letter sink to read a rejected event
+const DefaultDeadLetterTimeout = 10 * time.Second
+
+// DeadLetter route events rejected by a sink to another sink
+type DeadLetter struct {
+	sync.RWMutex
+	name     string
+	out      chan events.LookatchEvent
+	changed  chan struct{}
+	timeout  time.Duration
+	rejected uint64
+	failed   uint64
+}
+
+// NewDeadLetter create dead letter without target sink
+func NewDeadLetter() *DeadLetter {
+	return &DeadLetter{timeout: DefaultDeadLetterTimeout}
+}
+
+// set target sink of dead letter, a nil channel disable it
+// events being sent to previous target are given up
+func (d *DeadLetter) set(name string, out chan events.LookatchEvent) {
+	d.Lock()
+	if d.changed != nil {
+		close(d.changed)
+	}
+	d.name = name
+	d.out = out
+	d.changed = make(chan struct{})
+	d.Unlock()
+}
+
+// send wrapped event to target sink
+// return false if no target is set, or if target doesn't read event before timeout or is changed
+func (d *DeadLetter) send(event events.LookatchEvent) bool {
+	d.Lock()
+	name, out, changed, timeout := d.name, d.out, d.changed, d.timeout
+	d.rejected++
+	d.Unlock()
+	if out == nil {
+		return false
+	}
+	if timeout <= 0 {
+		timeout = DefaultDeadLetterTimeout
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case out <- event:
+		return true
+	case <-changed:
+		log.WithField("deadLetter", name).Error("Dead letter sink changed, event is lost")
+	case <-timer.C:
+		log.WithField("deadLetter", name).WithField("timeout", timeout).Error("Dead letter sink not reading, event is lost")
+	}
+	d.Lock()
+	d.failed++
+	d.Unlock()
+	return false
+}
+
+// GetTarget return name of target sink, empty if no target is set
+func (d *DeadLetter) GetTarget() string {
+	d.RLock()
+	defer d.RUnlock()
+	if d.out == nil {
+		return ""
+	}
+	return d.name
+}
+
+// getRejected return number of rejected events
+func (d *DeadLetter) getRejected() uint64 {
+	d.RLock()
+	defer d.RUnlock()
+	return d.rejected
+}
+
+// getFailed return number of rejected events which could not be sent to dead letter sink
+func (d *DeadLetter) getFailed() uint64 {
+	d.RLock()
+	defer d.RUnlock()
+	return d.failed
+}
+
+// SetDeadLetter send next rejected events to sink
+func (s *Sink) SetDeadLetter(sinkName string, out chan events.LookatchEvent) {
+	if s.DeadLetter == nil {
+		s.DeadLetter = NewDeadLetter()
+	}
+	s.DeadLetter.set(sinkName, out)
+}
+
+// Reject send event to dead letter sink with the reason of its rejection
+// return false if no dead letter sink is set, the event is then lost
+func (s *Sink) Reject(event events.LookatchEvent, reason error) bool {
+	log.WithError(reason).WithField("sink", s.Name).Warn("Event rejected")
+	if s.DeadLetter == nil {
+		return false
+	}
+
+	var offset *events.Offset
+	if o := event.GetOffset(); o != nil {
+		cp := *o
+		offset = &cp
+	}
+	return s.DeadLetter.send(events.LookatchEvent{
+		Header: event.Header,
+		Payload: events.DeadLetterEvent{
+			Reason:    reason.Error(),
+			Sink:      s.Name,
+			Timestamp: time.Now().Unix(),
+			Offset:    offset,
+			Event:     event,
+		},
+	})
+}
@@ -0,0 +1,116 @@
+package sinks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+func TestRejectWithoutDeadLetter(t *testing.T) {
+	s := &Sink{Name: "default", DeadLetter: NewDeadLetter()}
+
+	if s.Reject(events.LookatchEvent{Payload: events.GenericEvent{}}, errors.New("test")) {
+		t.Fail()
+	}
+	if s.DeadLetter.getRejected() != 1 {
+		t.Fail()
+	}
+}
+
+func TestReject(t *testing.T) {
+	out := make(chan events.LookatchEvent, 1)
+	s := &Sink{Name: "default", DeadLetter: NewDeadLetter()}
+	s.SetDeadLetter("errors", out)
+
+	offset := &events.Offset{Source: "1", Agent: "1"}
+	event := events.LookatchEvent{
+		Header:  events.LookatchHeader{EventType: "test"},
+		Payload: events.GenericEvent{Value: "test", Offset: offset},
+	}
+	if !s.Reject(event, errors.New("too large")) {
+		t.Fatal("event not rejected")
+	}
+
+	rejected := <-out
+	deadLetter, ok := rejected.Payload.(events.DeadLetterEvent)
+	if !ok {
+		t.Fatal("payload is not a dead letter event")
+	}
+	if rejected.Header.EventType != "test" || deadLetter.Reason != "too large" || deadLetter.Sink != "default" || deadLetter.Timestamp == 0 {
+		t.Fail()
+	}
+	if deadLetter.Offset == nil || deadLetter.Offset.Source != "1" || deadLetter.Offset == offset {
+		t.Error("offset not copied")
+	}
+	if deadLetter.Event.Payload.(events.GenericEvent).Value != "test" {
+		t.Fail()
+	}
+}
+
+func TestStdoutReject(t *testing.T) {
+	commits := make(chan interface{}, 1)
+	deadLetter := make(chan events.LookatchEvent, 1)
+	r, err := NewStdout(&Sink{make(chan events.LookatchEvent, 1), make(chan error), commits, "Stdout", "", vStdout.Sub("sinks.default"), SinkStatusWaiting, NewDeadLetter(), nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetDeadLetter("errors", deadLetter)
+
+	err = r.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.GetInputChan() <- events.LookatchEvent{
+		Payload: events.GenericEvent{
+			Value: make(chan int),
+			Offset: &events.Offset{
+				Source: "1",
+			},
+		},
+	}
+	if _, ok := (<-deadLetter).Payload.(events.DeadLetterEvent); !ok {
+		t.Fail()
+	}
+	if <-commits != (Ack{Offset: "1"}) {
+		t.Fail()
+	}
+
+	if r.Stop() != nil {
+		t.Fail()
+	}
+}
+
+func TestRejectDeadLetterBlocked(t *testing.T) {
+	out := make(chan events.LookatchEvent)
+	s := &Sink{Name: "default", DeadLetter: NewDeadLetter()}
+	s.SetDeadLetter("errors", out)
+	s.DeadLetter.timeout = 10 * time.Millisecond
+
+	// dead letter sink is stopped and doesn't read its input
+	if s.Reject(events.LookatchEvent{Payload: events.GenericEvent{}}, errors.New("test")) {
+		t.Error("event sent to stopped dead letter sink")
+	}
+
+	s.DeadLetter.timeout = time.Minute
+	rejected := make(chan bool)
+	go func() {
+		rejected <- s.Reject(events.LookatchEvent{Payload: events.GenericEvent{}}, errors.New("test"))
+	}()
+	// dead letter sink is removed while event is being sent
+	time.Sleep(10 * time.Millisecond)
+	s.SetDeadLetter("", nil)
+	select {
+	case ok := <-rejected:
+		if ok {
+			t.Error("event sent to removed dead letter sink")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reject blocked")
+	}
+	if s.DeadLetter.getRejected() != 2 || s.DeadLetter.getFailed() == 0 {
+		t.Error(s.DeadLetter.getRejected(), s.DeadLetter.getFailed())
+	}
+}
@@ -0,0 +1,147 @@
+package sinks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+// DebeziumVersion version set in source block of Debezium events
+const DebeziumVersion = "lookatch"
+
+// Debezium operations
+const (
+	DebeziumOpCreate = "c"
+	DebeziumOpUpdate = "u"
+	DebeziumOpDelete = "d"
+	DebeziumOpRead   = "r"
+)
+
+// Debezium connectors
+const (
+	DebeziumMySQL      = "mysql"
+	DebeziumPostgreSQL = "postgresql"
+	DebeziumSQLServer  = "sqlserver"
+)
+
+// debeziumConnectors connector of SQL sources event types
+var debeziumConnectors = map[string]string{
+	"MysqlCDC":        DebeziumMySQL,
+	"MysqlQuery":      DebeziumMySQL,
+	"PostgresqlCDC":   DebeziumPostgreSQL,
+	"PostgresqlQuery": DebeziumPostgreSQL,
+	"SqlserverCDC":    DebeziumSQLServer,
+	"SqlserverQuery":  DebeziumSQLServer,
+}
+
+type (
+	// DebeziumEvent Debezium change event envelope, without schema
+	DebeziumEvent struct {
+		Before map[string]interface{} `json:"before"`
+		After  map[string]interface{} `json:"after"`
+		Source DebeziumSource         `json:"source"`
+		Op     string                 `json:"op"`
+		TsMs   int64                  `json:"ts_ms"`
+	}
+
+	// DebeziumSource source block of Debezium event
+	// offset fields set depend on connector: file and pos or gtid for MySQL,
+	// lsn for PostgreSQL, commit_lsn for SQL Server
+	DebeziumSource struct {
+		Version   string `json:"version"`
+		Connector string `json:"connector"`
+		Name      string `json:"name"`
+		TsMs      int64  `json:"ts_ms"`
+		Snapshot  string `json:"snapshot"`
+		DB        string `json:"db"`
+		Schema    string `json:"schema,omitempty"`
+		Table     string `json:"table"`
+		File      string `json:"file,omitempty"`
+		Pos       uint64 `json:"pos,omitempty"`
+		GTID      string `json:"gtid,omitempty"`
+		LSN       uint64 `json:"lsn,omitempty"`
+		CommitLSN string `json:"commit_lsn,omitempty"`
+	}
+)
+
+// NewDebeziumEvent convert SQL event of source eventType to Debezium envelope
+func NewDebeziumEvent(eventType string, sqlEvent events.SQLEvent) DebeziumEvent {
+	debeziumEvent := DebeziumEvent{
+		Source: DebeziumSource{
+			Version:   DebeziumVersion,
+			Connector: debeziumConnectors[eventType],
+			Name:      sqlEvent.Environment,
+			TsMs:      nanoToMilli(sqlEvent.Timestamp),
+			Snapshot:  "false",
+			DB:        sqlEvent.Database,
+			Schema:    sqlEvent.Schema,
+			Table:     sqlEvent.Table,
+		},
+		TsMs: time.Now().UnixNano() / int64(time.Millisecond),
+	}
+
+	switch sqlEvent.Method {
+	case "insert":
+		debeziumEvent.Op = DebeziumOpCreate
+		debeziumEvent.After = sqlEvent.Statement
+	case "update":
+		debeziumEvent.Op = DebeziumOpUpdate
+		debeziumEvent.Before = sqlEvent.OldStatement
+		debeziumEvent.After = sqlEvent.Statement
+	case "delete":
+		// deleted row is the statement of delete events
+		debeziumEvent.Op = DebeziumOpDelete
+		debeziumEvent.Before = sqlEvent.OldStatement
+		if debeziumEvent.Before == nil {
+			debeziumEvent.Before = sqlEvent.Statement
+		}
+	default:
+		debeziumEvent.Op = DebeziumOpRead
+		debeziumEvent.Source.Snapshot = "true"
+		debeziumEvent.After = sqlEvent.Statement
+	}
+
+	if sqlEvent.Offset != nil && sqlEvent.Offset.Source != "" {
+		debeziumEvent.Source.setOffset(sqlEvent.Offset.Source)
+	}
+	return debeziumEvent
+}
+
+// setOffset set offset fields of connector from source offset
+func (s *DebeziumSource) setOffset(offset string) {
+	switch s.Connector {
+	case DebeziumMySQL:
+		// binlog offsets are formatted as file:pos:, other ones are GTID sets
+		parts := strings.Split(offset, ":")
+		if len(parts) == 3 && parts[2] == "" {
+			if pos, err := strconv.ParseUint(parts[1], 10, 64); err == nil {
+				s.File = parts[0]
+				s.Pos = pos
+				return
+			}
+		}
+		s.GTID = offset
+	case DebeziumPostgreSQL:
+		var hi, lo uint32
+		if _, err := fmt.Sscanf(offset, "%X/%X", &hi, &lo); err == nil {
+			s.LSN = uint64(hi)<<32 | uint64(lo)
+		}
+	case DebeziumSQLServer:
+		// LSN are hex encoded on 10 bytes, Debezium format them as 00000027:00000758:0003
+		if len(offset) == 20 {
+			s.CommitLSN = offset[:8] + ":" + offset[8:16] + ":" + offset[16:]
+		}
+	}
+}
+
+// nanoToMilli convert timestamp in nanoseconds to milliseconds, 0 if it can't be parsed
+func nanoToMilli(timestamp string) int64 {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return ts / int64(time.Millisecond)
+}
@@ -0,0 +1,132 @@
+package sinks
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+func TestNewDebeziumEventMysqlBinlog(t *testing.T) {
+	event := NewDebeziumEvent("MysqlCDC", events.SQLEvent{
+		Environment:  "prod",
+		Timestamp:    "1600000000000000000",
+		Database:     "shop",
+		Table:        "orders",
+		Method:       "update",
+		Statement:    map[string]interface{}{"id": 1, "status": "paid"},
+		OldStatement: map[string]interface{}{"id": 1, "status": "new"},
+		Offset:       &events.Offset{Source: "mysql-bin.000003:154:"},
+	})
+
+	if event.Op != DebeziumOpUpdate || event.Before["status"] != "new" || event.After["status"] != "paid" {
+		t.Error(event)
+	}
+	if event.Source.Connector != DebeziumMySQL || event.Source.DB != "shop" || event.Source.Table != "orders" || event.Source.Name != "prod" {
+		t.Error(event.Source)
+	}
+	if event.Source.File != "mysql-bin.000003" || event.Source.Pos != 154 || event.Source.GTID != "" {
+		t.Error(event.Source)
+	}
+	if event.Source.TsMs != 1600000000000 || event.Source.Snapshot != "false" {
+		t.Error(event.Source)
+	}
+}
+
+func TestNewDebeziumEventMysqlGTID(t *testing.T) {
+	gtid := "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"
+	event := NewDebeziumEvent("MysqlCDC", events.SQLEvent{
+		Method:    "insert",
+		Statement: map[string]interface{}{"id": 1},
+		Offset:    &events.Offset{Source: gtid},
+	})
+
+	if event.Op != DebeziumOpCreate || event.Before != nil || event.After["id"] != 1 {
+		t.Error(event)
+	}
+	if event.Source.GTID != gtid || event.Source.File != "" {
+		t.Error(event.Source)
+	}
+}
+
+func TestNewDebeziumEventPostgreSQL(t *testing.T) {
+	event := NewDebeziumEvent("PostgresqlCDC", events.SQLEvent{
+		Database:  "shop",
+		Schema:    "public",
+		Table:     "orders",
+		Method:    "delete",
+		Statement: map[string]interface{}{"id": 1},
+		Offset:    &events.Offset{Source: "16/B374D848"},
+	})
+
+	if event.Op != DebeziumOpDelete || event.After != nil || event.Before["id"] != 1 {
+		t.Error(event)
+	}
+	if event.Source.Connector != DebeziumPostgreSQL || event.Source.Schema != "public" {
+		t.Error(event.Source)
+	}
+	if event.Source.LSN != 0x16B374D848 {
+		t.Error(event.Source.LSN)
+	}
+}
+
+func TestNewDebeziumEventSQLServer(t *testing.T) {
+	event := NewDebeziumEvent("SqlserverCDC", events.SQLEvent{
+		Method:    "insert",
+		Statement: map[string]interface{}{"id": 1},
+		Offset:    &events.Offset{Source: "00000027000007580003"},
+	})
+
+	if event.Source.Connector != DebeziumSQLServer || event.Source.CommitLSN != "00000027:00000758:0003" {
+		t.Error(event.Source)
+	}
+}
+
+func TestNewDebeziumEventQuery(t *testing.T) {
+	event := NewDebeziumEvent("PostgresqlQuery", events.SQLEvent{
+		Method:    "query",
+		Statement: map[string]interface{}{"id": 1},
+		Offset:    &events.Offset{Agent: "0"},
+	})
+
+	if event.Op != DebeziumOpRead || event.Source.Snapshot != "true" || event.After["id"] != 1 {
+		t.Error(event)
+	}
+	if event.Source.Connector != DebeziumPostgreSQL || event.Source.LSN != 0 {
+		t.Error(event.Source)
+	}
+}
+
+func TestMarshalDebezium(t *testing.T) {
+	event := events.LookatchEvent{
+		Header: events.LookatchHeader{EventType: "MysqlCDC"},
+		Payload: events.SQLEvent{
+			Method:    "insert",
+			Statement: map[string]interface{}{"id": 1},
+		},
+	}
+
+	payload, _, err := serializeDebezium(event)
+	if err != nil {
+		t.Error(err)
+	}
+	var envelope map[string]interface{}
+	err = json.Unmarshal(payload, &envelope)
+	if err != nil {
+		t.Error(err)
+	}
+	if envelope["op"] != "c" || envelope["before"] != nil || envelope["source"].(map[string]interface{})["connector"] != "mysql" {
+		t.Error(envelope)
+	}
+
+	generic := events.LookatchEvent{Payload: events.GenericEvent{Value: "test"}}
+	payload, _, err = serializeDebezium(generic)
+	if err != nil {
+		t.Error(err)
+	}
+	envelope = nil
+	err = json.Unmarshal(payload, &envelope)
+	if err != nil || envelope["value"] != "test" {
+		t.Error(envelope, err)
+	}
+}
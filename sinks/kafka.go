@@ -2,7 +2,8 @@ package sinks
 
 import (
 	"encoding/binary"
-	"encoding/json"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/Shopify/sarama"
@@ -10,7 +11,6 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/Pirionfr/lookatch-agent/events"
-	"github.com/Pirionfr/lookatch-agent/utils"
 )
 
 // KafkaType type of sink
@@ -59,12 +59,22 @@ type (
 		Offset *events.Offset
 		// The actual serialized message to store In Kafka.
 		Value []byte
+		// The record headers set by the format of the sink
+		Headers map[string]string
+		// The original event, sent to dead letter sink if message is rejected
+		Event events.LookatchEvent
 	}
 
 	// Kafka representation of kafka sink
 	Kafka struct {
 		*Sink
 		KafkaConf *KafkaSinkConfig
+		// serializer of events, deadLetterSerializer of events rejected by other sinks
+		serializer           Serializer
+		deadLetterSerializer Serializer
+		done                 chan struct{}
+		consumer             sync.WaitGroup
+		producers            sync.WaitGroup
 	}
 )
 
@@ -76,10 +86,20 @@ func NewKafka(s *Sink) (SinkI, error) {
 		return nil, err
 	}
 
-	return &Kafka{
+	k := &Kafka{
 		Sink:      s,
 		KafkaConf: ksConf,
-	}, nil
+	}
+	encryptionEncoding := getEncryptionEncoding(s.Conf, EncryptionEncodingBase64)
+	k.serializer, err = s.newSerializer(getFormat(s.Conf, FormatJSON), encryptionEncoding)
+	if err != nil {
+		return nil, err
+	}
+	k.deadLetterSerializer, err = s.newSerializer(FormatJSON, encryptionEncoding)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
 }
 
 // Start kafka sink
@@ -91,56 +111,105 @@ func (k *Kafka) Start(_ ...interface{}) error {
 		"NbProducer": k.KafkaConf.NbProducer,
 	}).Debug("Starting sink producers")
 
+	k.done = make(chan struct{})
 	for x := 0; x < k.KafkaConf.NbProducer; x++ {
-		go k.StartProducer(resendChan, k.Stop)
+		k.producers.Add(1)
+		go func() {
+			defer k.producers.Done()
+			k.StartProducer(resendChan, k.Stopper)
+		}()
 	}
 
 	log.WithField("threshold", k.KafkaConf.MaxMessageBytes).Debug("KafkaSink: started with threshold")
 
-	go k.StartConsumer(resendChan)
+	k.consumer.Add(1)
+	go func() {
+		defer k.consumer.Done()
+		k.StartConsumer(resendChan)
+	}()
 
 	//Send empty event every Minutes as to flush buffer
 	ticker := time.NewTicker(time.Second * 10)
-	go func() {
-		for range ticker.C {
-			resendChan <- &KafkaMessage{}
+	go func(done chan struct{}) {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				select {
+				case resendChan <- &KafkaMessage{}:
+				case <-done:
+					return
+				}
+			}
 		}
-	}()
+	}(k.done)
 
+	k.Status = SinkStatusRunning
 	return nil
 }
 
-// StartConsumer consume input chan
+// Stop kafka sink
+// consumer stops reading events, then pending batches are sent and committed before producers are closed
+func (k *Kafka) Stop() error {
+	if k.done == nil {
+		return nil
+	}
+	close(k.done)
+	k.consumer.Wait()
+	k.producers.Wait()
+	k.done = nil
+	k.Status = SinkStatusWaiting
+	return nil
+}
+
+// StartConsumer consume input chan until sink is stopped
+// an event read while producers are stopping isn't sent, its offset isn't committed so it is read again after restart
 func (k *Kafka) StartConsumer(kafkaChan chan *KafkaMessage) {
-	for eventMsg := range k.In {
+	done := k.done
+	for {
+		var eventMsg events.LookatchEvent
+		select {
+		case <-done:
+			return
+		case eventMsg = <-k.In:
+		}
+		var producerMsg *KafkaMessage
+		var err error
 		switch typedMsg := eventMsg.Payload.(type) {
 		case events.SQLEvent:
-			producerMsg, err := k.ProcessSQLEvent(&typedMsg)
-			if err != nil {
-				break
-			}
-			kafkaChan <- producerMsg
+			producerMsg, err = k.ProcessSQLEvent(eventMsg.Header, &typedMsg)
 		case events.GenericEvent:
-			producerMsg, err := k.ProcessGenericEvent(&typedMsg)
-			if err != nil {
-				break
-			}
-			kafkaChan <- producerMsg
+			producerMsg, err = k.ProcessGenericEvent(eventMsg.Header, &typedMsg)
+		case events.DeadLetterEvent:
+			producerMsg, err = k.ProcessDeadLetterEvent(&typedMsg)
 		default:
 			log.WithField("message", eventMsg).Warn("KafkaSink: event doesn't match any known type: ")
+			err = errors.Errorf("unknown event type %T", eventMsg.Payload)
+		}
+		if err != nil {
+			k.Reject(eventMsg, err)
+			continue
+		}
+		producerMsg.Event = eventMsg
+		select {
+		case kafkaChan <- producerMsg:
+		case <-done:
+			return
 		}
 	}
 }
 
 // ProcessGenericEvent process Generic Event
-func (k *Kafka) ProcessGenericEvent(genericMsg *events.GenericEvent) (*KafkaMessage, error) {
+func (k *Kafka) ProcessGenericEvent(header events.LookatchHeader, genericMsg *events.GenericEvent) (*KafkaMessage, error) {
 	var topic string
 	if len(k.KafkaConf.Topic) == 0 {
 		topic = k.KafkaConf.TopicPrefix + genericMsg.Environment
 	} else {
 		topic = k.KafkaConf.Topic
 	}
-	serializedEventPayload, err := json.Marshal(genericMsg)
+	serializedEventPayload, headers, err := k.serializer.Serialize(events.LookatchEvent{Header: header, Payload: *genericMsg})
 	if err != nil {
 		log.WithError(err).Error("KafkaSink Marshal Error")
 		return nil, err
@@ -151,15 +220,40 @@ func (k *Kafka) ProcessGenericEvent(genericMsg *events.GenericEvent) (*KafkaMess
 	}
 
 	return &KafkaMessage{
-		Topic:  topic,
-		Key:    key,
-		Value:  serializedEventPayload,
-		Offset: genericMsg.Offset,
+		Topic:   topic,
+		Key:     key,
+		Value:   serializedEventPayload,
+		Headers: headers,
+		Offset:  genericMsg.Offset,
+	}, nil
+}
+
+// ProcessDeadLetterEvent process event rejected by a sink
+func (k *Kafka) ProcessDeadLetterEvent(deadLetterEvent *events.DeadLetterEvent) (*KafkaMessage, error) {
+	var topic string
+	if len(k.KafkaConf.Topic) == 0 {
+		topic = k.KafkaConf.TopicPrefix + deadLetterEvent.Event.Header.Tenant.Env + "_dead_letter"
+	} else {
+		topic = k.KafkaConf.Topic
+	}
+
+	serializedEventPayload, headers, err := k.deadLetterSerializer.Serialize(events.LookatchEvent{Payload: *deadLetterEvent})
+	if err != nil {
+		log.WithError(err).Error("KafkaSink Marshal Error")
+		return nil, err
+	}
+
+	return &KafkaMessage{
+		Topic:   topic,
+		Key:     deadLetterEvent.Sink,
+		Value:   serializedEventPayload,
+		Headers: headers,
 	}, nil
 }
 
 // ProcessSQLEvent process Sql Event
-func (k *Kafka) ProcessSQLEvent(sqlEvent *events.SQLEvent) (*KafkaMessage, error) {
+// header gives the source of the event to Debezium and CloudEvents formats
+func (k *Kafka) ProcessSQLEvent(header events.LookatchHeader, sqlEvent *events.SQLEvent) (*KafkaMessage, error) {
 	var topic string
 	if len(k.KafkaConf.Topic) == 0 {
 		topic = k.KafkaConf.TopicPrefix + sqlEvent.Environment + "_" + sqlEvent.Database
@@ -167,7 +261,7 @@ func (k *Kafka) ProcessSQLEvent(sqlEvent *events.SQLEvent) (*KafkaMessage, error
 		topic = k.KafkaConf.Topic
 	}
 
-	serializedEventPayload, err := json.Marshal(sqlEvent)
+	serializedEventPayload, headers, err := k.serializer.Serialize(events.LookatchEvent{Header: header, Payload: *sqlEvent})
 	if err != nil {
 		log.WithError(err).Error("KafkaSink Marshal Error")
 		return nil, err
@@ -176,7 +270,7 @@ func (k *Kafka) ProcessSQLEvent(sqlEvent *events.SQLEvent) (*KafkaMessage, error
 	if k.KafkaConf.ShuffleEvent {
 		key += sqlEvent.Offset.Agent
 	}
-	producerMsg := &KafkaMessage{Topic: topic, Key: key, Value: serializedEventPayload, Offset: sqlEvent.Offset}
+	producerMsg := &KafkaMessage{Topic: topic, Key: key, Value: serializedEventPayload, Headers: headers, Offset: sqlEvent.Offset}
 
 	return producerMsg, nil
 }
@@ -220,19 +314,24 @@ func (k *Kafka) StartProducer(in chan *KafkaMessage, stop chan error) {
 
 	if err := saramaConf.Validate(); err != nil {
 		errMsg := "StartProducer: sarama configuration not valid : "
+		k.Status = SinkStatusOnError
 		stop <- errors.Annotate(err, errMsg)
+		return
 	}
 	producer, err := sarama.NewSyncProducer(k.KafkaConf.Brokers, saramaConf)
 	if err != nil {
 		errMsg := "Error when Initialize NewSyncProducer"
+		k.Status = SinkStatusOnError
 		stop <- errors.Annotate(err, errMsg)
+		return
 	}
 
 	log.Debug("StartProducer: New SyncProducer created")
 
 	defer func() {
 		if err := producer.Close(); err != nil {
-			stop <- errors.Annotate(err, "Error while closing kafka Producer")
+			log.WithError(err).Error("Error while closing kafka Producer")
+			return
 		}
 		log.Debug("Successfully Closed kafka Producer")
 	}()
@@ -240,6 +339,8 @@ func (k *Kafka) StartProducer(in chan *KafkaMessage, stop chan error) {
 	k.ProducerLoop(producer, in)
 }
 
+// ProducerLoop batch messages and send them to kafka
+// when sink is stopped, remaining messages are sent and committed before returning
 func (k *Kafka) ProducerLoop(producer sarama.SyncProducer, in chan *KafkaMessage) {
 	var (
 		msg                *KafkaMessage
@@ -247,60 +348,83 @@ func (k *Kafka) ProducerLoop(producer sarama.SyncProducer, in chan *KafkaMessage
 		msgs               []*sarama.ProducerMessage
 		lastSend, timepass int64
 		msgsSize, msgSize  int
-		lastOffset         *events.Offset
+		stopping           bool
 	)
+	// last offset of batch for each source, events of several sources may be batched together
+	lastOffsets := make(map[string]*events.Offset)
+	done := k.done
 	lastSend = time.Now().Unix()
 	for {
-		select {
-		case msg = <-in:
-			if msg.Value != nil {
-				saramaMsg = &sarama.ProducerMessage{Topic: msg.Topic, Key: sarama.ByteEncoder(msg.Key)}
-				if len(k.EncryptionKey) > 0 {
-					result, err := utils.EncryptString(string(msg.Value), k.EncryptionKey)
-					if err != nil {
-						log.WithError(err).Error("KafkaSink Encrypt Error")
-						continue
-					}
-					saramaMsg.Value = sarama.ByteEncoder([]byte(result))
-				} else {
-					saramaMsg.Value = sarama.ByteEncoder(msg.Value)
+		if stopping {
+			select {
+			case msg = <-in:
+			default:
+				if len(msgs) > 0 {
+					SendMsg(msgs, producer)
+					k.commitOffsets(lastOffsets)
 				}
+				log.Info("StartProducer: Signal received, closing Producer")
+				return
+			}
+		} else {
+			select {
+			case msg = <-in:
+			case <-done:
+				stopping = true
+				continue
+			}
+		}
 
-				//calcul size
-				msgSize = MsgByteSize(saramaMsg)
-				if msgSize > k.KafkaConf.MaxMessageBytes {
-					log.Warn("Skip Message")
-					continue
-				}
-				if msgsSize+msgSize < k.KafkaConf.MaxMessageBytes {
-					msgs = append(msgs, saramaMsg)
-					lastOffset = msg.Offset
-					msgsSize += msgSize
-				} else {
-					lastSend = SendMsg(msgs, producer)
-					k.SendCommit(lastOffset)
-					msgs = []*sarama.ProducerMessage{}
-					msgs = append(msgs, saramaMsg)
-					msgsSize = msgSize
-				}
+		if msg.Value != nil {
+			saramaMsg = &sarama.ProducerMessage{
+				Topic:   msg.Topic,
+				Key:     sarama.ByteEncoder(msg.Key),
+				Value:   sarama.ByteEncoder(msg.Value),
+				Headers: RecordHeaders(msg.Headers),
+			}
+
+			//calcul size
+			msgSize = MsgByteSize(saramaMsg)
+			if msgSize > k.KafkaConf.MaxMessageBytes {
+				log.Warn("Skip Message")
+				k.Reject(msg.Event, errors.Errorf("message size %d exceeds max message bytes %d", msgSize, k.KafkaConf.MaxMessageBytes))
+				continue
 			}
-			//use to clear slice
-			now := time.Now().Unix()
-			timepass = now - lastSend
-			if timepass >= 1 {
+			if msgsSize+msgSize < k.KafkaConf.MaxMessageBytes {
+				msgs = append(msgs, saramaMsg)
+				msgsSize += msgSize
+			} else {
 				lastSend = SendMsg(msgs, producer)
-				k.SendCommit(lastOffset)
+				k.commitOffsets(lastOffsets)
 				msgs = []*sarama.ProducerMessage{}
-				msgsSize = 0
+				msgs = append(msgs, saramaMsg)
+				msgsSize = msgSize
 			}
-
-		case <-k.Stop:
-			log.Info("StartProducer: Signal received, closing Producer")
-			return
+			// dead letter events have no offset to commit
+			if msg.Offset != nil {
+				lastOffsets[msg.Event.Header.SourceName] = msg.Offset
+			}
+		}
+		//use to clear slice
+		now := time.Now().Unix()
+		timepass = now - lastSend
+		if timepass >= 1 {
+			lastSend = SendMsg(msgs, producer)
+			k.commitOffsets(lastOffsets)
+			msgs = []*sarama.ProducerMessage{}
+			msgsSize = 0
 		}
 	}
 }
 
+// commitOffsets commit last sent offset of each source, then clear them
+func (k *Kafka) commitOffsets(lastOffsets map[string]*events.Offset) {
+	for sourceName, offset := range lastOffsets {
+		k.SendCommit(sourceName, offset)
+		delete(lastOffsets, sourceName)
+	}
+}
+
 func SendMsg(msgs []*sarama.ProducerMessage, producer sarama.SyncProducer) int64 {
 	retries := 0
 	err := producer.SendMessages(msgs)
@@ -323,6 +447,23 @@ func SendMsg(msgs []*sarama.ProducerMessage, producer sarama.SyncProducer) int64
 	return time.Now().Unix()
 }
 
+// RecordHeaders convert headers to kafka record headers, sorted by key
+func RecordHeaders(headers map[string]string) []sarama.RecordHeader {
+	if len(headers) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	recordHeaders := make([]sarama.RecordHeader, 0, len(keys))
+	for _, key := range keys {
+		recordHeaders = append(recordHeaders, sarama.RecordHeader{Key: []byte(key), Value: []byte(headers[key])})
+	}
+	return recordHeaders
+}
+
 func MsgByteSize(msg *sarama.ProducerMessage) int {
 	// the metadata overhead of CRC, flags, etc.
 	size := 26
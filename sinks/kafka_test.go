@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/Pirionfr/lookatch-agent/events"
+	"github.com/Shopify/sarama/mocks"
 	"github.com/spf13/viper"
 
 	"strconv"
@@ -51,7 +52,7 @@ func init() {
 
 func TestBuildKafkaSinkConfig(t *testing.T) {
 
-	sink = &Sink{eventChan, stop, commitChan, "kafka", "", vKafka.Sub("sinks.kafka")}
+	sink = &Sink{eventChan, stop, commitChan, "kafka", "", vKafka.Sub("sinks.kafka"), SinkStatusWaiting, nil, nil}
 
 	ksink, err := NewKafka(sink)
 	if err != nil {
@@ -80,7 +81,7 @@ func TestBuildKafkaSinkConfig(t *testing.T) {
 func TestBuildKafkaSinkConfigTopicSet(t *testing.T) {
 
 	vKafka.Set("sinks.kafka.topic", "test")
-	sink = &Sink{eventChan, stop, commitChan, "kafka", "", vKafka.Sub("sinks.kafka")}
+	sink = &Sink{eventChan, stop, commitChan, "kafka", "", vKafka.Sub("sinks.kafka"), SinkStatusWaiting, nil, nil}
 
 	ksink, err := NewKafka(sink)
 	if err != nil {
@@ -96,7 +97,7 @@ func TestBuildKafkaSinkConfigTopicSet(t *testing.T) {
 func TestBuildKafkaSinktls(t *testing.T) {
 
 	vKafka.Set("sinks.kafka.tls", false)
-	sink = &Sink{eventChan, stop, commitChan, "kafka", "", vKafka.Sub("sinks.kafka")}
+	sink = &Sink{eventChan, stop, commitChan, "kafka", "", vKafka.Sub("sinks.kafka"), SinkStatusWaiting, nil, nil}
 
 	ksink, err := NewKafka(sink)
 	if err != nil {
@@ -112,7 +113,7 @@ func TestBuildKafkaSinktls(t *testing.T) {
 func TestBuildKafkaSinkClientID(t *testing.T) {
 
 	vKafka.Set("sinks.kafka.client_id", "test")
-	sink = &Sink{eventChan, stop, commitChan, "kafka", "", vKafka.Sub("sinks.kafka")}
+	sink = &Sink{eventChan, stop, commitChan, "kafka", "", vKafka.Sub("sinks.kafka"), SinkStatusWaiting, nil, nil}
 
 	ksink, err := NewKafka(sink)
 	if err != nil {
@@ -127,7 +128,7 @@ func TestBuildKafkaSinkClientID(t *testing.T) {
 
 func TestBuildKafkaSinkSecret(t *testing.T) {
 
-	sink = &Sink{eventChan, stop, commitChan, "kafka", "test", vKafka.Sub("sinks.kafka")}
+	sink = &Sink{eventChan, stop, commitChan, "kafka", "test", vKafka.Sub("sinks.kafka"), SinkStatusWaiting, nil, nil}
 
 	ksink, err := NewKafka(sink)
 	if err != nil {
@@ -141,7 +142,7 @@ func TestBuildKafkaSinkSecret(t *testing.T) {
 }
 
 func TestProcessGenericEvent(t *testing.T) {
-	sink = &Sink{eventChan, stop, commitChan, "kafka", "", vKafka.Sub("sinks.kafka")}
+	sink = &Sink{eventChan, stop, commitChan, "kafka", "", vKafka.Sub("sinks.kafka"), SinkStatusWaiting, nil, nil}
 
 	ksink, err := NewKafka(sink)
 	if err != nil {
@@ -155,7 +156,7 @@ func TestProcessGenericEvent(t *testing.T) {
 		Value:       "test",
 	}
 
-	msg, err := ksink.(*Kafka).ProcessGenericEvent(genericMsg)
+	msg, err := ksink.(*Kafka).ProcessGenericEvent(events.LookatchHeader{}, genericMsg)
 	if err != nil {
 		t.Error(err)
 	}
@@ -166,7 +167,7 @@ func TestProcessGenericEvent(t *testing.T) {
 }
 
 func TestProcessSqlEvent(t *testing.T) {
-	sink = &Sink{eventChan, stop, commitChan, "kafka", "", vKafka.Sub("sinks.kafka")}
+	sink = &Sink{eventChan, stop, commitChan, "kafka", "", vKafka.Sub("sinks.kafka"), SinkStatusWaiting, nil, nil}
 
 	ksink, err := NewKafka(sink)
 	if err != nil {
@@ -187,7 +188,7 @@ func TestProcessSqlEvent(t *testing.T) {
 		},
 	}
 
-	msg, err := ksink.(*Kafka).ProcessSQLEvent(msgSQL)
+	msg, err := ksink.(*Kafka).ProcessSQLEvent(events.LookatchHeader{}, msgSQL)
 	if err != nil {
 		t.Error(err)
 	}
@@ -196,3 +197,92 @@ func TestProcessSqlEvent(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestProducerLoopFlushOnStop(t *testing.T) {
+	commits := make(chan interface{}, 2)
+	ksink, err := NewKafka(&Sink{eventChan, stop, commits, "kafka", "", vKafka.Sub("sinks.kafka"), SinkStatusWaiting, nil, nil})
+	if err != nil {
+		t.Error(err)
+	}
+	k := ksink.(*Kafka)
+
+	producer := mocks.NewSyncProducer(t, nil)
+	producer.ExpectSendMessageAndSucceed()
+	producer.ExpectSendMessageAndSucceed()
+
+	// batch holds events of two sources, each of them gets its offset
+	in := make(chan *KafkaMessage, 2)
+	for _, sourceName := range []string{"mysql", "pg"} {
+		in <- &KafkaMessage{
+			Topic:  "test",
+			Key:    "key",
+			Value:  []byte("test"),
+			Offset: &events.Offset{Source: sourceName + "-1"},
+			Event:  events.LookatchEvent{Header: events.LookatchHeader{SourceName: sourceName}},
+		}
+	}
+
+	k.done = make(chan struct{})
+	close(k.done)
+	k.ProducerLoop(producer, in)
+
+	acks := make(map[Ack]bool)
+	for len(commits) > 0 {
+		acks[(<-commits).(Ack)] = true
+	}
+	if len(acks) != 2 || !acks[Ack{SourceName: "mysql", Offset: "mysql-1"}] || !acks[Ack{SourceName: "pg", Offset: "pg-1"}] {
+		t.Errorf("pending messages not committed, got %v", acks)
+	}
+
+	if err := producer.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStartConsumerStop(t *testing.T) {
+	in := make(chan events.LookatchEvent, 2)
+	ksink, err := NewKafka(&Sink{in, stop, commitChan, "kafka", "", vKafka.Sub("sinks.kafka"), SinkStatusWaiting, nil, nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := ksink.(*Kafka)
+	k.done = make(chan struct{})
+
+	// producers are gone, consumer is blocked on a message nobody reads
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		k.StartConsumer(make(chan *KafkaMessage))
+	}()
+	in <- events.LookatchEvent{Payload: events.GenericEvent{Value: "test", Offset: &events.Offset{Agent: "1"}}}
+	for len(in) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(k.done)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("consumer not stopped")
+	}
+
+	// events sent after stop are left to the next consumer
+	in <- events.LookatchEvent{Payload: events.GenericEvent{Value: "test"}}
+	time.Sleep(10 * time.Millisecond)
+	if len(in) != 1 {
+		t.Error("event consumed after stop")
+	}
+}
+
+func TestRecordHeaders(t *testing.T) {
+	if RecordHeaders(nil) != nil {
+		t.Fail()
+	}
+
+	headers := RecordHeaders(map[string]string{"ce_type": "MysqlCDC", "content-type": "application/json"})
+	if len(headers) != 2 {
+		t.Fatal(headers)
+	}
+	if string(headers[0].Key) != "ce_type" || string(headers[0].Value) != "MysqlCDC" || string(headers[1].Key) != "content-type" {
+		t.Error(headers)
+	}
+}
@@ -2,9 +2,7 @@ package sinks
 
 import (
 	"context"
-	"encoding/json"
 
-	"github.com/Pirionfr/goDcCrypto/crypto"
 	"github.com/apache/pulsar-client-go/pulsar"
 	log "github.com/sirupsen/logrus"
 
@@ -27,7 +25,11 @@ type (
 	Pulsar struct {
 		*Sink
 		PulsarConf *PulsarSinkConfig
+		serializer Serializer
 		Producer   pulsar.Producer
+		client     pulsar.Client
+		done       chan struct{}
+		stopped    chan struct{}
 	}
 )
 
@@ -37,11 +39,17 @@ func NewPulsar(s *Sink) (SinkI, error) {
 	ksConf := &PulsarSinkConfig{}
 	err := s.Conf.Unmarshal(ksConf)
 	if err != nil {
-		return nil, nil
+		return nil, err
+	}
+	// events are sent with their header and encrypted events as raw ciphertext by default
+	serializer, err := s.newSerializer(getFormat(s.Conf, FormatJSONWithHeader), getEncryptionEncoding(s.Conf, EncryptionEncodingRaw))
+	if err != nil {
+		return nil, err
 	}
 	return &Pulsar{
 		Sink:       s,
 		PulsarConf: ksConf,
+		serializer: serializer,
 	}, nil
 }
 
@@ -54,6 +62,7 @@ func (p *Pulsar) Start(_ ...interface{}) error {
 	})
 
 	if err != nil {
+		p.Status = SinkStatusOnError
 		return err
 	}
 
@@ -62,14 +71,36 @@ func (p *Pulsar) Start(_ ...interface{}) error {
 	})
 
 	if err != nil {
+		client.Close()
+		p.Status = SinkStatusOnError
 		return err
 	}
+	p.client = client
 
+	p.done = make(chan struct{})
+	p.stopped = make(chan struct{})
 	go p.StartProducer()
 
+	p.Status = SinkStatusRunning
 	return nil
 }
 
+// Stop stop Producer, flush pending messages and close connection
+func (p *Pulsar) Stop() error {
+	if p.done == nil {
+		return nil
+	}
+	close(p.done)
+	<-p.stopped
+	p.done = nil
+
+	err := p.Producer.Flush()
+	p.Producer.Close()
+	p.client.Close()
+	p.Status = SinkStatusWaiting
+	return err
+}
+
 // GetInputChan return the input channel attached to this sink
 func (p *Pulsar) GetInputChan() chan events.LookatchEvent {
 	return p.In
@@ -77,30 +108,50 @@ func (p *Pulsar) GetInputChan() chan events.LookatchEvent {
 
 // StartConsumer consume input chan
 func (p *Pulsar) StartProducer() {
-
-	for msg := range p.In {
-		err := p.ProcessEvent(msg)
+	defer close(p.stopped)
+	for {
+		var msg events.LookatchEvent
+		select {
+		case <-p.done:
+			return
+		case msg = <-p.In:
+		}
+		payload, properties, err := p.Serialize(msg)
+		if err != nil {
+			if p.Reject(msg, err) {
+				p.SendCommit(msg.Header.SourceName, msg.Payload)
+			}
+			continue
+		}
+		err = p.Send(payload, properties)
 		if err != nil {
 			log.WithError(err).Error("Producer could not send message")
+			continue
 		}
+		p.SendCommit(msg.Header.SourceName, msg.Payload)
 	}
 
 }
 
 // ProcessEvent convert LookatchEvent to  Pulsar ProducerMessage
 func (p *Pulsar) ProcessEvent(msg events.LookatchEvent) error {
-	payload, _ := json.Marshal(msg)
-
-	if len(p.EncryptionKey) > 0 {
-		var err error
-		payload, err = crypto.EncryptBytes(payload, p.EncryptionKey)
-		if err != nil {
-			log.WithError(err).Error("KafkaSink Encrypt Error")
-		}
+	payload, properties, err := p.Serialize(msg)
+	if err != nil {
+		return err
 	}
+	return p.Send(payload, properties)
+}
+
+// Serialize serialize event in format of sink, properties are set by the format and compression of the sink
+func (p *Pulsar) Serialize(msg events.LookatchEvent) ([]byte, map[string]string, error) {
+	return p.serializer.Serialize(msg)
+}
 
+// Send payload to pulsar with its properties
+func (p *Pulsar) Send(payload []byte, properties map[string]string) error {
 	pulsarMsg := &pulsar.ProducerMessage{
-		Payload: payload,
+		Payload:    payload,
+		Properties: properties,
 	}
 
 	_, err := p.Producer.Send(context.Background(), pulsarMsg)
@@ -0,0 +1,72 @@
+package sinks
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/Pirionfr/lookatch-agent/utils"
+)
+
+// decryptRaw decrypt raw ciphertext sent by Pulsar sink: AES CFB with SHA-256 of key, prefixed by IV
+func decryptRaw(t *testing.T, payload []byte, key string) []byte {
+	if len(payload) < aes.BlockSize {
+		t.Fatal("payload too short", payload)
+	}
+	hash := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted := make([]byte, len(payload)-aes.BlockSize)
+	cipher.NewCFBDecrypter(block, payload[:aes.BlockSize]).XORKeyStream(decrypted, payload[aes.BlockSize:])
+	return decrypted
+}
+
+func TestPulsarEncryptedFormat(t *testing.T) {
+	p, err := NewPulsar(&Sink{Conf: viper.New(), EncryptionKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, properties, err := p.(*Pulsar).Serialize(serializerEvent)
+	if err != nil || properties != nil {
+		t.Fatal(err, properties)
+	}
+
+	// raw ciphertext of event and its header, as sent before serializers were shared by sinks
+	expected, err := json.Marshal(serializerEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payload) != aes.BlockSize+len(expected) {
+		t.Fatal("payload is not raw ciphertext", payload)
+	}
+	if decrypted := decryptRaw(t, payload, "key"); string(decrypted) != string(expected) {
+		t.Error(string(decrypted))
+	}
+}
+
+func TestPulsarEncryptedBase64(t *testing.T) {
+	conf := viper.New()
+	conf.Set("encryption_encoding", "base64")
+	p, err := NewPulsar(&Sink{Conf: conf, EncryptionKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _, err := p.(*Pulsar).Serialize(serializerEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := json.Marshal(serializerEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := utils.DecryptString(string(payload), "key")
+	if err != nil || decrypted != string(expected) {
+		t.Error(decrypted, err)
+	}
+}
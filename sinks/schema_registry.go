@@ -0,0 +1,98 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/spf13/viper"
+)
+
+// SchemaRegistryContentType content type of schema registry requests
+const SchemaRegistryContentType = "application/vnd.schemaregistry.v1+json"
+
+// DefaultSchemaRegistryTimeout default timeout of schema registry requests
+const DefaultSchemaRegistryTimeout = "10s"
+
+type (
+	// SchemaRegistryConfig representation of schema registry configuration of a sink
+	SchemaRegistryConfig struct {
+		URL      string `json:"url"`
+		User     string `json:"user"`
+		Password string `json:"password"`
+		Timeout  string `json:"timeout"`
+	}
+
+	// schemaRegistry client of a Confluent compatible schema registry
+	schemaRegistry struct {
+		config SchemaRegistryConfig
+		client *http.Client
+	}
+)
+
+// newSchemaRegistry create client of schema registry set in sink config
+func newSchemaRegistry(conf *viper.Viper) (*schemaRegistry, error) {
+	config := SchemaRegistryConfig{
+		Timeout: DefaultSchemaRegistryTimeout,
+	}
+	err := conf.UnmarshalKey("schema_registry", &config)
+	if err != nil {
+		return nil, errors.Annotate(err, "invalid schema registry")
+	}
+	if config.URL == "" {
+		return nil, errors.New("schema_registry url is required by avro format")
+	}
+	timeout, err := time.ParseDuration(config.Timeout)
+	if err != nil {
+		return nil, errors.Annotate(err, "invalid schema registry timeout")
+	}
+	return &schemaRegistry{
+		config: config,
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// register register schema under subject and return its id
+// registry returns id of the existing version if schema is already registered
+func (r *schemaRegistry) register(subject string, schema string) (uint32, error) {
+	body, err := json.Marshal(map[string]string{"schema": schema})
+	if err != nil {
+		return 0, err
+	}
+	endpoint := strings.TrimSuffix(r.config.URL, "/") + "/subjects/" + url.PathEscape(subject) + "/versions"
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, errors.Annotate(err, "error while creating schema registry request")
+	}
+	req.Header.Set("Content-Type", SchemaRegistryContentType)
+	if r.config.User != "" {
+		req.SetBasicAuth(r.config.User, r.config.Password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, errors.Annotatef(err, "error while registering schema of '%s'", subject)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, errors.Annotatef(err, "error while registering schema of '%s'", subject)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, errors.Errorf("error while registering schema of '%s': %s %s", subject, resp.Status, string(respBody))
+	}
+
+	registered := struct {
+		ID uint32 `json:"id"`
+	}{}
+	err = json.Unmarshal(respBody, &registered)
+	if err != nil {
+		return 0, errors.Annotatef(err, "invalid schema registry response for '%s'", subject)
+	}
+	return registered.ID, nil
+}
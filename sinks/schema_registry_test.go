@@ -0,0 +1,69 @@
+package sinks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestSchemaRegistryRegister(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/subjects/lookatch.shop.orders/versions" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"id": 42}`))
+	}))
+	defer server.Close()
+
+	conf := viper.New()
+	conf.Set("schema_registry.url", server.URL+"/")
+	conf.Set("schema_registry.user", "user")
+	conf.Set("schema_registry.password", "secret")
+	registry, err := newSchemaRegistry(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := registry.register("lookatch.shop.orders", `"string"`)
+	if err != nil || id != 42 {
+		t.Error(id, err)
+	}
+}
+
+func TestSchemaRegistryIncompatible(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error_code": 409, "message": "incompatible schema"}`))
+	}))
+	defer server.Close()
+
+	conf := viper.New()
+	conf.Set("schema_registry.url", server.URL)
+	registry, err := newSchemaRegistry(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = registry.register("lookatch.shop.orders", `"string"`)
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestNewSchemaRegistryInvalidTimeout(t *testing.T) {
+	conf := viper.New()
+	conf.Set("schema_registry.url", "http://localhost:8081")
+	conf.Set("schema_registry.timeout", "soon")
+	_, err := newSchemaRegistry(conf)
+	if err == nil {
+		t.Fail()
+	}
+}
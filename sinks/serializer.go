@@ -0,0 +1,235 @@
+package sinks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/juju/errors"
+	"github.com/spf13/viper"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+	"github.com/Pirionfr/lookatch-agent/utils"
+)
+
+// Compressions of serialized events
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// Encodings of encrypted events
+const (
+	EncryptionEncodingBase64 = "base64"
+	EncryptionEncodingRaw    = "raw"
+)
+
+// ContentEncodingHeader header holding compression of serialized events
+const ContentEncodingHeader = "content-encoding"
+
+type (
+	// Serializer serialize events sent by a sink
+	// returned headers are sent as Kafka record headers or Pulsar message properties
+	Serializer interface {
+		Serialize(event events.LookatchEvent) ([]byte, map[string]string, error)
+	}
+
+	// SerializerFunc function used as Serializer
+	SerializerFunc func(event events.LookatchEvent) ([]byte, map[string]string, error)
+
+	// compressor compress serialized events
+	compressor func(payload []byte) ([]byte, error)
+
+	// encodingSerializer compress then encrypt output of serializer, as set in sink config
+	encodingSerializer struct {
+		Serializer
+		compression        string
+		compress           compressor
+		encryptionKey      string
+		encryptionEncoding string
+	}
+)
+
+// serializerCreator serializer Creator func
+type serializerCreator func(*Sink) (Serializer, error)
+
+// Serializers serializer Factory by format, other formats can be plugged by adding their creator
+var Serializers = map[string]serializerCreator{
+	FormatJSON:              newStaticSerializer(serializeJSON),
+	FormatJSONWithHeader:    newStaticSerializer(serializeJSONWithHeader),
+	FormatDebezium:          newStaticSerializer(serializeDebezium),
+	FormatCloudEvents:       newStaticSerializer(serializeCloudEvents),
+	FormatCloudEventsBinary: newStaticSerializer(serializeCloudEventsBinary),
+	FormatProtobuf:          newStaticSerializer(serializeProtobuf),
+	FormatAvro:              createAvroSerializer,
+}
+
+// compressors compressor by compression
+var compressors = map[string]compressor{
+	CompressionGzip: compressGzip,
+}
+
+// Serialize call f
+func (f SerializerFunc) Serialize(event events.LookatchEvent) ([]byte, map[string]string, error) {
+	return f(event)
+}
+
+// newStaticSerializer return creator of serializer which doesn't depend on sink config
+func newStaticSerializer(serialize SerializerFunc) serializerCreator {
+	return func(*Sink) (Serializer, error) {
+		return serialize, nil
+	}
+}
+
+// createAvroSerializer create avro serializer of sink as a Serializer
+func createAvroSerializer(s *Sink) (Serializer, error) {
+	a, err := newAvroSerializer(s)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// getFormat return output format set in sink config, defaultFormat if not set
+func getFormat(conf *viper.Viper, defaultFormat string) string {
+	format := conf.GetString("format")
+	if format == "" {
+		return defaultFormat
+	}
+	return format
+}
+
+// getCompression return compression set in sink config, none if not set
+func getCompression(conf *viper.Viper) string {
+	compression := conf.GetString("compression")
+	if compression == "" {
+		return CompressionNone
+	}
+	return compression
+}
+
+// getEncryptionEncoding return encoding of encrypted events set in sink config, defaultEncoding if not set
+func getEncryptionEncoding(conf *viper.Viper, defaultEncoding string) string {
+	encoding := conf.GetString("encryption_encoding")
+	if encoding == "" {
+		return defaultEncoding
+	}
+	return encoding
+}
+
+// newSerializer create serializer of format
+// its output is compressed and encrypted as set in sink config, encrypted output is encoded in encryptionEncoding
+func (s *Sink) newSerializer(format string, encryptionEncoding string) (Serializer, error) {
+	serializerCreatorFunc, found := Serializers[format]
+	if !found {
+		return nil, errors.Errorf("unknown format '%s'", format)
+	}
+	serializer, err := serializerCreatorFunc(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if encryptionEncoding != EncryptionEncodingBase64 && encryptionEncoding != EncryptionEncodingRaw {
+		return nil, errors.Errorf("unknown encryption encoding '%s'", encryptionEncoding)
+	}
+	e := &encodingSerializer{
+		Serializer:         serializer,
+		compression:        getCompression(s.Conf),
+		encryptionKey:      s.EncryptionKey,
+		encryptionEncoding: encryptionEncoding,
+	}
+	if e.compression != CompressionNone {
+		e.compress, found = compressors[e.compression]
+		if !found {
+			return nil, errors.Errorf("unknown compression '%s'", e.compression)
+		}
+	}
+	return e, nil
+}
+
+// Serialize serialize event, then compress and encrypt it
+// encrypted events are sent as raw ciphertext or encoded in URL base64, as read by utils.DecryptString
+func (e *encodingSerializer) Serialize(event events.LookatchEvent) ([]byte, map[string]string, error) {
+	payload, headers, err := e.Serializer.Serialize(event)
+	if err != nil {
+		return nil, nil, errors.Annotate(err, "error while marshalling event")
+	}
+
+	if e.compress != nil {
+		payload, err = e.compress(payload)
+		if err != nil {
+			return nil, nil, errors.Annotate(err, "error while compressing event")
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[ContentEncodingHeader] = e.compression
+	}
+
+	if len(e.encryptionKey) > 0 {
+		encrypted, err := utils.EncryptBytes(payload, e.encryptionKey)
+		if err != nil {
+			return nil, nil, errors.Annotate(err, "error while encrypting event")
+		}
+		payload = encrypted
+		if e.encryptionEncoding == EncryptionEncodingBase64 {
+			payload = make([]byte, base64.URLEncoding.EncodedLen(len(encrypted)))
+			base64.URLEncoding.Encode(payload, encrypted)
+		}
+	}
+	return payload, headers, nil
+}
+
+// serializeJSON serialize payload of event in JSON
+func serializeJSON(event events.LookatchEvent) ([]byte, map[string]string, error) {
+	payload, err := json.Marshal(event.Payload)
+	return payload, nil, err
+}
+
+// serializeJSONWithHeader serialize event and its header in JSON
+func serializeJSONWithHeader(event events.LookatchEvent) ([]byte, map[string]string, error) {
+	payload, err := json.Marshal(event)
+	return payload, nil, err
+}
+
+// serializeDebezium serialize SQL events in Debezium envelope, other events in JSON
+func serializeDebezium(event events.LookatchEvent) ([]byte, map[string]string, error) {
+	sqlEvent, ok := event.Payload.(events.SQLEvent)
+	if !ok {
+		return serializeJSON(event)
+	}
+	payload, err := json.Marshal(NewDebeziumEvent(event.Header.EventType, sqlEvent))
+	return payload, nil, err
+}
+
+// serializeCloudEvents serialize event in CloudEvents structured mode
+func serializeCloudEvents(event events.LookatchEvent) ([]byte, map[string]string, error) {
+	return NewCloudEvent(event).Structured()
+}
+
+// serializeCloudEventsBinary serialize event in CloudEvents binary mode
+func serializeCloudEventsBinary(event events.LookatchEvent) ([]byte, map[string]string, error) {
+	return NewCloudEvent(event).Binary()
+}
+
+// serializeProtobuf serialize event and its header in protobuf
+func serializeProtobuf(event events.LookatchEvent) ([]byte, map[string]string, error) {
+	payload, err := events.MarshalProto(event)
+	return payload, nil, err
+}
+
+// compressGzip compress payload with gzip
+func compressGzip(payload []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write(payload)
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,138 @@
+package sinks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+	"github.com/Pirionfr/lookatch-agent/utils"
+)
+
+var serializerEvent = events.LookatchEvent{
+	Header: events.LookatchHeader{EventType: "Syslog"},
+	Payload: events.GenericEvent{
+		Environment: "prod",
+		Value:       "test",
+	},
+}
+
+func TestGetFormat(t *testing.T) {
+	conf := viper.New()
+	if format := getFormat(conf, FormatJSONWithHeader); format != FormatJSONWithHeader {
+		t.Error(format)
+	}
+
+	conf.Set("format", "debezium")
+	if format := getFormat(conf, FormatJSON); format != FormatDebezium {
+		t.Error(format)
+	}
+}
+
+func TestNewSerializerUnknown(t *testing.T) {
+	conf := viper.New()
+	conf.Set("format", "xml")
+	s := &Sink{Conf: conf}
+	_, err := s.newSerializer(getFormat(conf, FormatJSON), EncryptionEncodingBase64)
+	if err == nil {
+		t.Error("unknown format")
+	}
+
+	conf.Set("compression", "lzma")
+	_, err = s.newSerializer(FormatJSON, EncryptionEncodingBase64)
+	if err == nil {
+		t.Error("unknown compression")
+	}
+
+	conf.Set("compression", "gzip")
+	_, err = s.newSerializer(FormatJSON, "hex")
+	if err == nil {
+		t.Error("unknown encryption encoding")
+	}
+}
+
+func TestSerializeJSON(t *testing.T) {
+	s := &Sink{Conf: viper.New()}
+	serializer, err := s.newSerializer(FormatJSON, EncryptionEncodingBase64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, headers, err := serializer.Serialize(serializerEvent)
+	if err != nil || headers != nil {
+		t.Fatal(err, headers)
+	}
+	if string(payload) != `{"environment":"prod","timestamp":"","value":"test"}` {
+		t.Error(string(payload))
+	}
+
+	serializer, err = s.newSerializer(FormatJSONWithHeader, EncryptionEncodingBase64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _, err = serializer.Serialize(serializerEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event map[string]map[string]interface{}
+	err = json.Unmarshal(payload, &event)
+	if err != nil || event["Header"]["EventType"] != "Syslog" || event["Payload"]["value"] != "test" {
+		t.Error(string(payload), err)
+	}
+}
+
+func TestSerializeCompressedEncrypted(t *testing.T) {
+	conf := viper.New()
+	conf.Set("compression", "gzip")
+	s := &Sink{Conf: conf, EncryptionKey: "key"}
+	serializer, err := s.newSerializer(FormatCloudEventsBinary, EncryptionEncodingBase64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, headers, err := serializer.Serialize(serializerEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers[ContentEncodingHeader] != CompressionGzip || headers["ce_type"] != "Syslog" {
+		t.Error(headers)
+	}
+
+	decrypted, err := utils.DecryptString(string(payload), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := gzip.NewReader(bytes.NewBufferString(decrypted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decompressed) != `{"environment":"prod","timestamp":"","value":"test"}` {
+		t.Error(string(decompressed))
+	}
+}
+
+func TestSerializersPluggable(t *testing.T) {
+	Serializers["upper"] = func(*Sink) (Serializer, error) {
+		return SerializerFunc(func(event events.LookatchEvent) ([]byte, map[string]string, error) {
+			return []byte("TEST"), nil, nil
+		}), nil
+	}
+	defer delete(Serializers, "upper")
+
+	conf := viper.New()
+	conf.Set("format", "upper")
+	k, err := NewKafka(&Sink{Conf: conf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := k.(*Kafka).ProcessGenericEvent(events.LookatchHeader{}, &events.GenericEvent{Value: "test"})
+	if err != nil || string(msg.Value) != "TEST" {
+		t.Error(msg, err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/Pirionfr/lookatch-agent/events"
+	"github.com/Pirionfr/lookatch-agent/utils"
 )
 
 // Possible Statuses
@@ -18,21 +19,50 @@ const (
 	DefaultChannelSize = 100
 )
 
+// Output formats of sinks
+const (
+	FormatJSON              = "json"
+	FormatJSONWithHeader    = "json-with-header"
+	FormatDebezium          = "debezium"
+	FormatCloudEvents       = "cloudevents"
+	FormatCloudEventsBinary = "cloudevents-binary"
+	FormatAvro              = "avro"
+	FormatProtobuf          = "protobuf"
+)
+
 type (
 	// SinkI sink interface
 	SinkI interface {
 		Start(...interface{}) error
+		Stop() error
+		GetName() string
 		GetInputChan() chan events.LookatchEvent
 		GetCommitChan() chan interface{}
+		GetMeta() map[string]utils.Meta
+		GetStatus() interface{}
+		HealthCheck() bool
+		GetCapabilities() map[string]*utils.TaskDescription
+		SetDeadLetter(string, chan events.LookatchEvent)
+		SetSchemaProvider(SchemaProvider)
 	}
 	// Sink representation of sink
 	Sink struct {
 		In            chan events.LookatchEvent
-		Stop          chan error
+		Stopper       chan error
 		Commit        chan interface{}
 		Name          string
 		EncryptionKey string
 		Conf          *viper.Viper
+		Status        string
+		DeadLetter    *DeadLetter
+		Schemas       SchemaProvider
+	}
+
+	// Ack offset committed by a sink, with the name of the source which sent it
+	// a sink linked to several sources commits offsets of each of them
+	Ack struct {
+		SourceName string
+		Offset     string
 	}
 )
 
@@ -69,7 +99,49 @@ func New(name string, sinkType string, conf *viper.Viper, stop chan error) (Sink
 	eventChan := make(chan events.LookatchEvent, channelSize)
 	commitChan := make(chan interface{}, channelSize)
 
-	return sinkCreatorFunc(&Sink{eventChan, stop, commitChan, name, conf.GetString("agent.EncryptionKey"), customConf})
+	return sinkCreatorFunc(&Sink{eventChan, stop, commitChan, name, conf.GetString("agent.EncryptionKey"), customConf, SinkStatusWaiting, NewDeadLetter(), nil})
+}
+
+// GetName get name of sink
+func (s *Sink) GetName() string {
+	return s.Name
+}
+
+// SetSchemaProvider set provider of source schemas, used by formats built from tables columns
+// it must be set before sink is started
+func (s *Sink) SetSchemaProvider(schemas SchemaProvider) {
+	s.Schemas = schemas
+}
+
+// GetStatus returns sink status
+func (s *Sink) GetStatus() interface{} {
+	return s.Status
+}
+
+// HealthCheck returns true if sink is running
+func (s *Sink) HealthCheck() bool {
+	return s.Status == SinkStatusRunning
+}
+
+// GetMeta returns sink meta
+func (s *Sink) GetMeta() map[string]utils.Meta {
+	meta := make(map[string]utils.Meta)
+	meta["pending_events"] = utils.NewMeta("pending_events", len(s.In))
+	meta["pending_commits"] = utils.NewMeta("pending_commits", len(s.Commit))
+	if s.DeadLetter != nil {
+		meta["rejected_events"] = utils.NewMeta("rejected_events", s.DeadLetter.getRejected())
+		meta["dead_letter_failures"] = utils.NewMeta("dead_letter_failures", s.DeadLetter.getFailed())
+	}
+	return meta
+}
+
+// GetCapabilities returns available actions
+func (s *Sink) GetCapabilities() map[string]*utils.TaskDescription {
+	availableAction := make(map[string]*utils.TaskDescription)
+	availableAction[utils.SinkStart] = utils.DeclareNewTaskDescription(nil, "Start sink")
+	availableAction[utils.SinkStop] = utils.DeclareNewTaskDescription(nil, "Stop sink")
+	availableAction[utils.SinkRestart] = utils.DeclareNewTaskDescription(nil, "Restart sink")
+	return availableAction
 }
 
 // GetInputChan return input channel attach to sink
@@ -82,22 +154,24 @@ func (s *Sink) GetCommitChan() chan interface{} {
 	return s.Commit
 }
 
-// SendCommit send a Commit message into the Commit channel of this sink
-func (s *Sink) SendCommit(payload interface{}) {
+// SendCommit send offset of payload, tagged with its source, into the Commit channel of this sink
+func (s *Sink) SendCommit(sourceName string, payload interface{}) {
 
 	switch typedMsg := payload.(type) {
 	case events.SQLEvent:
 		if typedMsg.Offset != nil {
-			s.Commit <- typedMsg.Offset.Source
+			s.Commit <- Ack{SourceName: sourceName, Offset: typedMsg.Offset.Source}
 		}
 	case events.GenericEvent:
 		if typedMsg.Offset != nil {
-			s.Commit <- typedMsg.Offset.Source
+			s.Commit <- Ack{SourceName: sourceName, Offset: typedMsg.Offset.Source}
 		}
 	case *events.Offset:
 		if typedMsg != nil {
-			s.Commit <- typedMsg.Source
+			s.Commit <- Ack{SourceName: sourceName, Offset: typedMsg.Source}
 		}
+	case events.DeadLetterEvent:
+		// offset is committed by the sink which rejected the event
 	default:
 		log.WithField("message", payload).Warn("Source  doesn't match any known type")
 	}
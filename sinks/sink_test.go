@@ -5,6 +5,9 @@ import (
 	"testing"
 
 	"github.com/spf13/viper"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+	"github.com/Pirionfr/lookatch-agent/utils"
 )
 
 var (
@@ -26,3 +29,66 @@ func TestCreateSink(t *testing.T) {
 		t.Error("mistmatch")
 	}
 }
+
+func TestSinkLifecycle(t *testing.T) {
+	rch := make(chan error)
+	r, err := New("default", "Stdout", vSink, rch)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if r.GetName() != "default" || r.GetStatus() != SinkStatusWaiting || r.HealthCheck() {
+		t.Fail()
+	}
+
+	if r.Start() != nil || !r.HealthCheck() {
+		t.Fail()
+	}
+
+	if r.Stop() != nil || r.GetStatus() != SinkStatusWaiting {
+		t.Fail()
+	}
+}
+
+func TestSinkGetMeta(t *testing.T) {
+	rch := make(chan error)
+	r, err := New("default", "Stdout", vSink, rch)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, ok := r.GetMeta()["pending_events"]; !ok {
+		t.Fail()
+	}
+}
+
+func TestSinkGetCapabilities(t *testing.T) {
+	rch := make(chan error)
+	r, err := New("default", "Stdout", vSink, rch)
+	if err != nil {
+		t.Error(err)
+	}
+
+	capabilities := r.GetCapabilities()
+	if capabilities[utils.SinkStart] == nil || capabilities[utils.SinkStop] == nil || capabilities[utils.SinkRestart] == nil {
+		t.Fail()
+	}
+}
+
+func TestMarshalProtobuf(t *testing.T) {
+	event := events.LookatchEvent{
+		Header:  events.LookatchHeader{EventType: "Syslog"},
+		Payload: events.GenericEvent{Value: "test"},
+	}
+	payload, headers, err := serializeProtobuf(event)
+	if err != nil || headers != nil {
+		t.Fatal(err, headers)
+	}
+	decoded, err := events.UnmarshalProto(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Header.EventType != "Syslog" || decoded.Payload.(events.GenericEvent).Value != "test" {
+		t.Error(decoded)
+	}
+}
@@ -1,16 +1,17 @@
 package sinks
 
 import (
-	"encoding/json"
-
-	"github.com/Pirionfr/goDcCrypto/crypto"
 	"github.com/Pirionfr/lookatch-agent/events"
+	"github.com/juju/errors"
 	log "github.com/sirupsen/logrus"
 )
 
 // Stdout representation of sink
 type Stdout struct {
 	*Sink
+	serializer Serializer
+	done       chan struct{}
+	stopped    chan struct{}
 }
 
 // StdoutType type of sink
@@ -18,32 +19,71 @@ const StdoutType = "Stdout"
 
 // NewStdout create new stdout sink
 func NewStdout(s *Sink) (SinkI, error) {
-	return &Stdout{s}, nil
+	// binary output can't be logged
+	format := getFormat(s.Conf, FormatJSON)
+	if format == FormatAvro || format == FormatProtobuf {
+		return nil, errors.Errorf("format '%s' is not supported by %s sink", format, StdoutType)
+	}
+	if compression := getCompression(s.Conf); compression != CompressionNone {
+		return nil, errors.Errorf("compression '%s' is not supported by %s sink", compression, StdoutType)
+	}
+	if encoding := getEncryptionEncoding(s.Conf, EncryptionEncodingBase64); encoding != EncryptionEncodingBase64 {
+		return nil, errors.Errorf("encryption encoding '%s' is not supported by %s sink", encoding, StdoutType)
+	}
+	serializer, err := s.newSerializer(format, EncryptionEncodingBase64)
+	if err != nil {
+		return nil, err
+	}
+	return &Stdout{Sink: s, serializer: serializer}, nil
 }
 
 // Start stdout sink
 func (s *Stdout) Start(i ...interface{}) (err error) {
-	go func(messages chan events.LookatchEvent) {
-		for message := range messages {
-			var bytes []byte
-			bytes, err = json.Marshal(message.Payload)
-			if err != nil {
-				log.WithError(err).Error("json.Marshal()")
+	s.done = make(chan struct{})
+	s.stopped = make(chan struct{})
+	go func(messages chan events.LookatchEvent, done chan struct{}) {
+		defer close(s.stopped)
+		for {
+			var message events.LookatchEvent
+			select {
+			case <-done:
 				return
+			case message = <-messages:
 			}
-			msg := string(bytes)
-			if s.EncryptionKey != "" {
-				msg, err = crypto.EncryptString(string(bytes), s.EncryptionKey)
-				if err != nil {
-					log.WithError(err).Error("error while encrypting event")
-					return
+			var bytes []byte
+			var headers map[string]string
+			bytes, headers, err = s.serializer.Serialize(message)
+			if err != nil {
+				if s.Reject(message, err) {
+					s.SendCommit(message.Header.SourceName, message.Payload)
+					continue
 				}
+				log.WithError(err).Error("error while serializing event")
+				s.Status = SinkStatusOnError
+				return
 			}
 
-			log.WithField("message", msg).Info("Stdout Sink")
-			s.SendCommit(message.Payload)
+			entry := log.WithField("message", string(bytes))
+			if len(headers) > 0 {
+				entry = entry.WithField("headers", headers)
+			}
+			entry.Info("Stdout Sink")
+			s.SendCommit(message.Header.SourceName, message.Payload)
 		}
-	}(s.In)
+	}(s.In, s.done)
 
+	s.Status = SinkStatusRunning
 	return
 }
+
+// Stop stdout sink
+func (s *Stdout) Stop() error {
+	if s.done == nil {
+		return nil
+	}
+	close(s.done)
+	<-s.stopped
+	s.done = nil
+	s.Status = SinkStatusWaiting
+	return nil
+}
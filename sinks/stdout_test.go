@@ -23,7 +23,7 @@ func init() {
 	vStdout.Set("sinks.default.autostart", true)
 	vStdout.Set("sinks.default.enabled", true)
 
-	sink = &Sink{eventChan, stop, commitChan, "Stdout", "", vStdout.Sub("sinks.default")}
+	sink = &Sink{eventChan, stop, commitChan, "Stdout", "", vStdout.Sub("sinks.default"), SinkStatusWaiting, nil, nil}
 }
 
 func TestNewStdout(t *testing.T) {
@@ -105,3 +105,36 @@ func TestStartBadEvent(t *testing.T) {
 		Payload: "test",
 	}
 }
+
+func TestStdoutStop(t *testing.T) {
+	commits := make(chan interface{}, 1)
+	r, err := NewStdout(&Sink{make(chan events.LookatchEvent, 1), make(chan error), commits, "Stdout", "", vStdout.Sub("sinks.default"), SinkStatusWaiting, nil, nil})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if r.Stop() != nil {
+		t.Fail()
+	}
+
+	err = r.Start()
+	if err != nil {
+		t.Error(err)
+	}
+
+	r.GetInputChan() <- events.LookatchEvent{
+		Payload: events.GenericEvent{
+			Value: "test",
+			Offset: &events.Offset{
+				Source: "1",
+			},
+		},
+	}
+	if <-commits != (Ack{Offset: "1"}) {
+		t.Fail()
+	}
+
+	if r.Stop() != nil {
+		t.Fail()
+	}
+}
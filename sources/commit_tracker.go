@@ -0,0 +1,59 @@
+package sources
+
+import (
+	"sync"
+)
+
+// commitTracker advance committed offset of a source past rows it did not send
+// offset of suppressed rows is committed as soon as every event sent before has been committed
+type commitTracker struct {
+	sync.Mutex
+	commit    func(offset string)
+	sent      string
+	committed string
+	skipped   string
+}
+
+// newCommitTracker create tracker calling commit for each offset to commit
+func newCommitTracker(commit func(offset string)) *commitTracker {
+	return &commitTracker{
+		commit: commit,
+	}
+}
+
+// Sent record offset of an event sent to output channel
+func (t *commitTracker) Sent(offset string) {
+	t.Lock()
+	defer t.Unlock()
+	t.sent = offset
+	t.skipped = ""
+}
+
+// Skip record offset of rows not sent
+// it is committed now if no event is waiting for commit, otherwise with the last sent one
+func (t *commitTracker) Skip(offset string) {
+	t.Lock()
+	defer t.Unlock()
+	if t.sent == t.committed {
+		t.sent = offset
+		t.committed = offset
+		t.skipped = ""
+		t.commit(offset)
+		return
+	}
+	t.skipped = offset
+}
+
+// Commit record offset committed by sinks
+// offset of rows skipped since the last sent event is committed instead once this event is committed
+func (t *commitTracker) Commit(offset string) {
+	t.Lock()
+	defer t.Unlock()
+	if offset == t.sent && t.skipped != "" {
+		offset = t.skipped
+		t.sent = offset
+		t.skipped = ""
+	}
+	t.committed = offset
+	t.commit(offset)
+}
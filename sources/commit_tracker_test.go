@@ -0,0 +1,59 @@
+package sources
+
+import (
+	"testing"
+)
+
+func TestCommitTrackerSkipIdle(t *testing.T) {
+	var committed []string
+	tracker := newCommitTracker(func(offset string) {
+		committed = append(committed, offset)
+	})
+
+	tracker.Skip("1")
+	if len(committed) != 1 || committed[0] != "1" {
+		t.Errorf("skipped offset expected to be committed, got %v", committed)
+	}
+}
+
+func TestCommitTrackerSkipPending(t *testing.T) {
+	var committed []string
+	tracker := newCommitTracker(func(offset string) {
+		committed = append(committed, offset)
+	})
+
+	tracker.Sent("1")
+	tracker.Sent("2")
+	tracker.Skip("3")
+	tracker.Skip("4")
+	if len(committed) != 0 {
+		t.Errorf("no commit expected while events are pending, got %v", committed)
+	}
+
+	tracker.Commit("1")
+	tracker.Commit("2")
+	if len(committed) != 2 || committed[0] != "1" || committed[1] != "4" {
+		t.Errorf("skipped offset expected with last sent one, got %v", committed)
+	}
+
+	tracker.Skip("5")
+	if len(committed) != 3 || committed[2] != "5" {
+		t.Errorf("skipped offset expected to be committed, got %v", committed)
+	}
+}
+
+func TestCommitTrackerSentAfterSkip(t *testing.T) {
+	var committed []string
+	tracker := newCommitTracker(func(offset string) {
+		committed = append(committed, offset)
+	})
+
+	tracker.Sent("1")
+	tracker.Skip("2")
+	tracker.Sent("3")
+	tracker.Commit("1")
+	tracker.Commit("3")
+	if len(committed) != 2 || committed[0] != "1" || committed[1] != "3" {
+		t.Errorf("sent offsets expected, got %v", committed)
+	}
+}
@@ -21,21 +21,33 @@ type (
 	// DBSQLQuery representation of DBSQL query
 	DBSQLQuery struct {
 		*Source
-		Config  DBSQLQueryConfig
-		db      *sql.DB
-		schemas SQLSchema
+		Config    DBSQLQueryConfig
+		eventType string
+		filter    *utils.Filter
+		masker    *utils.Masker
+		rowFilter *utils.RowFilter
+		methods   *utils.MethodFilter
+		db        *sql.DB
+		schemas   SQLSchema
+		columns   SQLSchema
 	}
 
 	// DBSQLQueryConfig representation of DBSQL query configuration
 	DBSQLQueryConfig struct {
-		Host             string            `json:"host"`
-		Port             int               `json:"port"`
-		User             string            `json:"user"`
-		Password         string            `json:"password"`
-		BatchSize        int               `json:"batch_size" mapstructure:"batch_size"`
-		NbWorker         int               `json:"nb_worker"  mapstructure:"nb_worker"`
-		ColumnsMetaValue bool              `json:"columns_meta" mapstructure:"columns_meta"`
-		DefinedPk        map[string]string `json:"defined_pk" mapstructure:"defined_pk"`
+		Host             string                 `json:"host"`
+		Port             int                    `json:"port"`
+		User             string                 `json:"user"`
+		Password         string                 `json:"password"`
+		BatchSize        int                    `json:"batch_size" mapstructure:"batch_size"`
+		NbWorker         int                    `json:"nb_worker"  mapstructure:"nb_worker"`
+		ColumnsMetaValue bool                   `json:"columns_meta" mapstructure:"columns_meta"`
+		DefinedPk        map[string]string      `json:"defined_pk" mapstructure:"defined_pk"`
+		FilterPolicy     string                 `json:"filter_policy" mapstructure:"filter_policy"`
+		Filter           map[string]interface{} `json:"filter"`
+		FilterPatterns   []string               `json:"filter_patterns" mapstructure:"filter_patterns"`
+		Mask             map[string]interface{} `json:"mask"`
+		RowFilter        map[string]interface{} `json:"row_filter" mapstructure:"row_filter"`
+		Methods          map[string]interface{} `json:"methods"`
 	}
 
 	// Query representation of query action
@@ -56,19 +68,38 @@ type (
 	SQLSchema map[string]map[string]map[string]*Column
 )
 
-// NewDBSQLQuery create new DBSQL query client
-func NewDBSQLQuery(s *Source) DBSQLQuery {
+// NewDBSQLQuery create new DBSQL query client sending events of eventType
+func NewDBSQLQuery(s *Source, eventType string) DBSQLQuery {
 	gdbcQueryConfig := DBSQLQueryConfig{}
 	err := s.Conf.UnmarshalKey("sources."+s.Name, &gdbcQueryConfig)
 	if err != nil {
 		return DBSQLQuery{}
 	}
 	return DBSQLQuery{
-		Source: s,
-		Config: gdbcQueryConfig,
+		Source:    s,
+		Config:    gdbcQueryConfig,
+		eventType: eventType,
 	}
 }
 
+// initFilters create filter of databases, tables and columns, masker of configured columns, filters of configured rows and methods
+func (d *DBSQLQuery) initFilters() (err error) {
+	d.filter, err = utils.NewFilter(d.Config.FilterPolicy, d.Config.Filter, d.Config.FilterPatterns)
+	if err != nil {
+		return
+	}
+	d.masker, err = utils.NewMasker(d.Config.Mask)
+	if err != nil {
+		return
+	}
+	d.rowFilter, err = utils.NewRowFilter(d.Config.RowFilter)
+	if err != nil {
+		return
+	}
+	d.methods, err = utils.NewMethodFilter(d.Config.Methods)
+	return
+}
+
 // GetSchema returns source schema
 func (d *DBSQLQuery) GetSchema() map[string]map[string]*Column {
 	schema := make(map[string]map[string]*Column)
@@ -126,7 +157,8 @@ func (d *DBSQLQuery) QuerySchema(q string) (err error) {
 	}
 	defer rows.Close()
 	// We initialize the schema map
-	d.schemas = make(SQLSchema)
+	schemas := make(SQLSchema)
+	columns := make(SQLSchema)
 
 	previousTableName := ""
 
@@ -148,13 +180,13 @@ func (d *DBSQLQuery) QuerySchema(q string) (err error) {
 			previousTableName = cs.Table
 		}
 
-		if _, ok := d.schemas[cs.Schema]; !ok {
-			t := make(map[string]map[string]*Column)
-			d.schemas[cs.Schema] = t
+		if _, ok := schemas[cs.Schema]; !ok {
+			schemas[cs.Schema] = make(map[string]map[string]*Column)
+			columns[cs.Schema] = make(map[string]map[string]*Column)
 		}
-		if _, ok := d.schemas[cs.Schema][cs.Table]; !ok {
-			c := make(map[string]*Column)
-			d.schemas[cs.Schema][cs.Table] = c
+		if _, ok := schemas[cs.Schema][cs.Table]; !ok {
+			schemas[cs.Schema][cs.Table] = make(map[string]*Column)
+			columns[cs.Schema][cs.Table] = make(map[string]*Column)
 		}
 
 		// Get Defined Primary Key
@@ -164,8 +196,10 @@ func (d *DBSQLQuery) QuerySchema(q string) (err error) {
 			cs.ColumnKey = PRI
 		}
 
-		d.schemas[cs.Schema][cs.Table][strconv.Itoa(cs.ColumnOrdPos-1)] = cs
+		schemas[cs.Schema][cs.Table][strconv.Itoa(cs.ColumnOrdPos-1)] = cs
+		columns[cs.Schema][cs.Table][cs.Column] = cs
 	}
+	d.schemas, d.columns = schemas, columns
 
 	return nil
 }
@@ -178,6 +212,10 @@ func (d *DBSQLQuery) Query(database string, query string) (err error) {
 	}
 	//parse query
 	info.Schema, info.Table = d.ExtractDatabaseTable(query)
+	if d.filter.IsExcludedTable(info.Schema, info.Table) || d.methods.IsFilteredMethod(info.Schema, info.Table, utils.MethodQuery) {
+		log.WithField("query", query).Warn("Table is excluded, query is not run")
+		return
+	}
 
 	log.WithField("query", query).Debug("Start querying")
 	// check that the collector is still connected to the database
@@ -216,6 +254,9 @@ func (d *DBSQLQuery) Query(database string, query string) (err error) {
 	if d.Config.ColumnsMetaValue {
 		info.ColumnMeta = make(map[string]events.ColumnsMeta)
 		for index, element := range cols {
+			if d.filter.IsExcludedColumn(info.Schema, info.Table, element) {
+				continue
+			}
 			info.ColumnMeta[element] = events.ColumnsMeta{
 				Type:     d.schemas[info.Schema][info.Table][strconv.Itoa(index)].ColumnType,
 				Position: index + 1,
@@ -277,10 +318,18 @@ func (d *DBSQLQuery) Query(database string, query string) (err error) {
 func (d *DBSQLQuery) ProcessLines(columns []string, lines [][]interface{}, info QueryInfo, wg *sizedwaitgroup.SizedWaitGroup) {
 	log.Debug("PROCESSING")
 	header := events.LookatchHeader{
-		Tenant: d.AgentInfo.Tenant,
+		EventType: d.eventType,
+		Tenant:    d.AgentInfo.Tenant,
 	}
 	var colmap map[string]interface{}
 	var err error
+	tableColumns := d.columns[info.Schema][info.Table]
+	excluded := make([]string, 0)
+	for _, col := range columns {
+		if d.filter.IsExcludedColumn(info.Schema, info.Table, col) {
+			excluded = append(excluded, col)
+		}
+	}
 	for index, values := range lines {
 		colmap = make(map[string]interface{})
 		if len(values) == 0 {
@@ -288,7 +337,13 @@ func (d *DBSQLQuery) ProcessLines(columns []string, lines [][]interface{}, info
 		}
 
 		for i, col := range columns {
-			switch vr := (*values[i].(*interface{})).(type) {
+			value := *values[i].(*interface{})
+			if column, ok := tableColumns[col]; ok {
+				colmap[col] = column.Normalize(value)
+				continue
+			}
+			// columns missing from schema, as computed ones, are guessed from their value
+			switch vr := value.(type) {
 			case []uint8:
 				if utils.IsNumDot(string(vr)) {
 					colmap[col], err = strconv.ParseFloat(string(vr), 64)
@@ -303,6 +358,14 @@ func (d *DBSQLQuery) ProcessLines(columns []string, lines [][]interface{}, info
 			}
 
 		}
+		// row filter may test excluded columns, they are removed once it is evaluated
+		if d.rowFilter.IsFilteredRow(info.Schema, info.Table, colmap, nil) {
+			continue
+		}
+		for _, col := range excluded {
+			delete(colmap, col)
+		}
+		d.masker.MaskValues(info.Schema, info.Table, colmap)
 		d.Source.OutputChannel <- events.LookatchEvent{
 			Header: header,
 			Payload: events.SQLEvent{
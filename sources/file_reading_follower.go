@@ -2,9 +2,11 @@ package sources
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"path/filepath"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/papertrail/go-tail/follower"
@@ -17,7 +19,11 @@ import (
 // FileReadingFollower representation of FileReadingFollower
 type FileReadingFollower struct {
 	*Source
-	config FileReadingFollowerConfig
+	config   FileReadingFollowerConfig
+	follower *follower.Follower
+	stopped  chan struct{}
+	// committed position in file, saved in checkpoints
+	committed int64
 }
 
 // FileReadingFollowerConfig representation of FileReadingFollower Config
@@ -43,14 +49,48 @@ func NewFileReadingFollower(s *Source) (SourceI, error) {
 }
 
 // Start source
+// reading resumes from committed position of last checkpoint, unless offset is set in configuration
 func (f *FileReadingFollower) Start(i ...interface{}) (err error) {
+	f.loadOffset()
 	f.Status = SourceStatusRunning
-	go f.UpdateCommittedLsn()
+	f.startCommitUpdater(f.UpdateCommittedLsn)
 
+	f.stopped = make(chan struct{})
 	go f.read()
 	return
 }
 
+// loadOffset set position in file to committed position of last checkpoint, unless offset is set in configuration
+func (f *FileReadingFollower) loadOffset() {
+	checkpoint := f.LoadCheckpoint()
+	if checkpoint != nil && checkpoint.Offset != "" && !f.Conf.IsSet("sources."+f.Name+".offset") {
+		offset, err := strconv.ParseInt(checkpoint.Offset, 10, 64)
+		if err != nil {
+			log.WithError(err).WithField("source", f.Name).Error("Error while parsing checkpoint offset")
+		} else {
+			f.config.Offset = offset
+		}
+	}
+	atomic.StoreInt64(&f.committed, f.config.Offset)
+}
+
+// Stop source
+// close file follower and wait for last lines to be sent
+func (f *FileReadingFollower) Stop() error {
+	if f.stopped == nil {
+		return nil
+	}
+	select {
+	case <-f.stopped:
+	default:
+		go f.follower.Close()
+		<-f.stopped
+	}
+	f.stopped = nil
+	f.Status = SourceStatusWaiting
+	return nil
+}
+
 // GetMeta get source meta
 func (f *FileReadingFollower) GetMeta() map[string]utils.Meta {
 	meta := f.Source.GetMeta()
@@ -104,6 +144,7 @@ func (f *FileReadingFollower) Process(action string, params ...interface{}) inte
 }
 
 func (f *FileReadingFollower) read() {
+	defer close(f.stopped)
 	t, err := follower.New(f.config.Path, follower.Config{
 		Whence: io.SeekCurrent,
 		Offset: f.config.Offset,
@@ -111,11 +152,15 @@ func (f *FileReadingFollower) read() {
 	})
 	if err != nil {
 		log.WithError(err).Error("Error while start reader")
+		return
 	}
+	f.follower = t
 
+	// offset of an event is the position following its line
 	currentOffset := f.config.Offset
 
 	for line := range t.Lines() {
+		currentOffset += int64(line.Discarded() + len(line.Bytes()) + 1)
 		f.OutputChannel <- events.LookatchEvent{
 			Header: events.LookatchHeader{
 				EventType: FileReadingFollowerType,
@@ -130,7 +175,6 @@ func (f *FileReadingFollower) read() {
 				},
 			},
 		}
-		currentOffset++
 		f.Offset++
 	}
 
@@ -139,11 +183,21 @@ func (f *FileReadingFollower) read() {
 	}
 }
 
-// UpdateCommittedLsn update CommittedLsn
+// UpdateCommittedLsn update committed position in file
 func (f *FileReadingFollower) UpdateCommittedLsn() {
 	for committedLsn := range f.CommitChannel {
-		if f.Offset > committedLsn.(int64) {
-			f.Offset = committedLsn.(int64)
+		committed, err := strconv.ParseInt(fmt.Sprint(committedLsn), 10, 64)
+		if err != nil {
+			log.WithError(err).Error("Error while updating committed offset")
+			continue
 		}
+		atomic.StoreInt64(&f.committed, committed)
 	}
 }
+
+// GetCheckpoint returns committed position in file and Offset counter
+func (f *FileReadingFollower) GetCheckpoint() *Checkpoint {
+	checkpoint := f.Source.GetCheckpoint()
+	checkpoint.Offset = strconv.FormatInt(atomic.LoadInt64(&f.committed), 10)
+	return checkpoint
+}
@@ -0,0 +1,71 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/Pirionfr/lookatch-agent/events"
+)
+
+// newTestFileReadingFollower create follower of a file holding lines, with a checkpoint at offset
+func newTestFileReadingFollower(t *testing.T, lines string, checkpoint string) *FileReadingFollower {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.log")
+	err := os.WriteFile(path, []byte(lines), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := newTestOffsetStore(t, filepath.Join(dir, "offsets.json"))
+	err = store.Save("file", &Checkpoint{Offset: checkpoint, Agent: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vFile := viper.New()
+	vFile.Set("sources.file.path", path)
+	s, err := NewFileReadingFollower(&Source{
+		Name:          "file",
+		OutputChannel: make(chan events.LookatchEvent, 10),
+		CommitChannel: make(chan interface{}, 10),
+		AgentInfo:     &AgentHeader{},
+		Conf:          vFile,
+		OffsetStore:   store,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.(*FileReadingFollower)
+}
+
+func TestFileReadingFollowerLoadOffset(t *testing.T) {
+	f := newTestFileReadingFollower(t, "a\nbb\nccc\n", "2")
+	f.loadOffset()
+	if f.config.Offset != 2 || f.GetCheckpoint().Offset != "2" {
+		t.Error(f.config.Offset, f.GetCheckpoint())
+	}
+
+	f.startCommitUpdater(f.UpdateCommittedLsn)
+	f.CommitChannel <- "5"
+	deadline := time.Now().Add(5 * time.Second)
+	for f.GetCheckpoint().Offset != "5" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if checkpoint := f.GetCheckpoint(); checkpoint.Offset != "5" {
+		t.Error(checkpoint)
+	}
+}
+
+func TestFileReadingFollowerConfiguredOffset(t *testing.T) {
+	f := newTestFileReadingFollower(t, "a\nbb\nccc\n", "2")
+	f.Conf.Set("sources.file.offset", 5)
+	f.config.Offset = 5
+	f.loadOffset()
+	if f.config.Offset != 5 {
+		t.Error(f.config.Offset)
+	}
+}
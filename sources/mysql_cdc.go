@@ -42,7 +42,12 @@ type (
 		meta      MysqlCDCMeta
 		query     *MySQLQuery
 		filter    *utils.Filter
+		masker    *utils.Masker
+		rowFilter *utils.RowFilter
+		methods   *utils.MethodFilter
+		commits   *commitTracker
 		cdcOffset *MysqlOffset
+		canal     *canal.Canal
 	}
 
 	// MysqlCDCConfig representation of Mysql change data capture configuration
@@ -60,7 +65,11 @@ type (
 		Mode             string                 `json:"mode"`
 		FilterPolicy     string                 `json:"filter_policy" mapstructure:"filter_policy"`
 		Filter           map[string]interface{} `json:"filter"`
+		FilterPatterns   []string               `json:"filter_patterns" mapstructure:"filter_patterns"`
 		DefinedPk        map[string]string      `json:"defined_pk" mapstructure:"defined_pk"`
+		Mask             map[string]interface{} `json:"mask"`
+		RowFilter        map[string]interface{} `json:"row_filter" mapstructure:"row_filter"`
+		Methods          map[string]interface{} `json:"methods"`
 	}
 
 	//MysqlCDCMeta representation of metadata
@@ -84,6 +93,22 @@ func NewMysqlCdc(s *Source) (SourceI, error) {
 	if err != nil {
 		return nil, err
 	}
+	masker, err := utils.NewMasker(mysqlCDCConfig.Mask)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := utils.NewFilter(mysqlCDCConfig.FilterPolicy, mysqlCDCConfig.Filter, mysqlCDCConfig.FilterPatterns)
+	if err != nil {
+		return nil, err
+	}
+	rowFilter, err := utils.NewRowFilter(mysqlCDCConfig.RowFilter)
+	if err != nil {
+		return nil, err
+	}
+	methods, err := utils.NewMethodFilter(mysqlCDCConfig.Methods)
+	if err != nil {
+		return nil, err
+	}
 	query := &MySQLQuery{
 		DBSQLQuery: &DBSQLQuery{
 			Source: s,
@@ -102,16 +127,19 @@ func NewMysqlCdc(s *Source) (SourceI, error) {
 	}
 
 	m := &MysqlCDC{
-		Source: s,
-		query:  query,
-		config: mysqlCDCConfig,
-		filter: &utils.Filter{
-			FilterPolicy: mysqlCDCConfig.FilterPolicy,
-			Filter:       mysqlCDCConfig.Filter,
-		},
+		Source:    s,
+		query:     query,
+		config:    mysqlCDCConfig,
+		filter:    filter,
+		masker:    masker,
+		rowFilter: rowFilter,
+		methods:   methods,
 		meta:      MysqlCDCMeta{},
 		cdcOffset: &MysqlOffset{},
 	}
+	m.commits = newCommitTracker(func(offset string) {
+		m.meta.CommittedOffset = offset
+	})
 	//default value
 	if m.config.Flavor == "" {
 		m.config.Flavor = Mysql
@@ -142,12 +170,15 @@ func (m *MysqlCDC) Start(i ...interface{}) (err error) {
 		"type": MysqlCDCType,
 	}).Debug("Start")
 
+	m.startCommitUpdater(m.UpdateCommittedLsn)
 	err = m.Source.Start(i)
 	if err != nil {
 		return err
 	}
 
-	go m.UpdateCommittedLsn()
+	if checkpoint := m.LoadCheckpoint(); checkpoint != nil && m.meta.CommittedOffset == "" {
+		m.meta.CommittedOffset = checkpoint.Offset
+	}
 
 	if m.meta.CommittedOffset == "" {
 		m.meta.CommittedOffset = m.config.Offset
@@ -172,6 +203,18 @@ func (m *MysqlCDC) Start(i ...interface{}) (err error) {
 	return err
 }
 
+// Stop source
+// close replication connection
+func (m *MysqlCDC) Stop() error {
+	if m.canal == nil {
+		return nil
+	}
+	m.canal.Close()
+	m.canal = nil
+	m.Status = SourceStatusWaiting
+	return nil
+}
+
 // GetMeta get metadata
 func (m *MysqlCDC) GetMeta() map[string]utils.Meta {
 	log.WithFields(log.Fields{
@@ -187,6 +230,14 @@ func (m *MysqlCDC) GetMeta() map[string]utils.Meta {
 	return meta
 }
 
+// GetCheckpoint returns committed offset to persist in offset store
+func (m *MysqlCDC) GetCheckpoint() *Checkpoint {
+	return &Checkpoint{
+		Offset: m.meta.CommittedOffset,
+		Agent:  m.Offset,
+	}
+}
+
 // Process action
 func (m *MysqlCDC) Process(action string, params ...interface{}) interface{} {
 	switch action {
@@ -222,7 +273,11 @@ func (m *MysqlCDC) StartCanal() error {
 	}
 	cfg.Dump.ExecutionPath = ""
 
-	c, _ := canal.NewCanal(cfg)
+	c, err := canal.NewCanal(cfg)
+	if err != nil {
+		return err
+	}
+	m.canal = c
 
 	// Register a handler to handle RowsEvent
 	c.SetEventHandler(m)
@@ -298,14 +353,20 @@ func (m *MysqlCDC) OnDDL(header *replication.EventHeader, nextPos mysql.Position
 }
 
 // OnRow send row to multiplexer
-func (m *MysqlCDC) OnRow(e *canal.RowsEvent) error {
+// offset of rows event is committed even if none of its rows is sent
+func (m *MysqlCDC) OnRow(e *canal.RowsEvent) (err error) {
+	m.cdcOffset.UpdatePos(e.Header.LogPos)
+	sent := m.Offset
+	defer func() {
+		if m.Offset == sent {
+			m.commits.Skip(m.cdcOffset.OffsetString(m.config.Mode))
+		}
+	}()
 
-	if m.filter.IsFilteredTable(e.Table.Schema, e.Table.Name) {
+	if m.filter.IsExcludedTable(e.Table.Schema, e.Table.Name) || m.methods.IsFilteredMethod(e.Table.Schema, e.Table.Name, e.Action) {
 		return nil
 	}
 
-	m.cdcOffset.UpdatePos(e.Header.LogPos)
-
 	switch e.Action {
 	case canal.InsertAction:
 		return m.parseEvent(e)
@@ -423,6 +484,25 @@ func (m *MysqlCDC) sendEvent(ts uint32, action string, table *schema.Table, even
 		key = strings.Join(primaryKey, ",")
 	}
 
+	columns := m.query.columns[table.Schema][table.Name]
+	NormalizeValues(columns, event)
+	NormalizeValues(columns, oldEvent)
+	// row filter may test excluded columns, they are removed once it is evaluated
+	if m.rowFilter.IsFilteredRow(table.Schema, table.Name, event, oldEvent) {
+		return
+	}
+	for _, column := range table.Columns {
+		if m.filter.IsExcludedColumn(table.Schema, table.Name, column.Name) {
+			delete(event, column.Name)
+			delete(oldEvent, column.Name)
+			delete(columnMeta, column.Name)
+		}
+	}
+	m.masker.MaskValues(table.Schema, table.Name, event)
+	m.masker.MaskValues(table.Schema, table.Name, oldEvent)
+
+	offset := m.cdcOffset.OffsetString(m.config.Mode)
+	m.commits.Sent(offset)
 	m.Offset++
 	m.OutputChannel <- events.LookatchEvent{
 		Header: events.LookatchHeader{
@@ -440,7 +520,7 @@ func (m *MysqlCDC) sendEvent(ts uint32, action string, table *schema.Table, even
 			Statement:    event,
 			PrimaryKey:   key,
 			Offset: &events.Offset{
-				Source: m.cdcOffset.OffsetString(m.config.Mode),
+				Source: offset,
 				Agent:  strconv.FormatInt(m.Offset, 10),
 			},
 		},
@@ -682,6 +762,6 @@ func (m *MysqlOffset) GTIDSet() mysql.GTIDSet {
 // UpdateCommittedLsn  update CommittedLsn
 func (m *MysqlCDC) UpdateCommittedLsn() {
 	for committedOffset := range m.CommitChannel {
-		m.meta.CommittedOffset = committedOffset.(string)
+		m.commits.Commit(committedOffset.(string))
 	}
 }
@@ -34,7 +34,7 @@ type (
 
 // NewMysqlQuery create a Mysql Query source
 func NewMysqlQuery(s *Source) (SourceI, error) {
-	gdbcQuery := NewDBSQLQuery(s)
+	gdbcQuery := NewDBSQLQuery(s, MysqlQueryType)
 
 	mysqlQueryConfig := MysqlQueryConfig{}
 	err := s.Conf.UnmarshalKey("sources."+s.Name, &mysqlQueryConfig)
@@ -43,6 +43,9 @@ func NewMysqlQuery(s *Source) (SourceI, error) {
 	}
 
 	mysqlQueryConfig.DBSQLQueryConfig = &gdbcQuery.Config
+	if err = gdbcQuery.initFilters(); err != nil {
+		return nil, err
+	}
 
 	return &MySQLQuery{
 		DBSQLQuery: &gdbcQuery,
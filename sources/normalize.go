@@ -0,0 +1,351 @@
+package sources
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of column values, values of a kind are normalized the same way whichever database they come from
+const (
+	KindInteger   = "integer"
+	KindFloat     = "float"
+	KindDecimal   = "decimal"
+	KindBoolean   = "boolean"
+	KindDate      = "date"
+	KindTimestamp = "timestamp"
+	KindBinary    = "binary"
+	KindJSON      = "json"
+	KindString    = "string"
+)
+
+// DateLayout layout of normalized dates
+const DateLayout = "2006-01-02"
+
+// kinds kind of SQL data types of MySQL, PostgreSQL and SQL Server
+var kinds = map[string]string{
+	"tinyint":                     KindInteger,
+	"smallint":                    KindInteger,
+	"mediumint":                   KindInteger,
+	"int":                         KindInteger,
+	"integer":                     KindInteger,
+	"bigint":                      KindInteger,
+	"int2":                        KindInteger,
+	"int4":                        KindInteger,
+	"int8":                        KindInteger,
+	"smallserial":                 KindInteger,
+	"serial":                      KindInteger,
+	"bigserial":                   KindInteger,
+	"year":                        KindInteger,
+	"float":                       KindFloat,
+	"float4":                      KindFloat,
+	"float8":                      KindFloat,
+	"double":                      KindFloat,
+	"double precision":            KindFloat,
+	"real":                        KindFloat,
+	"decimal":                     KindDecimal,
+	"numeric":                     KindDecimal,
+	"dec":                         KindDecimal,
+	"fixed":                       KindDecimal,
+	"money":                       KindDecimal,
+	"smallmoney":                  KindDecimal,
+	"boolean":                     KindBoolean,
+	"bool":                        KindBoolean,
+	"date":                        KindDate,
+	"datetime":                    KindTimestamp,
+	"datetime2":                   KindTimestamp,
+	"smalldatetime":               KindTimestamp,
+	"datetimeoffset":              KindTimestamp,
+	"timestamp":                   KindTimestamp,
+	"timestamptz":                 KindTimestamp,
+	"timestamp without time zone": KindTimestamp,
+	"timestamp with time zone":    KindTimestamp,
+	"binary":                      KindBinary,
+	"varbinary":                   KindBinary,
+	"tinyblob":                    KindBinary,
+	"blob":                        KindBinary,
+	"mediumblob":                  KindBinary,
+	"longblob":                    KindBinary,
+	"bytea":                       KindBinary,
+	"image":                       KindBinary,
+	"rowversion":                  KindBinary,
+	"json":                        KindJSON,
+	"jsonb":                       KindJSON,
+}
+
+// timestampLayouts layouts of timestamps read as text, values without zone are in UTC
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999 Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+}
+
+// Kind return kind of column values from its data type
+// bit columns of one bit are booleans, other ones integers
+func (c *Column) Kind() string {
+	dataType := strings.ToLower(strings.TrimSpace(c.DataType))
+	if i := strings.Index(dataType, "("); i != -1 {
+		dataType = strings.TrimSpace(dataType[:i])
+	}
+	if dataType == "bit" {
+		columnType := strings.ToLower(strings.TrimSpace(c.ColumnType))
+		if columnType == "" || columnType == "bit" || columnType == "bit(1)" {
+			return KindBoolean
+		}
+		return KindInteger
+	}
+	if kind, ok := kinds[dataType]; ok {
+		return kind
+	}
+	return KindString
+}
+
+// Normalize convert value read from column to its normalized representation
+// integers are int64 (uint64 when unsigned), floats float64, decimals strings, booleans bool,
+// dates and timestamps RFC 3339 strings, binaries base64 strings, JSON decoded
+// a value which can't be converted is returned as read, bytes as string
+func (c *Column) Normalize(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	var normalized interface{}
+	var ok bool
+	switch c.Kind() {
+	case KindInteger:
+		normalized, ok = normalizeInteger(value)
+	case KindFloat:
+		normalized, ok = normalizeFloat(value)
+	case KindDecimal:
+		normalized, ok = normalizeDecimal(value)
+	case KindBoolean:
+		normalized, ok = normalizeBoolean(value)
+	case KindDate:
+		normalized, ok = normalizeTime(value, DateLayout)
+	case KindTimestamp:
+		normalized, ok = normalizeTime(value, time.RFC3339Nano)
+		if !ok {
+			// rowversion of SQL Server is named timestamp
+			if b, isBytes := value.([]byte); isBytes {
+				normalized, ok = base64.StdEncoding.EncodeToString(b), true
+			}
+		}
+	case KindBinary:
+		normalized, ok = normalizeBinary(value)
+	case KindJSON:
+		normalized, ok = normalizeJSON(value)
+	}
+	if ok {
+		return normalized
+	}
+	if b, isBytes := value.([]byte); isBytes {
+		return string(b)
+	}
+	return value
+}
+
+// normalizeInteger convert value to int64, uint64 for unsigned values
+func normalizeInteger(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return uint64(v), true
+	case uint8:
+		return uint64(v), true
+	case uint16:
+		return uint64(v), true
+	case uint32:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	case float32:
+		return normalizeInteger(float64(v))
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<63 {
+			return int64(v), true
+		}
+	case bool:
+		if v {
+			return int64(1), true
+		}
+		return int64(0), true
+	case json.Number:
+		return normalizeInteger(string(v))
+	case []byte:
+		return normalizeInteger(string(v))
+	case string:
+		s := strings.TrimSpace(v)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return u, true
+		}
+	}
+	return nil, false
+}
+
+// normalizeFloat convert value to float64
+// float32 values are converted through their shortest representation to keep their digits
+func normalizeFloat(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case float32:
+		f, err := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'g', -1, 32), 64)
+		return f, err == nil
+	case float64:
+		return v, true
+	case json.Number:
+		return normalizeFloat(string(v))
+	case []byte:
+		return normalizeFloat(string(v))
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	if i, ok := normalizeInteger(value); ok {
+		switch n := i.(type) {
+		case int64:
+			return float64(n), true
+		case uint64:
+			return float64(n), true
+		}
+	}
+	return nil, false
+}
+
+// normalizeDecimal convert value to its exact string representation
+func normalizeDecimal(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case json.Number:
+		return string(v), true
+	case []byte:
+		return strings.TrimSpace(string(v)), true
+	case string:
+		// money of PostgreSQL is read with its currency symbol
+		return strings.TrimSpace(v), true
+	}
+	if i, ok := normalizeInteger(value); ok {
+		switch n := i.(type) {
+		case int64:
+			return strconv.FormatInt(n, 10), true
+		case uint64:
+			return strconv.FormatUint(n, 10), true
+		}
+	}
+	return nil, false
+}
+
+// normalizeBoolean convert value to bool
+func normalizeBoolean(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case []byte:
+		if len(v) == 1 && v[0] <= 1 {
+			return v[0] == 1, true
+		}
+		return normalizeBoolean(string(v))
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		return b, err == nil
+	}
+	if i, ok := normalizeInteger(value); ok {
+		return i != int64(0) && i != uint64(0), true
+	}
+	return nil, false
+}
+
+// normalizeTime format time value with layout, in UTC if value has no zone
+func normalizeTime(value interface{}, layout string) (interface{}, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(layout), true
+	case []byte:
+		return normalizeTime(string(v), layout)
+	case string:
+		s := strings.TrimSpace(v)
+		if layout == DateLayout && len(s) >= len(DateLayout) {
+			if t, err := time.Parse(DateLayout, s[:len(DateLayout)]); err == nil {
+				return t.Format(layout), true
+			}
+			return nil, false
+		}
+		for _, timestampLayout := range timestampLayouts {
+			if t, err := time.Parse(timestampLayout, s); err == nil {
+				return t.Format(layout), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// normalizeBinary encode value in base64
+// bytea read as text by wal2json are hex encoded
+func normalizeBinary(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v), true
+	case string:
+		if strings.HasPrefix(v, `\x`) {
+			if b, err := hex.DecodeString(v[2:]); err == nil {
+				return base64.StdEncoding.EncodeToString(b), true
+			}
+		}
+		return base64.StdEncoding.EncodeToString([]byte(v)), true
+	}
+	return nil, false
+}
+
+// normalizeJSON decode JSON document, numbers are kept as json.Number
+func normalizeJSON(value interface{}) (interface{}, bool) {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		// already decoded
+		return value, true
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, false
+	}
+	return decoded, true
+}
+
+// NormalizeValues normalize values of columns of a table of schema
+// values of columns missing from schema are left unchanged
+func NormalizeValues(columns map[string]*Column, values map[string]interface{}) {
+	if len(columns) == 0 {
+		return
+	}
+	for name, value := range values {
+		if column, ok := columns[name]; ok {
+			values[name] = column.Normalize(value)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package sources
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestColumnKind(t *testing.T) {
+	tests := []struct {
+		column Column
+		kind   string
+	}{
+		{Column{DataType: "bigint"}, KindInteger},
+		{Column{DataType: "INT"}, KindInteger},
+		{Column{DataType: "double precision"}, KindFloat},
+		{Column{DataType: "numeric"}, KindDecimal},
+		{Column{DataType: "decimal(10,2)"}, KindDecimal},
+		{Column{DataType: "bit", ColumnType: "bit(1)"}, KindBoolean},
+		{Column{DataType: "bit", ColumnType: "bit(8)"}, KindInteger},
+		{Column{DataType: "bit", ColumnType: "bit"}, KindBoolean},
+		{Column{DataType: "timestamp with time zone"}, KindTimestamp},
+		{Column{DataType: "datetime2"}, KindTimestamp},
+		{Column{DataType: "date"}, KindDate},
+		{Column{DataType: "bytea"}, KindBinary},
+		{Column{DataType: "jsonb"}, KindJSON},
+		{Column{DataType: "character varying"}, KindString},
+		{Column{DataType: "USER-DEFINED"}, KindString},
+	}
+	for _, test := range tests {
+		if kind := test.column.Kind(); kind != test.kind {
+			t.Errorf("%s: expected %s, got %s", test.column.DataType, test.kind, kind)
+		}
+	}
+}
+
+func TestColumnNormalize(t *testing.T) {
+	paris := time.FixedZone("CET", 3600)
+	tests := []struct {
+		dataType string
+		value    interface{}
+		expected interface{}
+	}{
+		{"int", int32(42), int64(42)},
+		{"bigint", uint64(18446744073709551615), uint64(18446744073709551615)},
+		{"integer", json.Number("42"), int64(42)},
+		{"integer", float64(42), int64(42)},
+		{"int", []byte("42"), int64(42)},
+		{"float", float32(0.1), 0.1},
+		{"double precision", json.Number("2.5"), 2.5},
+		{"decimal", []byte("12345678901234567890.12"), "12345678901234567890.12"},
+		{"numeric", json.Number("0.10"), "0.10"},
+		{"decimal", "19.99", "19.99"},
+		{"decimal", float64(1.5), "1.5"},
+		{"boolean", "t", true},
+		{"bit", []byte{1}, true},
+		{"bit", int64(0), false},
+		{"varchar", []byte("01234"), "01234"},
+		{"char", "01234", "01234"},
+		{"date", "2020-01-02", "2020-01-02"},
+		{"date", time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), "2020-01-02"},
+		{"datetime", "2020-01-02 03:04:05", "2020-01-02T03:04:05Z"},
+		{"timestamp with time zone", "2020-01-02 03:04:05.5+01", "2020-01-02T03:04:05.5+01:00"},
+		{"datetimeoffset", time.Date(2020, 1, 2, 3, 4, 5, 0, paris), "2020-01-02T03:04:05+01:00"},
+		{"datetime", "0000-00-00 00:00:00", "0000-00-00 00:00:00"},
+		{"timestamp", []byte{0, 0, 0, 0, 0, 0, 7, 209}, "AAAAAAAAB9E="},
+		{"bytea", `\x0102`, "AQI="},
+		{"blob", []byte{1, 2}, "AQI="},
+		{"json", `{"a":1,"b":[true]}`, map[string]interface{}{"a": json.Number("1"), "b": []interface{}{true}}},
+		{"jsonb", "not json", "not json"},
+		{"int", nil, nil},
+	}
+	for _, test := range tests {
+		column := &Column{DataType: test.dataType}
+		if normalized := column.Normalize(test.value); !reflect.DeepEqual(normalized, test.expected) {
+			t.Errorf("%s %#v: expected %#v, got %#v", test.dataType, test.value, test.expected, normalized)
+		}
+	}
+}
+
+func TestNormalizeValues(t *testing.T) {
+	columns := map[string]*Column{
+		"id":     {Column: "id", DataType: "int"},
+		"amount": {Column: "amount", DataType: "decimal"},
+	}
+	values := map[string]interface{}{
+		"id":       []byte("1"),
+		"amount":   []byte("1.10"),
+		"computed": []byte("x"),
+	}
+	NormalizeValues(columns, values)
+	if values["id"] != int64(1) || values["amount"] != "1.10" {
+		t.Errorf("unexpected values %v", values)
+	}
+	if !reflect.DeepEqual(values["computed"], []byte("x")) {
+		t.Error("column missing from schema expected to be unchanged")
+	}
+}
@@ -0,0 +1,177 @@
+package sources
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/spf13/viper"
+)
+
+// FileOffsetStoreType type of offset store
+const FileOffsetStoreType = "File"
+
+// DefaultOffsetStorePath default file used by file offset store
+const DefaultOffsetStorePath = "offsets.json"
+
+type (
+	// Checkpoint representation of a source position
+	// Offset is the committed source offset, Agent the source Offset counter
+	Checkpoint struct {
+		Offset    string `json:"offset"`
+		Agent     int64  `json:"agent"`
+		Timestamp int64  `json:"timestamp"`
+	}
+
+	// OffsetStore interface of offset store
+	OffsetStore interface {
+		Load(sourceName string) (*Checkpoint, error)
+		Save(sourceName string, checkpoint *Checkpoint) error
+		Flush() error
+	}
+
+	// FileOffsetStoreConfig representation of file offset store configuration
+	FileOffsetStoreConfig struct {
+		Path string `json:"path"`
+	}
+
+	// FileOffsetStore store checkpoints of all sources in a json file
+	FileOffsetStore struct {
+		sync.RWMutex
+		config      FileOffsetStoreConfig
+		checkpoints map[string]*Checkpoint
+	}
+)
+
+// offsetStoreCreator offset store Creator func
+type offsetStoreCreator func(*viper.Viper) (OffsetStore, error)
+
+// OffsetStoreFactory offset store Factory
+var OffsetStoreFactory = map[string]offsetStoreCreator{
+	FileOffsetStoreType: NewFileOffsetStore,
+}
+
+// NewOffsetStore create offset store from agent configuration
+// return nil if no offset store is configured
+func NewOffsetStore(config *viper.Viper) (OffsetStore, error) {
+	storeType := config.GetString("agent.offset_store.type")
+	if storeType == "" {
+		return nil, nil
+	}
+
+	storeCreatorFunc, found := OffsetStoreFactory[storeType]
+	if !found {
+		return nil, errors.Errorf("Offset store type not found '%s'", storeType)
+	}
+
+	return storeCreatorFunc(config.Sub("agent.offset_store"))
+}
+
+// NewFileOffsetStore create new file offset store
+// existing checkpoints are loaded from file
+func NewFileOffsetStore(conf *viper.Viper) (OffsetStore, error) {
+	storeConfig := FileOffsetStoreConfig{}
+	err := conf.Unmarshal(&storeConfig)
+	if err != nil {
+		return nil, err
+	}
+	if storeConfig.Path == "" {
+		storeConfig.Path = DefaultOffsetStorePath
+	}
+
+	f := &FileOffsetStore{
+		config:      storeConfig,
+		checkpoints: make(map[string]*Checkpoint),
+	}
+
+	content, err := ioutil.ReadFile(storeConfig.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return f, nil
+		}
+		return nil, errors.Annotate(err, "error while reading offset store")
+	}
+
+	if len(content) > 0 {
+		err = json.Unmarshal(content, &f.checkpoints)
+		if err != nil {
+			return nil, errors.Annotate(err, "error while parsing offset store")
+		}
+	}
+	return f, nil
+}
+
+// Load return last checkpoint of a source, nil if none found
+func (f *FileOffsetStore) Load(sourceName string) (*Checkpoint, error) {
+	f.RLock()
+	defer f.RUnlock()
+
+	checkpoint, ok := f.checkpoints[sourceName]
+	if !ok {
+		return nil, nil
+	}
+	cp := *checkpoint
+	return &cp, nil
+}
+
+// Save set checkpoint of a source, it will be written on next flush
+func (f *FileOffsetStore) Save(sourceName string, checkpoint *Checkpoint) error {
+	if checkpoint == nil {
+		return nil
+	}
+	f.Lock()
+	cp := *checkpoint
+	cp.Timestamp = time.Now().Unix()
+	f.checkpoints[sourceName] = &cp
+	f.Unlock()
+	return nil
+}
+
+// Flush write all checkpoints to file
+// content is written to a temporary file synced on disk then renamed
+func (f *FileOffsetStore) Flush() (err error) {
+	f.RLock()
+	content, err := json.Marshal(f.checkpoints)
+	f.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(f.config.Path)
+	tmp, err := ioutil.TempFile(dir, filepath.Base(f.config.Path)+".tmp")
+	if err != nil {
+		return errors.Annotate(err, "error while creating offset store file")
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return errors.Annotate(err, "error while writing offset store file")
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Annotate(err, "error while syncing offset store file")
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), f.config.Path); err != nil {
+		return errors.Annotate(err, "error while renaming offset store file")
+	}
+
+	// sync directory to persist rename
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,92 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func newTestOffsetStore(t *testing.T, path string) OffsetStore {
+	vStore := viper.New()
+	vStore.Set("agent.offset_store.type", FileOffsetStoreType)
+	vStore.Set("agent.offset_store.path", path)
+
+	store, err := NewOffsetStore(vStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestNewOffsetStoreNotConfigured(t *testing.T) {
+	store, err := NewOffsetStore(viper.New())
+	if err != nil || store != nil {
+		t.Fail()
+	}
+}
+
+func TestNewOffsetStoreBadType(t *testing.T) {
+	vStore := viper.New()
+	vStore.Set("agent.offset_store.type", "toto")
+
+	_, err := NewOffsetStore(vStore)
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestFileOffsetStoreFlushAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offsets.json")
+	store := newTestOffsetStore(t, path)
+
+	err := store.Save("default", &Checkpoint{Offset: "mysql-bin.000001:4:", Agent: 42})
+	if err != nil {
+		t.Error(err)
+	}
+	err = store.Flush()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, err = os.Stat(path); err != nil {
+		t.Error(err)
+	}
+
+	reloaded := newTestOffsetStore(t, path)
+	checkpoint, err := reloaded.Load("default")
+	if err != nil {
+		t.Error(err)
+	}
+	if checkpoint == nil || checkpoint.Offset != "mysql-bin.000001:4:" || checkpoint.Agent != 42 {
+		t.Fail()
+	}
+}
+
+func TestFileOffsetStoreLoadUnknown(t *testing.T) {
+	store := newTestOffsetStore(t, filepath.Join(t.TempDir(), "offsets.json"))
+
+	checkpoint, err := store.Load("default")
+	if err != nil || checkpoint != nil {
+		t.Fail()
+	}
+}
+
+func TestSourceLoadCheckpoint(t *testing.T) {
+	store := newTestOffsetStore(t, filepath.Join(t.TempDir(), "offsets.json"))
+	store.Save("default", &Checkpoint{Agent: 42})
+
+	source, err := New("default", RandomType, vSource, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = source.Start()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if source.GetCheckpoint().Agent != 42 {
+		t.Fail()
+	}
+}
@@ -1,6 +1,7 @@
 package sources
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -27,9 +28,14 @@ type (
 		config         PostgreSQLCDCConf
 		query          *PostgreSQLQuery
 		filter         *utils.Filter
+		masker         *utils.Masker
+		rowFilter      *utils.RowFilter
+		methods        *utils.MethodFilter
 		conn           *pgconn.PgConn
 		meta           Meta
 		ctx            context.Context
+		cancel         context.CancelFunc
+		stopped        chan struct{}
 		CommittedState *OffsetCommittedState
 	}
 
@@ -47,7 +53,11 @@ type (
 		SlotName         string                 `json:"slot_name" mapstructure:"slot_name"`
 		FilterPolicy     string                 `json:"filter_policy" mapstructure:"filter_policy"`
 		Filter           map[string]interface{} `json:"filter"`
+		FilterPatterns   []string               `json:"filter_patterns" mapstructure:"filter_patterns"`
 		DefinedPk        map[string]string      `json:"defined_pk" mapstructure:"defined_pk"`
+		Mask             map[string]interface{} `json:"mask"`
+		RowFilter        map[string]interface{} `json:"row_filter" mapstructure:"row_filter"`
+		Methods          map[string]interface{} `json:"methods"`
 	}
 
 	// Messages representation of messages
@@ -102,6 +112,22 @@ func NewPostgreSQLCdc(s *Source) (SourceI, error) {
 	if err != nil {
 		return nil, err
 	}
+	masker, err := utils.NewMasker(postgreSQLCDCConf.Mask)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := utils.NewFilter(postgreSQLCDCConf.FilterPolicy, postgreSQLCDCConf.Filter, postgreSQLCDCConf.FilterPatterns)
+	if err != nil {
+		return nil, err
+	}
+	rowFilter, err := utils.NewRowFilter(postgreSQLCDCConf.RowFilter)
+	if err != nil {
+		return nil, err
+	}
+	methods, err := utils.NewMethodFilter(postgreSQLCDCConf.Methods)
+	if err != nil {
+		return nil, err
+	}
 
 	query := &PostgreSQLQuery{
 		DBSQLQuery: &DBSQLQuery{
@@ -121,13 +147,13 @@ func NewPostgreSQLCdc(s *Source) (SourceI, error) {
 		},
 	}
 	p := &PostgreSQLCDC{
-		Source: s,
-		query:  query,
-		config: postgreSQLCDCConf,
-		filter: &utils.Filter{
-			FilterPolicy: postgreSQLCDCConf.FilterPolicy,
-			Filter:       postgreSQLCDCConf.Filter,
-		},
+		Source:         s,
+		query:          query,
+		config:         postgreSQLCDCConf,
+		filter:         filter,
+		masker:         masker,
+		rowFilter:      rowFilter,
+		methods:        methods,
 		ctx:            context.Background(),
 		CommittedState: NewOffsetCommittedState(),
 	}
@@ -149,13 +175,20 @@ func (p *PostgreSQLCDC) GetSchema() map[string]map[string]*Column {
 func (p *PostgreSQLCDC) Start(i ...interface{}) (err error) {
 	log.WithField("type", PostgreSQLCDCType).Info("Start")
 
+	p.startCommitUpdater(p.UpdateCommittedLsn)
 	err = p.Source.Start(i)
 	if err != nil {
 		return
 	}
 
-	go p.UpdateCommittedLsn()
+	if checkpoint := p.LoadCheckpoint(); checkpoint != nil {
+		if lsn, errParse := pglogrepl.ParseLSN(checkpoint.Offset); errParse == nil {
+			p.meta.CommittedLsn = lsn
+		}
+	}
 
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.stopped = make(chan struct{})
 	p.StartReplication()
 	// consume events
 	go p.decodeEvents()
@@ -163,6 +196,19 @@ func (p *PostgreSQLCDC) Start(i ...interface{}) (err error) {
 	return nil
 }
 
+// Stop source
+// stop decoding events and close replication connection
+func (p *PostgreSQLCDC) Stop() error {
+	if p.cancel == nil {
+		return nil
+	}
+	p.cancel()
+	<-p.stopped
+	p.cancel = nil
+	p.Status = SourceStatusWaiting
+	return nil
+}
+
 // GetMeta get metadata
 func (p *PostgreSQLCDC) GetMeta() map[string]utils.Meta {
 	meta := p.Source.GetMeta()
@@ -173,6 +219,14 @@ func (p *PostgreSQLCDC) GetMeta() map[string]utils.Meta {
 	return meta
 }
 
+// GetCheckpoint returns committed lsn to persist in offset store
+func (p *PostgreSQLCDC) GetCheckpoint() *Checkpoint {
+	return &Checkpoint{
+		Offset: p.meta.CommittedLsn.String(),
+		Agent:  p.Offset,
+	}
+}
+
 // HealthCheck returns true if ok
 func (p *PostgreSQLCDC) HealthCheck() bool {
 	return p.Source.HealthCheck() && p.meta.SlotStatus
@@ -229,7 +283,10 @@ func (p *PostgreSQLCDC) StartReplication() {
 			"DBName":   sysident.DBName,
 		}).Info("IdentifySystem")
 
-	p.meta.CommittedLsn, _ = p.GetConfirmedFlushLsn()
+	// slot position is used when available, otherwise keep the checkpoint
+	if lsn, err := p.GetConfirmedFlushLsn(); err == nil {
+		p.meta.CommittedLsn = lsn
+	}
 	// start replication
 	log.WithField("offset", p.meta.CommittedLsn).Debug("Starting replication")
 
@@ -297,7 +354,12 @@ func (p *PostgreSQLCDC) decodeEvents() {
 	standbyTimeout := time.Second * TickerValue
 	nextStatusSend := time.Now().Add(standbyTimeout)
 	processEvent := false
+	defer close(p.stopped)
 	for {
+		if p.ctx.Err() != nil {
+			p.closeReplication()
+			return
+		}
 		if time.Now().After(nextStatusSend) {
 			p.checkStatus(p.CommittedState.IsEmpty())
 			nextStatusSend = time.Now().Add(standbyTimeout)
@@ -309,7 +371,7 @@ func (p *PostgreSQLCDC) decodeEvents() {
 		repMsg, err = p.conn.ReceiveMessage(ctx)
 		cancel()
 		if err != nil {
-			if pgconn.Timeout(err) {
+			if pgconn.Timeout(err) || p.ctx.Err() != nil {
 				continue
 			}
 			if p.conn.IsClosed() {
@@ -357,7 +419,10 @@ func (p *PostgreSQLCDC) decodeEvents() {
 				}
 				p.meta.CurrentLsn = xld.WALStart
 				msgs = &Messages{}
-				err = json.Unmarshal(utils.EscapeCtrl(xld.WALData), msgs)
+				// numbers are kept as json.Number so decimals are not rounded
+				decoder := json.NewDecoder(bytes.NewReader(utils.EscapeCtrl(xld.WALData)))
+				decoder.UseNumber()
+				err = decoder.Decode(msgs)
 				if err != nil {
 					log.WithFields(log.Fields{
 						"error": err,
@@ -388,7 +453,8 @@ func (p *PostgreSQLCDC) processMsgs(msgs *Messages, serverTime int64) {
 	for _, msg := range msgs.Change {
 		p.meta.LastState = "Waiting for next pg event"
 
-		if p.filter.IsFilteredTable(msg.Schema, msg.Table) {
+		// suppressed rows are not added to committed state, their lsn is committed once state is empty
+		if p.filter.IsExcludedTable(msg.Schema, msg.Table) || p.methods.IsFilteredMethod(msg.Schema, msg.Table, msg.Kind) {
 			continue
 		}
 
@@ -400,6 +466,16 @@ func (p *PostgreSQLCDC) processMsgs(msgs *Messages, serverTime int64) {
 		}
 
 		statement, OldStatement, columTypes, key := p.fieldsToMap(msg)
+		columns := p.query.columns[msg.Schema][msg.Table]
+		NormalizeValues(columns, statement)
+		NormalizeValues(columns, OldStatement)
+		// row filter may test excluded columns, they are removed once it is evaluated
+		if p.rowFilter.IsFilteredRow(msg.Schema, msg.Table, statement, OldStatement) {
+			continue
+		}
+		p.filter.RemoveExcludedColumns(msg.Schema, msg.Table, statement, OldStatement)
+		p.masker.MaskValues(msg.Schema, msg.Table, statement)
+		p.masker.MaskValues(msg.Schema, msg.Table, OldStatement)
 		p.CommittedState.Add(p.meta.CurrentLsn)
 		p.OutputChannel <- events.LookatchEvent{
 			Header: events.LookatchHeader{
@@ -429,6 +505,7 @@ func (p *PostgreSQLCDC) processMsgs(msgs *Messages, serverTime int64) {
 }
 
 // fieldsToJSON map fields to json
+// values of excluded columns are kept, so row filter can test them
 func (p *PostgreSQLCDC) fieldsToMap(msg Message) (map[string]interface{}, map[string]interface{}, map[string]events.ColumnsMeta, string) {
 	var key string
 	var columnNames []string
@@ -452,16 +529,14 @@ func (p *PostgreSQLCDC) fieldsToMap(msg Message) (map[string]interface{}, map[st
 	if p.config.OldValue {
 		if msg.Kind != "insert" {
 			for index, element := range msg.Oldkeys.Keynames {
-				if !p.filter.IsFilteredColumn(msg.Schema, msg.Table, element) {
-					o[element] = msg.Oldkeys.Keyvalues[index]
-				}
+				o[element] = msg.Oldkeys.Keyvalues[index]
 			}
 		}
 	}
 	keys := make([]string, 0)
 	for index, element := range columnNames {
-		if !p.filter.IsFilteredColumn(msg.Schema, msg.Table, element) {
-			s[element] = columnValues[index]
+		s[element] = columnValues[index]
+		if !p.filter.IsExcludedColumn(msg.Schema, msg.Table, element) {
 			if p.config.ColumnsMetaValue {
 				c[element] = events.ColumnsMeta{
 					Type:     columnTypes[index],
@@ -483,6 +558,27 @@ func (p *PostgreSQLCDC) fieldsToMap(msg Message) (map[string]interface{}, map[st
 	return s, o, c, key
 }
 
+// closeReplication send committed lsn to server and close replication connection
+func (p *PostgreSQLCDC) closeReplication() {
+	if p.conn == nil || p.conn.IsClosed() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*TickerValue)
+	defer cancel()
+
+	standbyStatusUpdate := pglogrepl.StandbyStatusUpdate{
+		WALWritePosition: p.meta.CommittedLsn,
+	}
+	err := pglogrepl.SendStandbyStatusUpdate(ctx, p.conn, standbyStatusUpdate)
+	if err != nil {
+		log.WithError(err).Error("SendStandbyStatusUpdate failed")
+	}
+	err = p.conn.Close(ctx)
+	if err != nil {
+		log.WithError(err).Error("Error while closing replication connection")
+	}
+}
+
 // GetSlotStatus get slot status
 func (p *PostgreSQLCDC) GetSlotStatus() bool {
 	// Fetch the restart LSN of the slot, to establish a starting point
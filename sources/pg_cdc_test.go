@@ -1,6 +1,7 @@
 package sources
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"gopkg.in/guregu/null.v3"
 
 	"github.com/Pirionfr/lookatch-agent/events"
+	"github.com/Pirionfr/lookatch-agent/utils"
 )
 
 var vPgcdc *viper.Viper
@@ -402,6 +404,239 @@ func TestProcessMsgs(t *testing.T) {
 	}
 }
 
+func TestProcessMsgsMask(t *testing.T) {
+	pgQuery, ok := NewPostgreSQLCdc(sPgcdc)
+	if ok != nil {
+		t.Fail()
+	}
+	pCDC := pgQuery.(*PostgreSQLCDC)
+	pCDC.filter.FilterPolicy = "accept"
+	pCDC.config.OldValue = true
+	masker, err := utils.NewMasker(map[string]interface{}{
+		"schematest": map[string]interface{}{
+			"tabletest": map[string]interface{}{"email": "redact"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pCDC.masker = masker
+
+	msg := Message{
+		Columnnames:  []string{"email"},
+		Columntypes:  []string{"TEXT"},
+		Columnvalues: []interface{}{"new@example.com"},
+		Kind:         "update",
+		Schema:       "SchemaTest",
+		Table:        "TableTest",
+		Oldkeys: Oldkeys{
+			Keynames:  []string{"email"},
+			Keytypes:  []string{"TEXT"},
+			Keyvalues: []interface{}{"old@example.com"},
+		},
+	}
+	pCDC.processMsgs(&Messages{Change: []Message{msg}}, 0)
+
+	sqlEvent := (<-pgQuery.GetOutputChan()).Payload.(events.SQLEvent)
+	if sqlEvent.Statement["email"] != utils.DefaultMaskReplacement || sqlEvent.OldStatement["email"] != utils.DefaultMaskReplacement {
+		t.Fail()
+	}
+}
+
+func TestProcessMsgsRowFilter(t *testing.T) {
+	pgQuery, ok := NewPostgreSQLCdc(sPgcdc)
+	if ok != nil {
+		t.Fail()
+	}
+	pCDC := pgQuery.(*PostgreSQLCDC)
+	pCDC.filter.FilterPolicy = "accept"
+	rowFilter, err := utils.NewRowFilter(map[string]interface{}{
+		"schematest": map[string]interface{}{"tabletest": "col1 > 1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pCDC.rowFilter = rowFilter
+
+	msg := Message{
+		Columnnames:  []string{"col1"},
+		Columntypes:  []string{"INT4"},
+		Columnvalues: []interface{}{1},
+		Kind:         "insert",
+		Schema:       "SchemaTest",
+		Table:        "TableTest",
+	}
+	matching := msg
+	matching.Columnvalues = []interface{}{2}
+	pCDC.processMsgs(&Messages{Change: []Message{msg, matching}}, 0)
+
+	if (<-pgQuery.GetOutputChan()).Payload.(events.SQLEvent).Statement["col1"] != 2 {
+		t.Fail()
+	}
+}
+
+func TestProcessMsgsColumnFilter(t *testing.T) {
+	pgQuery, ok := NewPostgreSQLCdc(sPgcdc)
+	if ok != nil {
+		t.Fail()
+	}
+	pCDC := pgQuery.(*PostgreSQLCDC)
+	pCDC.config.OldValue = true
+	pCDC.filter.FilterPolicy = "accept"
+	pCDC.filter.Filter = map[string]interface{}{
+		"schematest": map[string]interface{}{"tabletest": []interface{}{"secret"}},
+	}
+
+	msg := Message{
+		Columnnames:  []string{"col1", "secret"},
+		Columntypes:  []string{"INT4", "TEXT"},
+		Columnvalues: []interface{}{2, "new"},
+		Kind:         "update",
+		Schema:       "SchemaTest",
+		Table:        "TableTest",
+		Oldkeys: Oldkeys{
+			Keynames:  []string{"col1", "secret"},
+			Keytypes:  []string{"INT4", "TEXT"},
+			Keyvalues: []interface{}{1, "old"},
+		},
+	}
+	pCDC.processMsgs(&Messages{Change: []Message{msg}}, 0)
+
+	event := (<-pgQuery.GetOutputChan()).Payload.(events.SQLEvent)
+	if _, ok := event.Statement["secret"]; ok {
+		t.Error("column filtered from new image expected")
+	}
+	if _, ok := event.OldStatement["secret"]; ok {
+		t.Error("column filtered from old image expected")
+	}
+	if event.Statement["col1"] != 2 || event.OldStatement["col1"] != 1 {
+		t.Error("other columns expected")
+	}
+}
+
+func TestProcessMsgsRowFilterExcludedColumn(t *testing.T) {
+	pgQuery, ok := NewPostgreSQLCdc(sPgcdc)
+	if ok != nil {
+		t.Fail()
+	}
+	pCDC := pgQuery.(*PostgreSQLCDC)
+	pCDC.filter.FilterPolicy = "accept"
+	pCDC.filter.Filter = map[string]interface{}{
+		"schematest": map[string]interface{}{"tabletest": []interface{}{"tenant"}},
+	}
+	rowFilter, err := utils.NewRowFilter(map[string]interface{}{
+		"schematest": map[string]interface{}{"tabletest": "tenant == 42"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pCDC.rowFilter = rowFilter
+
+	msg := Message{
+		Columnnames:  []string{"col1", "tenant"},
+		Columntypes:  []string{"INT4", "INT4"},
+		Columnvalues: []interface{}{1, 7},
+		Kind:         "insert",
+		Schema:       "SchemaTest",
+		Table:        "TableTest",
+	}
+	matching := msg
+	matching.Columnvalues = []interface{}{2, 42}
+	pCDC.processMsgs(&Messages{Change: []Message{msg, matching}}, 0)
+
+	event := (<-pgQuery.GetOutputChan()).Payload.(events.SQLEvent)
+	if event.Statement["col1"] != 2 {
+		t.Error("row matching excluded column expected", event.Statement)
+	}
+	if _, ok := event.Statement["tenant"]; ok {
+		t.Error("column filtered once row filter is evaluated expected")
+	}
+}
+
+func TestProcessMsgsMethods(t *testing.T) {
+	pgQuery, ok := NewPostgreSQLCdc(sPgcdc)
+	if ok != nil {
+		t.Fail()
+	}
+	pCDC := pgQuery.(*PostgreSQLCDC)
+	pCDC.filter.FilterPolicy = "accept"
+	methods, err := utils.NewMethodFilter(map[string]interface{}{
+		"schematest": map[string]interface{}{"tabletest": []interface{}{"insert"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pCDC.methods = methods
+
+	msg := Message{
+		Columnnames:  []string{"col1"},
+		Columntypes:  []string{"INT4"},
+		Columnvalues: []interface{}{1},
+		Kind:         "update",
+		Schema:       "SchemaTest",
+		Table:        "TableTest",
+	}
+	insert := msg
+	insert.Kind = "insert"
+	insert.Columnvalues = []interface{}{2}
+	pCDC.processMsgs(&Messages{Change: []Message{msg, insert}}, 0)
+
+	if (<-pgQuery.GetOutputChan()).Payload.(events.SQLEvent).Statement["col1"] != 2 {
+		t.Fail()
+	}
+	if len(pCDC.CommittedState.SendedLsn) != 1 {
+		t.Error("only sent rows expected in committed state")
+	}
+}
+
+func TestNewPostgreSQLCdcInvalidMethods(t *testing.T) {
+	v := viper.New()
+	v.Set("sources.default.methods", map[string]interface{}{"db": map[string]interface{}{"table": "upsert"}})
+	_, err := NewPostgreSQLCdc(&Source{Name: "default", Conf: v})
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestProcessMsgsNormalize(t *testing.T) {
+	pgQuery, ok := NewPostgreSQLCdc(sPgcdc)
+	if ok != nil {
+		t.Fail()
+	}
+	pCDC := pgQuery.(*PostgreSQLCDC)
+	pCDC.filter.FilterPolicy = "accept"
+	pCDC.query.columns = SQLSchema{
+		"SchemaTest": {"TableTest": {
+			"amount":  {Column: "amount", DataType: "numeric"},
+			"created": {Column: "created", DataType: "timestamp with time zone"},
+		}},
+	}
+
+	msg := Message{
+		Columnnames:  []string{"amount", "created"},
+		Columntypes:  []string{"numeric", "timestamptz"},
+		Columnvalues: []interface{}{json.Number("10.10"), "2020-01-02 03:04:05+00"},
+		Kind:         "insert",
+		Schema:       "SchemaTest",
+		Table:        "TableTest",
+	}
+	pCDC.processMsgs(&Messages{Change: []Message{msg}}, 0)
+
+	statement := (<-pgQuery.GetOutputChan()).Payload.(events.SQLEvent).Statement
+	if statement["amount"] != "10.10" || statement["created"] != "2020-01-02T03:04:05Z" {
+		t.Errorf("unexpected statement %v", statement)
+	}
+}
+
+func TestNewPostgreSQLCdcInvalidMask(t *testing.T) {
+	v := viper.New()
+	v.Set("sources.default.mask", map[string]interface{}{"db": "table"})
+	_, err := NewPostgreSQLCdc(&Source{Name: "default", Conf: v})
+	if err == nil {
+		t.Fail()
+	}
+}
+
 func TestNewOffsetCommittedStateAdd(t *testing.T) {
 	state := NewOffsetCommittedState()
 
@@ -34,7 +34,7 @@ type (
 
 // NewPostgreSQLQuery create a PostgreSQL Query source
 func NewPostgreSQLQuery(s *Source) (SourceI, error) {
-	gdbcQuery := NewDBSQLQuery(s)
+	gdbcQuery := NewDBSQLQuery(s, PostgreSQLQueryType)
 
 	pgQueryConfig := PostgreSQLQueryConfig{}
 	err := s.Conf.UnmarshalKey("sources."+s.Name, &pgQueryConfig)
@@ -43,6 +43,9 @@ func NewPostgreSQLQuery(s *Source) (SourceI, error) {
 	}
 
 	pgQueryConfig.DBSQLQueryConfig = &gdbcQuery.Config
+	if err = gdbcQuery.initFilters(); err != nil {
+		return nil, err
+	}
 
 	return &PostgreSQLQuery{
 		DBSQLQuery: &gdbcQuery,
@@ -20,6 +20,7 @@ type Random struct {
 	config     RandomConfig
 	NbMessages uint64
 	metaMutex  sync.RWMutex
+	stop       chan struct{}
 }
 
 // RandomConfig representation of Random Config
@@ -48,9 +49,15 @@ func (r *Random) Start(i ...interface{}) error {
 	if err := r.Source.Start(i); err != nil {
 		return err
 	}
-	go func() {
+	r.stop = make(chan struct{})
+	go func(stop chan struct{}) {
 		wait, _ := time.ParseDuration(r.config.Wait)
 		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
 			randomData := randomdata.Paragraph()
 			log.WithField("data", randomData).Debug("random.Run()")
 			r.OutputChannel <- events.LookatchEvent{
@@ -72,7 +79,18 @@ func (r *Random) Start(i ...interface{}) error {
 			r.metaMutex.Unlock()
 			time.Sleep(wait)
 		}
-	}()
+	}(r.stop)
+	return nil
+}
+
+// Stop source
+func (r *Random) Stop() error {
+	if r.stop == nil {
+		return nil
+	}
+	close(r.stop)
+	r.stop = nil
+	r.Status = SourceStatusWaiting
 	return nil
 }
 
@@ -84,7 +102,9 @@ func (r *Random) HealthCheck() bool {
 // GetMeta get source meta
 func (r *Random) GetMeta() map[string]utils.Meta {
 	meta := make(map[string]utils.Meta)
+	r.metaMutex.RLock()
 	meta["nbMessages"] = utils.NewMeta("nbMessages", r.NbMessages)
+	r.metaMutex.RUnlock()
 	return meta
 }
 
@@ -1,6 +1,8 @@
 package sources
 
 import (
+	"sync"
+
 	"github.com/juju/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -65,6 +67,7 @@ type (
 		HealthCheck() bool
 		GetCapabilities() map[string]*utils.TaskDescription
 		Process(string, ...interface{}) interface{}
+		GetCheckpoint() *Checkpoint
 	}
 
 	// Source representation of source
@@ -76,6 +79,8 @@ type (
 		Conf          *viper.Viper
 		Offset        int64
 		Status        string
+		OffsetStore   OffsetStore
+		commitUpdater sync.Once
 	}
 )
 
@@ -96,7 +101,8 @@ var Factory = map[string]sourceCreatorT{
 }
 
 // New create new source
-func New(name string, sourceType string, config *viper.Viper) (s SourceI, err error) {
+// store can be nil if no offset store is configured
+func New(name string, sourceType string, config *viper.Viper, store OffsetStore) (s SourceI, err error) {
 	//setup agentHeader
 	agentInfo := &AgentHeader{
 		Tenant: events.LookatchTenantInfo{
@@ -130,6 +136,7 @@ func New(name string, sourceType string, config *viper.Viper) (s SourceI, err er
 		Conf:          config,
 		Offset:        0,
 		Status:        SourceStatusWaitingForMETA,
+		OffsetStore:   store,
 	}
 
 	s, err = sourceCreatorFunc(baseSrc)
@@ -146,13 +153,23 @@ func (s *Source) Stop() error {
 }
 
 // Start source
+// sources consuming their commits start their own updater before calling it
 func (s *Source) Start(i ...interface{}) (err error) {
+	s.LoadCheckpoint()
 	s.Status = SourceStatusRunning
-	log.Debug("start default UpdateCommittedLsn")
-	go s.UpdateCommittedLsn()
+	s.startCommitUpdater(s.UpdateCommittedLsn)
 	return nil
 }
 
+// startCommitUpdater start update, consuming offsets committed by sinks, once per source
+// commit channel is kept when source is restarted, so a single updater must read it
+func (s *Source) startCommitUpdater(update func()) {
+	s.commitUpdater.Do(func() {
+		log.WithField("source", s.Name).Debug("start UpdateCommittedLsn")
+		go update()
+	})
+}
+
 // GetName get name of source
 func (s *Source) GetName() string {
 	return s.Name
@@ -207,3 +224,31 @@ func (s *Source) UpdateCommittedLsn() {
 
 	}
 }
+
+// GetCheckpoint returns source position to persist in offset store
+func (s *Source) GetCheckpoint() *Checkpoint {
+	return &Checkpoint{
+		Agent: s.Offset,
+	}
+}
+
+// LoadCheckpoint get last checkpoint of source from offset store
+// Offset counter is restored if not already set
+// return nil if no checkpoint found
+func (s *Source) LoadCheckpoint() *Checkpoint {
+	if s.OffsetStore == nil {
+		return nil
+	}
+	checkpoint, err := s.OffsetStore.Load(s.Name)
+	if err != nil {
+		log.WithError(err).WithField("source", s.Name).Error("Error while loading checkpoint")
+		return nil
+	}
+	if checkpoint == nil {
+		return nil
+	}
+	if s.Offset == 0 {
+		s.Offset = checkpoint.Agent
+	}
+	return checkpoint
+}
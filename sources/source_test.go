@@ -2,6 +2,7 @@ package sources
 
 import (
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -19,7 +20,7 @@ func init() {
 }
 
 func TestSourcesNew(t *testing.T) {
-	source, ok := New("default", RandomType, vSource)
+	source, ok := New("default", RandomType, vSource, nil)
 	if ok != nil {
 		t.Fail()
 	}
@@ -29,3 +30,19 @@ func TestSourcesNew(t *testing.T) {
 	}
 
 }
+
+func TestSourcesStartCommitUpdaterOnce(t *testing.T) {
+	s := &Source{Name: "default"}
+	started := make(chan struct{}, 2)
+	update := func() {
+		started <- struct{}{}
+	}
+	s.startCommitUpdater(update)
+	s.startCommitUpdater(update)
+	<-started
+	select {
+	case <-started:
+		t.Error("updater started twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -31,24 +31,33 @@ type (
 		query       *SqlserverQuery
 		config      SqlserverCDCConfig
 		filter      *utils.Filter
+		masker      *utils.Masker
+		rowFilter   *utils.RowFilter
+		methods     *utils.MethodFilter
+		commits     *commitTracker
 		meta        SqlserverCDCMeta
 		db          *sql.DB
 		changeTable atomic.Value
+		stop        chan struct{}
 	}
 
 	// SqlserverCDCConfig representation Sqlserver Query configuration
 	SqlserverCDCConfig struct {
-		Host         string                 `json:"host"`
-		Port         int                    `json:"port"`
-		User         string                 `json:"user"`
-		Password     string                 `json:"password"`
-		SslMode      string                 `json:"sslmode"`
-		Database     string                 `json:"database"`
-		PollInterval string                 `json:"poll_interval" mapstructure:"poll_interval"`
-		FilterPolicy string                 `json:"filter_policy" mapstructure:"filter_policy"`
-		Filter       map[string]interface{} `json:"filter"`
-		Enabled      bool                   `json:"enabled"`
-		Lsn          string                 `json:"lsn"`
+		Host           string                 `json:"host"`
+		Port           int                    `json:"port"`
+		User           string                 `json:"user"`
+		Password       string                 `json:"password"`
+		SslMode        string                 `json:"sslmode"`
+		Database       string                 `json:"database"`
+		PollInterval   string                 `json:"poll_interval" mapstructure:"poll_interval"`
+		FilterPolicy   string                 `json:"filter_policy" mapstructure:"filter_policy"`
+		Filter         map[string]interface{} `json:"filter"`
+		FilterPatterns []string               `json:"filter_patterns" mapstructure:"filter_patterns"`
+		Enabled        bool                   `json:"enabled"`
+		Lsn            string                 `json:"lsn"`
+		Mask           map[string]interface{} `json:"mask"`
+		RowFilter      map[string]interface{} `json:"row_filter" mapstructure:"row_filter"`
+		Methods        map[string]interface{} `json:"methods"`
 	}
 
 	//SqlserverCDCMeta representation of matadata
@@ -64,6 +73,22 @@ func NewSqlserverCDC(s *Source) (SourceI, error) {
 	if err != nil {
 		return nil, err
 	}
+	masker, err := utils.NewMasker(MSSqlCDCConfig.Mask)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := utils.NewFilter(MSSqlCDCConfig.FilterPolicy, MSSqlCDCConfig.Filter, MSSqlCDCConfig.FilterPatterns)
+	if err != nil {
+		return nil, err
+	}
+	rowFilter, err := utils.NewRowFilter(MSSqlCDCConfig.RowFilter)
+	if err != nil {
+		return nil, err
+	}
+	methods, err := utils.NewMethodFilter(MSSqlCDCConfig.Methods)
+	if err != nil {
+		return nil, err
+	}
 
 	query := &SqlserverQuery{
 		DBSQLQuery: &DBSQLQuery{
@@ -82,16 +107,19 @@ func NewSqlserverCDC(s *Source) (SourceI, error) {
 	}
 
 	m := &SqlserverCDC{
-		Source: s,
-		query:  query,
-		config: MSSqlCDCConfig,
-		filter: &utils.Filter{
-			FilterPolicy: MSSqlCDCConfig.FilterPolicy,
-			Filter:       MSSqlCDCConfig.Filter,
-		},
-		meta: SqlserverCDCMeta{},
+		Source:    s,
+		query:     query,
+		config:    MSSqlCDCConfig,
+		filter:    filter,
+		masker:    masker,
+		rowFilter: rowFilter,
+		methods:   methods,
+		meta:      SqlserverCDCMeta{},
 	}
 	m.meta.CurrentLsn = hex.EncodeToString(make([]byte, 10))
+	m.commits = newCommitTracker(func(offset string) {
+		m.config.Lsn = offset
+	})
 
 	return m, nil
 }
@@ -108,7 +136,14 @@ func (s *SqlserverCDC) Init() {
 }
 
 // Stop source
+// stop polling change tables
 func (s *SqlserverCDC) Stop() error {
+	if s.stop == nil {
+		return nil
+	}
+	close(s.stop)
+	s.stop = nil
+	s.Status = SourceStatusWaiting
 	return nil
 }
 
@@ -119,11 +154,16 @@ func (s *SqlserverCDC) Start(i ...interface{}) (err error) {
 		"type": SqlserverCDCType,
 	}).Debug("Start")
 
+	s.startCommitUpdater(s.UpdateCommittedLsn)
 	err = s.Source.Start(i)
 	if err != nil {
 		return
 	}
 
+	if checkpoint := s.LoadCheckpoint(); checkpoint != nil && s.config.Lsn == "" {
+		s.config.Lsn = checkpoint.Offset
+	}
+
 	s.Connect()
 
 	s.changeTable.Store(make([]string, 0))
@@ -133,11 +173,11 @@ func (s *SqlserverCDC) Start(i ...interface{}) (err error) {
 		s.meta.CurrentLsn = hex.EncodeToString(s.GetNextLsn(currentLsn))
 	}
 
-	go s.UpdateChangeTables()
+	s.stop = make(chan struct{})
 
-	go s.GetRecordedChances()
+	go s.UpdateChangeTables(s.stop)
 
-	go s.UpdateCommittedLsn()
+	go s.GetRecordedChances(s.stop)
 
 	return
 }
@@ -155,6 +195,14 @@ func (s *SqlserverCDC) GetMeta() map[string]utils.Meta {
 	return meta
 }
 
+// GetCheckpoint returns committed lsn to persist in offset store
+func (s *SqlserverCDC) GetCheckpoint() *Checkpoint {
+	return &Checkpoint{
+		Offset: s.config.Lsn,
+		Agent:  s.Offset,
+	}
+}
+
 // GetSchema get schema
 func (s *SqlserverCDC) GetSchema() map[string]map[string]*Column {
 	return s.query.GetSchema()
@@ -221,11 +269,18 @@ func (s *SqlserverCDC) Process(action string, params ...interface{}) interface{}
 	return nil
 }
 
-// GetRecordedChances get cdc change for table list
-func (s *SqlserverCDC) GetRecordedChances() {
+// GetRecordedChances get cdc change for table list until stop is closed
+func (s *SqlserverCDC) GetRecordedChances(stop chan struct{}) {
 	poolInterval, _ := time.ParseDuration(s.config.PollInterval)
 	ticker := time.NewTicker(poolInterval)
-	for range ticker.C {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
 		err := s.db.Ping()
 		if err != nil {
 			log.WithError(err).Error("connexion error")
@@ -244,6 +299,9 @@ func (s *SqlserverCDC) GetRecordedChances() {
 		for _, schemaTable := range s.changeTable.Load().([]string) {
 			res := strings.Split(schemaTable, "_")
 			schema, table := res[0], res[1]
+			if s.filter.IsExcludedTable(schema, table) {
+				continue
+			}
 
 			pk := s.query.GetPrimary(schema, table)
 
@@ -264,17 +322,31 @@ func (s *SqlserverCDC) GetRecordedChances() {
 		}
 
 		s.meta.CurrentLsn = hex.EncodeToString(maxLsn)
+		// rows up to max lsn are either sent or suppressed
+		s.commits.Skip(s.meta.CurrentLsn)
 	}
 }
 
 // ProcessRow send row to chan
 func (s *SqlserverCDC) ProcessRow(row map[string]interface{}, schema string, table string, pk string, method string) {
+	if s.methods.IsFilteredMethod(schema, table, method) {
+		return
+	}
 	event := make(map[string]interface{})
 	for k, v := range row {
 		if !strings.HasPrefix(k, "__$") {
 			event[k] = v
 		}
 	}
+	NormalizeValues(s.query.columns[schema][table], event)
+	// row filter may test excluded columns, they are removed once it is evaluated
+	if s.rowFilter.IsFilteredRow(schema, table, event, nil) {
+		return
+	}
+	s.filter.RemoveExcludedColumns(schema, table, event)
+	s.masker.MaskValues(schema, table, event)
+	offset := hex.EncodeToString(row["__$start_lsn"].([]byte))
+	s.commits.Sent(offset)
 	s.Offset++
 	s.OutputChannel <- events.LookatchEvent{
 		Header: events.LookatchHeader{
@@ -291,7 +363,7 @@ func (s *SqlserverCDC) ProcessRow(row map[string]interface{}, schema string, tab
 			Statement:   event,
 			PrimaryKey:  pk,
 			Offset: &events.Offset{
-				Source: hex.EncodeToString(row["__$start_lsn"].([]byte)),
+				Source: offset,
 				Agent:  strconv.FormatInt(s.Offset, 10),
 			},
 		},
@@ -323,18 +395,25 @@ func (s *SqlserverCDC) GetNextLsn(lsn []byte) []byte {
 	return result[0]["next_lsn"].([]byte)
 }
 
-// UpdateChangeTables update list of activated cdc tables
-func (s *SqlserverCDC) UpdateChangeTables() {
+// UpdateChangeTables update list of activated cdc tables until stop is closed
+func (s *SqlserverCDC) UpdateChangeTables(stop chan struct{}) {
 	poolInterval, _ := time.ParseDuration(s.config.PollInterval)
 	ticker := time.NewTicker(10 * poolInterval)
+	defer ticker.Stop()
 
-	for c := ticker.C; ; <-c {
+	for {
 		results := s.Query("SELECT * FROM cdc.change_tables")
 		changeTable := make([]string, 0)
 		for _, v := range results {
 			changeTable = append(changeTable, v["capture_instance"].(string))
 		}
 		s.changeTable.Store(changeTable)
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
@@ -398,6 +477,6 @@ func (s *SqlserverCDC) Query(query string) []map[string]interface{} {
 // UpdateCommittedLsn  update CommittedLsn
 func (s *SqlserverCDC) UpdateCommittedLsn() {
 	for committedLsn := range s.CommitChannel {
-		s.config.Lsn = committedLsn.(string)
+		s.commits.Commit(committedLsn.(string))
 	}
 }
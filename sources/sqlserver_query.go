@@ -34,7 +34,7 @@ type (
 
 // NewSqlserverSQLQuery create a Sqlserver Query source
 func NewSqlserverSQLQuery(s *Source) (SourceI, error) {
-	gdbcQuery := NewDBSQLQuery(s)
+	gdbcQuery := NewDBSQLQuery(s, SqlserverQueryType)
 
 	pgQueryConfig := SqlserverQueryConfig{}
 	err := s.Conf.UnmarshalKey("sources."+s.Name, &pgQueryConfig)
@@ -43,6 +43,9 @@ func NewSqlserverSQLQuery(s *Source) (SourceI, error) {
 	}
 
 	pgQueryConfig.DBSQLQueryConfig = &gdbcQuery.Config
+	if err = gdbcQuery.initFilters(); err != nil {
+		return nil, err
+	}
 
 	return &SqlserverQuery{
 		DBSQLQuery: &gdbcQuery,
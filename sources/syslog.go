@@ -10,6 +10,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	syslog "gopkg.in/mcuadros/go-syslog.v2"
+	"gopkg.in/mcuadros/go-syslog.v2/format"
 
 	"github.com/Pirionfr/lookatch-agent/events"
 )
@@ -25,6 +26,7 @@ type Syslog struct {
 	config     SyslogConfig
 	NbMessages int
 	metaMutex  sync.RWMutex
+	stop       chan struct{}
 }
 
 // SyslogConfig representation of Random Config
@@ -80,8 +82,16 @@ func (s *Syslog) Init() {
 }
 
 // Stop syslog source
+// server is killed and a new one is prepared for next start
 func (s *Syslog) Stop() error {
-	return nil
+	if s.stop == nil {
+		return nil
+	}
+	close(s.stop)
+	s.stop = nil
+	err := s.server.Kill()
+	s.Init()
+	return err
 }
 
 // Start syslog source
@@ -94,8 +104,15 @@ func (s *Syslog) Start(i ...interface{}) error {
 	if err != nil {
 		return err
 	}
-	go func() {
-		for logParts := range s.channel {
+	s.stop = make(chan struct{})
+	go func(channel syslog.LogPartsChannel, stop chan struct{}) {
+		for {
+			var logParts format.LogParts
+			select {
+			case <-stop:
+				return
+			case logParts = <-channel:
+			}
 			log.WithField("logParts", logParts).Debug("Run syslog")
 
 			s.OutputChannel <- events.LookatchEvent{
@@ -112,7 +129,7 @@ func (s *Syslog) Start(i ...interface{}) error {
 			s.NbMessages++
 			s.metaMutex.Unlock()
 		}
-	}()
+	}(s.channel, s.stop)
 
 	return nil
 }
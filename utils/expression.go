@@ -0,0 +1,415 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/juju/errors"
+)
+
+type (
+	// Expression boolean expression evaluated against the columns of a row
+	// it supports comparisons (==, !=, <, <=, >, >=) of columns, numbers, quoted strings, true, false and null
+	// combined with &&, || and !, and grouped with parentheses
+	Expression struct {
+		source string
+		root   node
+	}
+
+	// node of a parsed expression
+	node interface {
+		eval(values map[string]interface{}) interface{}
+	}
+
+	// literalNode constant value
+	literalNode struct {
+		value interface{}
+	}
+
+	// columnNode value of a column, null if the column is missing
+	columnNode struct {
+		name string
+	}
+
+	// notNode negation of an expression
+	notNode struct {
+		operand node
+	}
+
+	// binaryNode logical or comparison operator
+	binaryNode struct {
+		operator    string
+		left, right node
+	}
+
+	// token lexical unit of an expression
+	token struct {
+		kind  int
+		value string
+	}
+
+	// parser recursive descent parser of expressions
+	parser struct {
+		tokens []token
+		pos    int
+	}
+)
+
+// kinds of tokens
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+// ParseExpression parse expression
+func ParseExpression(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, errors.Annotatef(err, "invalid expression '%s'", source)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = errors.Errorf("unexpected '%s'", p.peek().value)
+	}
+	if err != nil {
+		return nil, errors.Annotatef(err, "invalid expression '%s'", source)
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+// String return source of expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Eval return true if values match expression
+func (e *Expression) Eval(values map[string]interface{}) bool {
+	return truthy(e.root.eval(values))
+}
+
+// tokenize split expression into tokens
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsLetter(c) || c == '_' || c == '`':
+			if c == '`' {
+				end := i + 1
+				for end < len(runes) && runes[end] != '`' {
+					end++
+				}
+				if end >= len(runes) {
+					return nil, errors.New("unterminated quoted column")
+				}
+				tokens = append(tokens, token{tokenIdent, string(runes[i+1 : end])})
+				i = end + 1
+				continue
+			}
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[start:i])})
+		case unicode.IsDigit(c) || c == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E') {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[start:i])})
+		case c == '\'' || c == '"':
+			var b strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != c; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, errors.New("unterminated string")
+			}
+			i++
+			tokens = append(tokens, token{tokenString, b.String()})
+		default:
+			operator := ""
+			for _, op := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "-"} {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					operator = op
+					break
+				}
+			}
+			if operator == "" {
+				return nil, errors.Errorf("unexpected character '%c'", c)
+			}
+			tokens = append(tokens, token{tokenOperator, operator})
+			i += len(operator)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+// peek return current token
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// accept consume current token if it is operator
+func (p *parser) accept(operator string) bool {
+	if t := p.peek(); t.kind == tokenOperator && t.value == operator {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseOr parse expressions joined with ||
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		var right node
+		right, err = p.parseAnd()
+		left = &binaryNode{operator: "||", left: left, right: right}
+	}
+	return left, err
+}
+
+// parseAnd parse expressions joined with &&
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	for err == nil && p.accept("&&") {
+		var right node
+		right, err = p.parseNot()
+		left = &binaryNode{operator: "&&", left: left, right: right}
+	}
+	return left, err
+}
+
+// parseNot parse negations
+func (p *parser) parseNot() (node, error) {
+	if p.accept("!") {
+		operand, err := p.parseNot()
+		return &notNode{operand: operand}, err
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parse comparison of two operands
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for _, operator := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(operator) {
+			right, err := p.parseOperand()
+			return &binaryNode{operator: operator, left: left, right: right}, err
+		}
+	}
+	return left, nil
+}
+
+// parseOperand parse literal, column or parenthesized expression
+func (p *parser) parseOperand() (node, error) {
+	t := p.peek()
+	p.pos++
+	switch t.kind {
+	case tokenNumber:
+		return parseNumber(t.value, false)
+	case tokenString:
+		return &literalNode{value: t.value}, nil
+	case tokenIdent:
+		switch strings.ToLower(t.value) {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		}
+		return &columnNode{name: t.value}, nil
+	case tokenOperator:
+		switch t.value {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.accept(")") {
+				return nil, errors.New("missing ')'")
+			}
+			return n, nil
+		case "-":
+			if next := p.peek(); next.kind == tokenNumber {
+				p.pos++
+				return parseNumber(next.value, true)
+			}
+		}
+		return nil, errors.Errorf("unexpected '%s'", t.value)
+	}
+	return nil, errors.New("unexpected end of expression")
+}
+
+// parseNumber parse number literal
+func parseNumber(value string, negative bool) (node, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, errors.Errorf("invalid number '%s'", value)
+	}
+	if negative {
+		f = -f
+	}
+	return &literalNode{value: f}, nil
+}
+
+// eval return literal value
+func (n *literalNode) eval(_ map[string]interface{}) interface{} {
+	return n.value
+}
+
+// eval return column value, columns are matched case insensitively if no exact match is found
+func (n *columnNode) eval(values map[string]interface{}) interface{} {
+	if value, ok := values[n.name]; ok {
+		return value
+	}
+	for column, value := range values {
+		if strings.EqualFold(column, n.name) {
+			return value
+		}
+	}
+	return nil
+}
+
+// eval return negation of operand
+func (n *notNode) eval(values map[string]interface{}) interface{} {
+	return !truthy(n.operand.eval(values))
+}
+
+// eval apply operator to operands
+func (n *binaryNode) eval(values map[string]interface{}) interface{} {
+	switch n.operator {
+	case "&&":
+		return truthy(n.left.eval(values)) && truthy(n.right.eval(values))
+	case "||":
+		return truthy(n.left.eval(values)) || truthy(n.right.eval(values))
+	}
+
+	left, right := n.left.eval(values), n.right.eval(values)
+	if left == nil || right == nil {
+		switch n.operator {
+		case "==":
+			return left == nil && right == nil
+		case "!=":
+			return (left == nil) != (right == nil)
+		}
+		return false
+	}
+
+	var cmp int
+	lf, lok := toFloat(left)
+	rf, rok := toFloat(right)
+	if lok && rok {
+		switch {
+		case lf < rf:
+			cmp = -1
+		case lf > rf:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(toString(left), toString(right))
+	}
+
+	switch n.operator {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// truthy return boolean value of an operand
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != "" && v != "0" && !strings.EqualFold(v, "false")
+	case []byte:
+		return truthy(string(v))
+	}
+	if f, ok := toFloat(value); ok {
+		return f != 0
+	}
+	return true
+}
+
+// toFloat convert numbers and numeric strings to float
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case bool:
+		return 0, false
+	case string, []byte:
+		f, err := strconv.ParseFloat(strings.TrimSpace(toString(v)), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toString convert value to string
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseExpressionInvalid(t *testing.T) {
+	for _, source := range []string{"", "a ==", "(a == 1", "a == 'b", "a = 1", "a == 1 b", "`a == 1"} {
+		if _, err := ParseExpression(source); err == nil {
+			t.Errorf("invalid expression accepted: %s", source)
+		}
+	}
+}
+
+func TestExpressionEval(t *testing.T) {
+	values := map[string]interface{}{
+		"tenant_id": int64(42),
+		"status":    []byte("published"),
+		"amount":    json.Number("10.5"),
+		"Deleted":   false,
+		"note":      nil,
+		"order id":  "7",
+	}
+	tests := map[string]bool{
+		"tenant_id == 42 && status != 'draft'":       true,
+		"tenant_id == 42 && status == 'draft'":       false,
+		"tenant_id != 42 || status == \"published\"": true,
+		"amount > 10 && amount <= 10.5":              true,
+		"amount < -1":                                false,
+		"!(tenant_id >= 43)":                         true,
+		"deleted":                                    false,
+		"!Deleted":                                   true,
+		"note == null":                               true,
+		"missing == null && note != 1":               true,
+		"note > 1":                                   false,
+		"`order id` == 7":                            true,
+		"status > 'draft'":                           true,
+		"tenant_id == 1 || tenant_id == 2 || (tenant_id == 42 && true)": true,
+	}
+	for source, expected := range tests {
+		expression, err := ParseExpression(source)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if expression.Eval(values) != expected {
+			t.Errorf("%s: expected %v", source, expected)
+		}
+	}
+}
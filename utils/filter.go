@@ -1,18 +1,105 @@
 package utils
 
 import (
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+	log "github.com/sirupsen/logrus"
 )
 
+// filterEscape escape of a letter in a regular expression, its meaning depends on the case of the letter
+var filterEscape = regexp.MustCompile(`\\[a-z]`)
+
 type (
 	// Filter Represents a filter
 	// (which will allow to specify the data the user want to replicate or not on the database, schema, table or column level.
+	// keys of the filter tree and columns may be glob patterns (orders_2024_*) or anchored regular expressions between slashes (/tenant_[0-9]+/)
+	// an exact key takes precedence over patterns, patterns are tried in lexical order and the first match is used
+	// names are compared case insensitively. As configuration keys are lower cased, regular expressions of keys holding
+	// escapes (\d, \D...) are read with their case from Patterns, which lists them without slashes
 	Filter struct {
 		FilterPolicy string                 `json:"filter_policy" mapstructure:"filter_policy"`
 		Filter       map[string]interface{} `json:"filter"`
+		Patterns     []string               `json:"filter_patterns" mapstructure:"filter_patterns"`
+		mutex        sync.RWMutex
+		patterns     map[string]*regexp.Regexp
+		matches      map[string]filterMatch
+	}
+
+	// filterMatch cached result of a lookup in the filter tree
+	filterMatch struct {
+		key   string
+		found bool
 	}
 )
 
+// NewFilter create filter of databases, tables and columns with policy
+// patterns are regular expressions of keys with their case, an error is returned if a key or column pattern is invalid
+// or a regular expression key holding escapes isn't listed in patterns
+func NewFilter(policy string, filter map[string]interface{}, patterns []string) (*Filter, error) {
+	f := &Filter{
+		FilterPolicy: policy,
+		Filter:       filter,
+		Patterns:     patterns,
+	}
+	for database, tables := range filter {
+		if err := f.checkKey(database); err != nil {
+			return nil, err
+		}
+		tableFilter, ok := tables.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for table, columns := range tableFilter {
+			if err := f.checkKey(table); err != nil {
+				return nil, err
+			}
+			columnFilter, ok := columns.([]interface{})
+			if !ok {
+				continue
+			}
+			for _, column := range columnFilter {
+				name, ok := column.(string)
+				if !ok {
+					continue
+				}
+				if _, err := compilePattern(name); err != nil {
+					return nil, errors.Annotatef(err, "invalid filter pattern '%s' of table '%s.%s'", name, database, table)
+				}
+			}
+		}
+	}
+	return f, nil
+}
+
+// checkKey check that pattern of filter key is valid
+// regular expressions holding escapes must be listed in patterns, as their case is lost in configuration keys
+func (f *Filter) checkKey(key string) error {
+	if isRegexKey(key) && filterEscape.MatchString(key) && f.keyExpr(key) == key {
+		return errors.Errorf("regular expression key '%s' of filter must be listed in filter_patterns, as configuration keys are lower cased", key)
+	}
+	if _, err := compilePattern(f.keyExpr(key)); err != nil {
+		return errors.Annotatef(err, "invalid filter pattern '%s'", key)
+	}
+	return nil
+}
+
+// keyExpr return regular expression key with the case it has in patterns, key itself if it isn't listed
+func (f *Filter) keyExpr(key string) string {
+	if !isRegexKey(key) {
+		return key
+	}
+	for _, pattern := range f.Patterns {
+		if strings.ToLower(pattern) == key[1:len(key)-1] {
+			return "/" + pattern + "/"
+		}
+	}
+	return key
+}
+
 // isAccept returns true if policy is accept
 func (f *Filter) isAccept() bool {
 	return f.FilterPolicy == "accept"
@@ -21,7 +108,7 @@ func (f *Filter) isAccept() bool {
 // IsFilteredDatabase check if database is filtered
 func (f *Filter) IsFilteredDatabase(database string) bool {
 	found := false
-	if tableFilter, ok := f.Filter[strings.ToLower(database)]; ok {
+	if tableFilter, ok := f.lookup(f.Filter, "", strings.ToLower(database)); ok {
 		if tableFilter == nil {
 			found = true
 		} else {
@@ -37,8 +124,9 @@ func (f *Filter) IsFilteredDatabase(database string) bool {
 // IsFilteredTable check if table is filtered
 func (f *Filter) IsFilteredTable(database string, table string) bool {
 	found := false
-	if tableFilter, ok := f.Filter[strings.ToLower(database)].(map[string]interface{}); ok {
-		if columnFilter, ok := tableFilter[strings.ToLower(table)]; ok {
+	database = strings.ToLower(database)
+	if tableFilter, ok := f.get(f.Filter, "", database).(map[string]interface{}); ok {
+		if columnFilter, ok := f.lookup(tableFilter, database, strings.ToLower(table)); ok {
 			if columnFilter == nil {
 				found = true
 			} else {
@@ -55,10 +143,11 @@ func (f *Filter) IsFilteredTable(database string, table string) bool {
 // IsFilteredColumn check if column is filtered
 func (f *Filter) IsFilteredColumn(database string, table string, column string) bool {
 	found := false
-	if tableFilter, ok := f.Filter[strings.ToLower(database)].(map[string]interface{}); ok {
-		columnFilter := tableFilter[strings.ToLower(table)]
+	database, table = strings.ToLower(database), strings.ToLower(table)
+	if tableFilter, ok := f.get(f.Filter, "", database).(map[string]interface{}); ok {
+		columnFilter := f.get(tableFilter, database, table)
 		if columnFilter != nil {
-			found = contains(columnFilter.([]interface{}), column)
+			found = f.contains(columnFilter.([]interface{}), database+"\x00"+table, column)
 		}
 	}
 	if found {
@@ -67,12 +156,243 @@ func (f *Filter) IsFilteredColumn(database string, table string, column string)
 	return !f.isAccept()
 }
 
-// contains check if e is contain in s
-func contains(s []interface{}, e string) bool {
-	for _, a := range s {
-		if a == e {
+// isEmpty returns true if no filter is configured, nothing is then excluded
+func (f *Filter) isEmpty() bool {
+	return f == nil || f.FilterPolicy == "" && len(f.Filter) == 0
+}
+
+// IsExcludedTable check if rows of table are not replicated, from database and table levels of the filter
+// a table whose columns are listed is replicated, its columns are checked with IsExcludedColumn
+func (f *Filter) IsExcludedTable(database string, table string) bool {
+	if f.isEmpty() {
+		return false
+	}
+	_, listed, partial := f.walk(database, table)
+	if !listed {
+		return !f.isAccept()
+	}
+	if !partial {
+		return f.isAccept()
+	}
+	return false
+}
+
+// IsExcludedColumn check if column is not replicated, from database, table and column levels of the filter
+func (f *Filter) IsExcludedColumn(database string, table string, column string) bool {
+	if f.isEmpty() {
+		return false
+	}
+	columns, listed, partial := f.walk(database, table)
+	if !listed {
+		return !f.isAccept()
+	}
+	if !partial {
+		return f.isAccept()
+	}
+	if f.contains(columns, strings.ToLower(database)+"\x00"+strings.ToLower(table), column) {
+		return f.isAccept()
+	}
+	return !f.isAccept()
+}
+
+// RemoveExcludedColumns delete columns not replicated from rows of table
+func (f *Filter) RemoveExcludedColumns(database string, table string, rows ...map[string]interface{}) {
+	if f.isEmpty() {
+		return
+	}
+	for _, row := range rows {
+		for column := range row {
+			if f.IsExcludedColumn(database, table, column) {
+				delete(row, column)
+			}
+		}
+	}
+}
+
+// walk look for table in filter tree
+// listed is true if database or table is listed, partial is false if a whole database or table is listed
+// columns are listed columns of table, if any
+func (f *Filter) walk(database string, table string) (columns []interface{}, listed bool, partial bool) {
+	database, table = strings.ToLower(database), strings.ToLower(table)
+	tables, listed := f.lookup(f.Filter, "", database)
+	if !listed {
+		return nil, false, false
+	}
+	tableFilter, ok := tables.(map[string]interface{})
+	if !ok {
+		return nil, true, false
+	}
+	columnFilter, listed := f.lookup(tableFilter, database, table)
+	if !listed {
+		return nil, false, false
+	}
+	columns, ok = columnFilter.([]interface{})
+	return columns, true, ok
+}
+
+// get return value of key matching name in filters, nil if none matches
+func (f *Filter) get(filters map[string]interface{}, path string, name string) interface{} {
+	value, _ := f.lookup(filters, path, name)
+	return value
+}
+
+// lookup return value of key matching name in filters
+// path identify filters in the filter tree so the matching key can be cached
+func (f *Filter) lookup(filters map[string]interface{}, path string, name string) (interface{}, bool) {
+	if value, ok := filters[name]; ok {
+		return value, true
+	}
+	if len(filters) == 0 {
+		return nil, false
+	}
+
+	cacheKey := path + "\x00" + name
+	f.mutex.RLock()
+	match, cached := f.matches[cacheKey]
+	f.mutex.RUnlock()
+	if !cached {
+		keys := make([]string, 0, len(filters))
+		for key := range filters {
+			keys = append(keys, key)
+		}
+		match = f.match(keys, name, true)
+		f.cacheMatch(cacheKey, match)
+	}
+	if !match.found {
+		return nil, false
+	}
+	return filters[match.key], true
+}
+
+// contains check if column matches one of columns, case insensitively like patterns
+func (f *Filter) contains(columns []interface{}, path string, column string) bool {
+	for _, c := range columns {
+		if name, ok := c.(string); ok && strings.EqualFold(name, column) {
 			return true
 		}
 	}
-	return false
+
+	cacheKey := path + "\x00\x00" + column
+	f.mutex.RLock()
+	match, cached := f.matches[cacheKey]
+	f.mutex.RUnlock()
+	if !cached {
+		keys := make([]string, 0, len(columns))
+		for _, c := range columns {
+			if key, ok := c.(string); ok {
+				keys = append(keys, key)
+			}
+		}
+		match = f.match(keys, column, false)
+		f.cacheMatch(cacheKey, match)
+	}
+	return match.found
+}
+
+// match return first pattern of keys in lexical order matching name
+// mapKeys is true if keys are keys of the filter tree, false if they are columns
+func (f *Filter) match(keys []string, name string, mapKeys bool) filterMatch {
+	sort.Strings(keys)
+	for _, key := range keys {
+		if re := f.pattern(key, mapKeys); re != nil && re.MatchString(name) {
+			return filterMatch{key: key, found: true}
+		}
+	}
+	return filterMatch{}
+}
+
+// cacheMatch store result of a lookup
+func (f *Filter) cacheMatch(cacheKey string, match filterMatch) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.matches == nil {
+		f.matches = make(map[string]filterMatch)
+	}
+	f.matches[cacheKey] = match
+}
+
+// pattern return compiled pattern of key, nil if key is not a pattern
+// regular expressions of keys of the filter tree are read from patterns with their case
+func (f *Filter) pattern(key string, mapKey bool) *regexp.Regexp {
+	cacheKey := key
+	if mapKey {
+		key = f.keyExpr(key)
+		cacheKey = "\x00" + key
+	}
+	f.mutex.RLock()
+	re, ok := f.patterns[cacheKey]
+	f.mutex.RUnlock()
+	if ok {
+		return re
+	}
+
+	re, err := compilePattern(key)
+	if err != nil {
+		log.WithError(err).WithField("pattern", key).Warn("Invalid filter pattern")
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.patterns == nil {
+		f.patterns = make(map[string]*regexp.Regexp)
+	}
+	f.patterns[cacheKey] = re
+	return re
+}
+
+// compilePattern compile glob or regex key, nil if key is an exact name
+func compilePattern(key string) (*regexp.Regexp, error) {
+	expr, isPattern := patternExpr(key)
+	if !isPattern {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+// isRegexKey return true if key is a regular expression between slashes
+func isRegexKey(key string) bool {
+	return len(key) > 2 && strings.HasPrefix(key, "/") && strings.HasSuffix(key, "/")
+}
+
+// patternExpr return anchored case insensitive regular expression of a glob or regex key
+// return false if key is an exact name
+func patternExpr(key string) (string, bool) {
+	if isRegexKey(key) {
+		return "(?i)^(?:" + key[1:len(key)-1] + ")$", true
+	}
+	if !strings.ContainsAny(key, "*?[") {
+		return "", false
+	}
+
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	runes := []rune(key)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end >= len(runes) {
+				b.WriteString(regexp.QuoteMeta(string(runes[i:])))
+				i = len(runes)
+				continue
+			}
+			class := string(runes[i+1 : end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String(), true
 }
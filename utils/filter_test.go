@@ -483,3 +483,183 @@ func TestColumnAccept5(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestFilterPatterns(t *testing.T) {
+	filters := make(map[string]interface{})
+	aViper := viper.New()
+	aViper.SetConfigType("JSON")
+	aViper.ReadConfig(bytes.NewBuffer([]byte(`{"filter" :{
+		"orders_2024_*": null,
+		"/tenant_[0-9]+/": {"user?": null, "invoice": ["id", "amount_*"]},
+		"tenant_1": {"customer": null}
+	}}`)))
+	aViper.UnmarshalKey("filter", &filters)
+	filter := &Filter{
+		FilterPolicy: "drop",
+		Filter:       filters,
+	}
+
+	if filter.IsFilteredDatabase("orders_2024_01") || !filter.IsFilteredDatabase("orders_2023_01") {
+		t.Error("glob database not matched")
+	}
+	if filter.IsFilteredTable("tenant_12", "users") || !filter.IsFilteredTable("tenant_x", "users") {
+		t.Error("regex database not matched")
+	}
+	if !filter.IsFilteredTable("tenant_12", "user") {
+		t.Error("glob table matched a shorter name")
+	}
+	if filter.IsFilteredTable("tenant_1", "customer") || !filter.IsFilteredTable("tenant_1", "users") {
+		t.Error("exact database must take precedence over patterns")
+	}
+	if filter.IsFilteredColumn("Tenant_7", "invoice", "amount_ht") || filter.IsFilteredColumn("tenant_7", "invoice", "id") {
+		t.Error("column patterns not matched")
+	}
+	if !filter.IsFilteredColumn("tenant_7", "invoice", "label") {
+		t.Error("column not listed must be filtered")
+	}
+	// cached results
+	if filter.IsFilteredDatabase("orders_2024_01") || filter.IsFilteredColumn("tenant_7", "invoice", "amount_ht") {
+		t.Error("cached match differs")
+	}
+}
+
+func TestPatternExpr(t *testing.T) {
+	tests := map[string]string{
+		"orders":      "",
+		"orders_*":    "(?i)^orders_.*$",
+		"a?c":         "(?i)^a.c$",
+		"t[!0-9]":     "(?i)^t[^0-9]$",
+		"a.b*":        `(?i)^a\.b.*$`,
+		"/tenant_.+/": "(?i)^(?:tenant_.+)$",
+	}
+	for key, expected := range tests {
+		expr, isPattern := patternExpr(key)
+		if isPattern != (expected != "") || expr != expected {
+			t.Errorf("%s: got %s", key, expr)
+		}
+	}
+}
+
+func TestIsExcludedEmpty(t *testing.T) {
+	filter := &Filter{}
+	if filter.IsExcludedTable("test", "employee") || filter.IsExcludedColumn("test", "employee", "emp_id") {
+		t.Fail()
+	}
+	var nilFilter *Filter
+	if nilFilter.IsExcludedTable("test", "employee") || nilFilter.IsExcludedColumn("test", "employee", "emp_id") {
+		t.Fail()
+	}
+}
+
+func TestIsExcluded(t *testing.T) {
+	filters := make(map[string]interface{})
+	aViper := viper.New()
+	aViper.SetConfigType("JSON")
+	aViper.ReadConfig(bytes.NewBuffer([]byte(`{"filter" :{"whole":null,"test":{"all":null,"EMPLOYEE":["EMP_ID"]}}}`)))
+	aViper.UnmarshalKey("filter", &filters)
+
+	tests := []struct {
+		policy        string
+		database      string
+		table         string
+		column        string
+		excludedTable bool
+		excludedCol   bool
+	}{
+		{"drop", "whole", "any", "any", false, false},
+		{"drop", "test", "all", "any", false, false},
+		{"drop", "test", "employee", "EMP_ID", false, false},
+		{"drop", "test", "employee", "NAME", false, true},
+		{"drop", "test", "other", "any", true, true},
+		{"drop", "other", "any", "any", true, true},
+		{"accept", "whole", "any", "any", true, true},
+		{"accept", "test", "all", "any", true, true},
+		{"accept", "test", "employee", "EMP_ID", false, true},
+		{"accept", "test", "employee", "NAME", false, false},
+		{"accept", "test", "other", "any", false, false},
+		{"accept", "other", "any", "any", false, false},
+	}
+	for _, test := range tests {
+		filter := &Filter{
+			FilterPolicy: test.policy,
+			Filter:       filters,
+		}
+		if filter.IsExcludedTable(test.database, test.table) != test.excludedTable {
+			t.Errorf("%s table %s.%s: expected excluded %t", test.policy, test.database, test.table, test.excludedTable)
+		}
+		if filter.IsExcludedColumn(test.database, test.table, test.column) != test.excludedCol {
+			t.Errorf("%s column %s.%s.%s: expected excluded %t", test.policy, test.database, test.table, test.column, test.excludedCol)
+		}
+	}
+}
+
+func TestRemoveExcludedColumns(t *testing.T) {
+	filter := &Filter{
+		FilterPolicy: "accept",
+		Filter: map[string]interface{}{
+			"db": map[string]interface{}{"table": []interface{}{"secret"}},
+		},
+	}
+	row := map[string]interface{}{"id": 1, "secret": "new"}
+	oldRow := map[string]interface{}{"id": 1, "secret": "old"}
+	filter.RemoveExcludedColumns("db", "table", row, oldRow, nil)
+	if _, ok := row["secret"]; ok || row["id"] != 1 {
+		t.Error(row)
+	}
+	if _, ok := oldRow["secret"]; ok || oldRow["id"] != 1 {
+		t.Error(oldRow)
+	}
+}
+
+func TestNewFilterRegexKeyCase(t *testing.T) {
+	aViper := viper.New()
+	aViper.SetConfigType("JSON")
+	aViper.ReadConfig(bytes.NewBuffer([]byte(`{
+		"filter_policy": "drop",
+		"filter": {"/tenant_\\D+/": null},
+		"filter_patterns": ["tenant_\\D+"]
+	}`)))
+	filter, err := NewFilter(aViper.GetString("filter_policy"), aViper.GetStringMap("filter"), aViper.GetStringSlice("filter_patterns"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// key is lower cased by configuration, pattern keeps its case
+	if _, ok := filter.Filter[`/tenant_\d+/`]; !ok {
+		t.Fatal(filter.Filter)
+	}
+	if filter.IsFilteredDatabase("tenant_abc") || !filter.IsFilteredDatabase("tenant_12") {
+		t.Error("pattern read without its case")
+	}
+
+	// case of escapes is lost without pattern
+	_, err = NewFilter("drop", aViper.GetStringMap("filter"), nil)
+	if err == nil {
+		t.Error("regular expression key with escapes not listed")
+	}
+	_, err = NewFilter("drop", map[string]interface{}{"/tenant_[0-9]+/": nil}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = NewFilter("drop", map[string]interface{}{"shop": map[string]interface{}{"orders": []interface{}{"/a(/"}}}, nil)
+	if err == nil {
+		t.Error("invalid column pattern")
+	}
+}
+
+func TestFilterColumnCase(t *testing.T) {
+	filter, err := NewFilter("drop", map[string]interface{}{
+		"shop": map[string]interface{}{"orders": []interface{}{"Amount", "note_*", `/\D+_ID/`}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// exact columns and patterns are both matched case insensitively
+	for _, column := range []string{"amount", "AMOUNT", "Note_1", "customer_id"} {
+		if filter.IsFilteredColumn("shop", "orders", column) {
+			t.Error(column)
+		}
+	}
+	if !filter.IsFilteredColumn("shop", "orders", "1_id") {
+		t.Error("column pattern keeps its case")
+	}
+}
@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/juju/errors"
+	"github.com/mitchellh/mapstructure"
+)
+
+// Masking actions
+const (
+	MaskRedact   = "redact"
+	MaskTruncate = "truncate"
+	MaskHash     = "hash"
+	MaskPartial  = "partial"
+)
+
+// Default values of masking rules
+const (
+	DefaultMaskReplacement = "REDACTED"
+	DefaultMaskChar        = "*"
+)
+
+type (
+	// MaskRule representation of the masking action of a column
+	MaskRule struct {
+		Action      string `json:"action" mapstructure:"action"`
+		Replacement string `json:"replacement" mapstructure:"replacement"`
+		Length      int    `json:"length" mapstructure:"length"`
+		Key         string `json:"key" mapstructure:"key"`
+		KeepStart   int    `json:"keep_start" mapstructure:"keep_start"`
+		KeepEnd     int    `json:"keep_end" mapstructure:"keep_end"`
+		MaskChar    string `json:"mask_char" mapstructure:"mask_char"`
+	}
+
+	// Masker mask values of columns
+	// rules are configured with a database, table, column tree like filters
+	Masker struct {
+		rules map[string]map[string]map[string]*MaskRule
+	}
+)
+
+// NewMasker create masker from database, table, column tree of rules
+// a rule is either an action name or a MaskRule
+func NewMasker(conf map[string]interface{}) (*Masker, error) {
+	m := &Masker{
+		rules: make(map[string]map[string]map[string]*MaskRule),
+	}
+	for database, tables := range conf {
+		tablesConf, ok := tables.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("invalid mask configuration for database '%s'", database)
+		}
+		m.rules[strings.ToLower(database)] = make(map[string]map[string]*MaskRule)
+		for table, columns := range tablesConf {
+			columnsConf, ok := columns.(map[string]interface{})
+			if !ok {
+				return nil, errors.Errorf("invalid mask configuration for table '%s.%s'", database, table)
+			}
+			rules := make(map[string]*MaskRule)
+			for column, ruleConf := range columnsConf {
+				rule, err := newMaskRule(ruleConf)
+				if err != nil {
+					return nil, errors.Annotatef(err, "invalid mask rule for column '%s.%s.%s'", database, table, column)
+				}
+				rules[strings.ToLower(column)] = rule
+			}
+			m.rules[strings.ToLower(database)][strings.ToLower(table)] = rules
+		}
+	}
+	return m, nil
+}
+
+// newMaskRule decode and check rule
+func newMaskRule(conf interface{}) (*MaskRule, error) {
+	rule := &MaskRule{}
+	if action, ok := conf.(string); ok {
+		rule.Action = action
+	} else if err := mapstructure.WeakDecode(conf, rule); err != nil {
+		return nil, err
+	}
+
+	switch rule.Action {
+	case MaskRedact:
+		if rule.Replacement == "" {
+			rule.Replacement = DefaultMaskReplacement
+		}
+	case MaskTruncate:
+		if rule.Length < 0 {
+			return nil, errors.New("length must be positive")
+		}
+	case MaskHash:
+		if rule.Key == "" {
+			return nil, errors.New("hash requires a key")
+		}
+	case MaskPartial:
+		if rule.KeepStart < 0 || rule.KeepEnd < 0 {
+			return nil, errors.New("keep_start and keep_end must be positive")
+		}
+		if rule.MaskChar == "" {
+			rule.MaskChar = DefaultMaskChar
+		}
+	default:
+		return nil, errors.Errorf("unknown mask action '%s'", rule.Action)
+	}
+	return rule, nil
+}
+
+// HasRules return true if columns of table are masked
+func (m *Masker) HasRules(database string, table string) bool {
+	if m == nil {
+		return false
+	}
+	return len(m.rules[strings.ToLower(database)][strings.ToLower(table)]) > 0
+}
+
+// MaskValues replace values of masked columns of table
+// names are case insensitive as configuration keys are lower cased
+func (m *Masker) MaskValues(database string, table string, values map[string]interface{}) {
+	if !m.HasRules(database, table) {
+		return
+	}
+	rules := m.rules[strings.ToLower(database)][strings.ToLower(table)]
+	for column, value := range values {
+		if rule, ok := rules[strings.ToLower(column)]; ok {
+			values[column] = rule.Mask(value)
+		}
+	}
+}
+
+// Mask return masked value, null values are kept
+func (r *MaskRule) Mask(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	switch r.Action {
+	case MaskRedact:
+		return r.Replacement
+	case MaskTruncate:
+		runes := []rune(s)
+		if len(runes) > r.Length {
+			return string(runes[:r.Length])
+		}
+		return s
+	case MaskHash:
+		mac := hmac.New(sha256.New, []byte(r.Key))
+		mac.Write([]byte(s))
+		return hex.EncodeToString(mac.Sum(nil))
+	case MaskPartial:
+		return r.partial(s)
+	}
+	return value
+}
+
+// partial replace letters and digits by mask char except the first KeepStart and last KeepEnd ones
+// other characters are kept so value keeps its format
+func (r *MaskRule) partial(s string) string {
+	runes := []rune(s)
+	total := 0
+	for _, c := range runes {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			total++
+		}
+	}
+
+	var b strings.Builder
+	position := 0
+	for _, c := range runes {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			b.WriteRune(c)
+			continue
+		}
+		if position < r.KeepStart || position >= total-r.KeepEnd {
+			b.WriteRune(c)
+		} else {
+			b.WriteString(r.MaskChar)
+		}
+		position++
+	}
+	return b.String()
+}
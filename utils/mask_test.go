@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const maskConfig = `{"mask": {"Shop": {"Customer": {
+	"Email": {"action": "partial", "keep_start": 1},
+	"phone": {"action": "partial", "keep_end": 2, "mask_char": "#"},
+	"password": "redact",
+	"comment": {"action": "truncate", "length": 3},
+	"ssn": {"action": "hash", "key": "secret"}
+}}}}`
+
+func newTestMasker(t *testing.T) *Masker {
+	v := viper.New()
+	v.SetConfigType("json")
+	if err := v.ReadConfig(bytes.NewBufferString(maskConfig)); err != nil {
+		t.Fatal(err)
+	}
+	masker, err := NewMasker(v.GetStringMap("mask"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return masker
+}
+
+func TestMaskValues(t *testing.T) {
+	masker := newTestMasker(t)
+	values := map[string]interface{}{
+		"Email":    "john.doe@example.com",
+		"phone":    []byte("+33 6 12"),
+		"password": "secret",
+		"comment":  "été comme hiver",
+		"ssn":      int64(123),
+		"id":       int64(1),
+		"other":    nil,
+	}
+	masker.MaskValues("shop", "customer", values)
+
+	if values["Email"] != "j***.***@*******.***" {
+		t.Error(values["Email"])
+	}
+	if values["phone"] != "+## # 12" {
+		t.Error(values["phone"])
+	}
+	if values["password"] != DefaultMaskReplacement {
+		t.Error(values["password"])
+	}
+	if values["comment"] != "été" {
+		t.Error(values["comment"])
+	}
+	if values["ssn"] != (&MaskRule{Action: MaskHash, Key: "secret"}).Mask("123") || len(values["ssn"].(string)) != 64 {
+		t.Error(values["ssn"])
+	}
+	if values["id"] != int64(1) || values["other"] != nil {
+		t.Fail()
+	}
+}
+
+func TestMaskHashKeyed(t *testing.T) {
+	rule := &MaskRule{Action: MaskHash, Key: "a"}
+	other := &MaskRule{Action: MaskHash, Key: "b"}
+	if rule.Mask("value") != rule.Mask([]byte("value")) {
+		t.Error("hash must be stable")
+	}
+	if rule.Mask("value") == other.Mask("value") {
+		t.Error("hash must depend on key")
+	}
+}
+
+func TestMaskOtherTable(t *testing.T) {
+	masker := newTestMasker(t)
+	values := map[string]interface{}{"password": "secret"}
+	masker.MaskValues("shop", "order", values)
+	if values["password"] != "secret" {
+		t.Fail()
+	}
+
+	var nilMasker *Masker
+	nilMasker.MaskValues("shop", "customer", values)
+	if values["password"] != "secret" {
+		t.Fail()
+	}
+}
+
+func TestNewMaskerInvalid(t *testing.T) {
+	invalid := []map[string]interface{}{
+		{"shop": "customer"},
+		{"shop": map[string]interface{}{"customer": []interface{}{"email"}}},
+		{"shop": map[string]interface{}{"customer": map[string]interface{}{"email": "unknown"}}},
+		{"shop": map[string]interface{}{"customer": map[string]interface{}{"email": map[string]interface{}{"action": "hash"}}}},
+	}
+	for _, conf := range invalid {
+		if _, err := NewMasker(conf); err == nil {
+			t.Errorf("invalid configuration accepted: %v", conf)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// Methods of events which can be allowed for a table
+const (
+	MethodInsert = "insert"
+	MethodUpdate = "update"
+	MethodDelete = "delete"
+	MethodQuery  = "query"
+)
+
+// MethodFilter filter events whose method is not allowed for their table
+// allowed methods are configured with a database, table tree like filters
+type MethodFilter struct {
+	methods map[string]map[string]map[string]bool
+}
+
+// NewMethodFilter create method filter from database, table tree of allowed methods
+// allowed methods are either a list of methods or a single method
+func NewMethodFilter(conf map[string]interface{}) (*MethodFilter, error) {
+	f := &MethodFilter{
+		methods: make(map[string]map[string]map[string]bool),
+	}
+	for database, tables := range conf {
+		tablesConf, ok := tables.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("invalid methods configuration for database '%s'", database)
+		}
+		f.methods[strings.ToLower(database)] = make(map[string]map[string]bool)
+		for table, methodsConf := range tablesConf {
+			methods, err := newMethods(methodsConf)
+			if err != nil {
+				return nil, errors.Annotatef(err, "invalid methods for table '%s.%s'", database, table)
+			}
+			f.methods[strings.ToLower(database)][strings.ToLower(table)] = methods
+		}
+	}
+	return f, nil
+}
+
+// newMethods decode allowed methods of a table
+func newMethods(conf interface{}) (map[string]bool, error) {
+	var list []interface{}
+	switch value := conf.(type) {
+	case string:
+		list = []interface{}{value}
+	case []interface{}:
+		list = value
+	case []string:
+		for _, method := range value {
+			list = append(list, method)
+		}
+	default:
+		return nil, errors.Errorf("methods must be a list, got %T", conf)
+	}
+
+	methods := make(map[string]bool, len(list))
+	for _, item := range list {
+		method, ok := item.(string)
+		if !ok {
+			return nil, errors.Errorf("method must be a string, got %T", item)
+		}
+		method = strings.ToLower(method)
+		switch method {
+		case MethodInsert, MethodUpdate, MethodDelete, MethodQuery:
+			methods[method] = true
+		default:
+			return nil, errors.Errorf("unknown method '%s'", method)
+		}
+	}
+	return methods, nil
+}
+
+// IsFilteredMethod check if method is not allowed for table
+// tables without allowed methods accept all of them
+func (f *MethodFilter) IsFilteredMethod(database string, table string, method string) bool {
+	if f == nil {
+		return false
+	}
+	methods, ok := f.methods[strings.ToLower(database)][strings.ToLower(table)]
+	if !ok {
+		return false
+	}
+	return !methods[strings.ToLower(method)]
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestMethodFilter(t *testing.T) {
+	conf := make(map[string]interface{})
+	aViper := viper.New()
+	aViper.SetConfigType("JSON")
+	aViper.ReadConfig(bytes.NewBuffer([]byte(`{"methods":{"Shop":{"Orders":["insert"],"customers":"update","audit":["INSERT","update"]}}}`)))
+	aViper.UnmarshalKey("methods", &conf)
+
+	filter, err := NewMethodFilter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		database string
+		table    string
+		method   string
+		filtered bool
+	}{
+		{"shop", "orders", "insert", false},
+		{"SHOP", "ORDERS", "update", true},
+		{"shop", "orders", "delete", true},
+		{"shop", "customers", "update", false},
+		{"shop", "customers", "insert", true},
+		{"shop", "audit", "update", false},
+		{"shop", "audit", "delete", true},
+		{"shop", "other", "delete", false},
+		{"other", "orders", "delete", false},
+	}
+	for _, test := range tests {
+		if filter.IsFilteredMethod(test.database, test.table, test.method) != test.filtered {
+			t.Errorf("%s %s.%s: expected filtered %t", test.method, test.database, test.table, test.filtered)
+		}
+	}
+}
+
+func TestMethodFilterNil(t *testing.T) {
+	var filter *MethodFilter
+	if filter.IsFilteredMethod("shop", "orders", "delete") {
+		t.Fail()
+	}
+}
+
+func TestMethodFilterInvalid(t *testing.T) {
+	confs := []map[string]interface{}{
+		{"shop": "orders"},
+		{"shop": map[string]interface{}{"orders": 1}},
+		{"shop": map[string]interface{}{"orders": []interface{}{"upsert"}}},
+		{"shop": map[string]interface{}{"orders": []interface{}{1}}},
+	}
+	for _, conf := range confs {
+		if _, err := NewMethodFilter(conf); err == nil {
+			t.Errorf("error expected for %v", conf)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/mitchellh/mapstructure"
+)
+
+// Images of a row a predicate is evaluated against
+const (
+	ImageNew    = "new"
+	ImageOld    = "old"
+	ImageEither = "either"
+)
+
+type (
+	// RowPredicate representation of the expression rows of a table must match
+	// Image is the image of updated rows evaluated, new by default
+	RowPredicate struct {
+		Expression string `json:"expression" mapstructure:"expression"`
+		Image      string `json:"image" mapstructure:"image"`
+		expression *Expression
+	}
+
+	// RowFilter filter rows not matching predicate of their table
+	// predicates are configured with a database, table tree like filters
+	RowFilter struct {
+		predicates map[string]map[string]*RowPredicate
+	}
+)
+
+// NewRowFilter create row filter from database, table tree of predicates
+// a predicate is either an expression or a RowPredicate
+func NewRowFilter(conf map[string]interface{}) (*RowFilter, error) {
+	f := &RowFilter{
+		predicates: make(map[string]map[string]*RowPredicate),
+	}
+	for database, tables := range conf {
+		tablesConf, ok := tables.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("invalid row filter configuration for database '%s'", database)
+		}
+		f.predicates[strings.ToLower(database)] = make(map[string]*RowPredicate)
+		for table, predicateConf := range tablesConf {
+			predicate, err := newRowPredicate(predicateConf)
+			if err != nil {
+				return nil, errors.Annotatef(err, "invalid row filter for table '%s.%s'", database, table)
+			}
+			f.predicates[strings.ToLower(database)][strings.ToLower(table)] = predicate
+		}
+	}
+	return f, nil
+}
+
+// newRowPredicate decode predicate and parse its expression
+func newRowPredicate(conf interface{}) (*RowPredicate, error) {
+	predicate := &RowPredicate{}
+	if expression, ok := conf.(string); ok {
+		predicate.Expression = expression
+	} else if err := mapstructure.Decode(conf, predicate); err != nil {
+		return nil, err
+	}
+
+	switch predicate.Image {
+	case "":
+		predicate.Image = ImageNew
+	case ImageNew, ImageOld, ImageEither:
+	default:
+		return nil, errors.Errorf("unknown image '%s'", predicate.Image)
+	}
+
+	expression, err := ParseExpression(predicate.Expression)
+	if err != nil {
+		return nil, err
+	}
+	predicate.expression = expression
+	return predicate, nil
+}
+
+// IsFilteredRow check if row does not match predicate of its table
+// when the image to evaluate is missing, as for inserts and deletes, the other image is evaluated
+func (f *RowFilter) IsFilteredRow(database string, table string, statement map[string]interface{}, oldStatement map[string]interface{}) bool {
+	if f == nil {
+		return false
+	}
+	predicate, ok := f.predicates[strings.ToLower(database)][strings.ToLower(table)]
+	if !ok {
+		return false
+	}
+	return !predicate.Match(statement, oldStatement)
+}
+
+// Match return true if row matches predicate
+func (p *RowPredicate) Match(statement map[string]interface{}, oldStatement map[string]interface{}) bool {
+	if len(statement) == 0 {
+		statement = oldStatement
+	}
+	if len(oldStatement) == 0 {
+		oldStatement = statement
+	}
+	switch p.Image {
+	case ImageOld:
+		return p.expression.Eval(oldStatement)
+	case ImageEither:
+		return p.expression.Eval(statement) || p.expression.Eval(oldStatement)
+	}
+	return p.expression.Eval(statement)
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestNewRowFilterInvalid(t *testing.T) {
+	invalid := []map[string]interface{}{
+		{"shop": "orders"},
+		{"shop": map[string]interface{}{"orders": "status =="}},
+		{"shop": map[string]interface{}{"orders": map[string]interface{}{"expression": "id > 1", "image": "both"}}},
+	}
+	for _, conf := range invalid {
+		if _, err := NewRowFilter(conf); err == nil {
+			t.Errorf("invalid configuration accepted: %v", conf)
+		}
+	}
+}
+
+func TestIsFilteredRow(t *testing.T) {
+	filter, err := NewRowFilter(map[string]interface{}{
+		"Shop": map[string]interface{}{
+			"orders":  "status != 'draft'",
+			"old":     map[string]interface{}{"expression": "status != 'draft'", "image": ImageOld},
+			"either":  map[string]interface{}{"expression": "status != 'draft'", "image": ImageEither},
+			"invoice": map[string]interface{}{"expression": "tenant_id == 42"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	draft := map[string]interface{}{"status": "draft"}
+	published := map[string]interface{}{"status": "published"}
+
+	if filter.IsFilteredRow("shop", "orders", published, nil) || !filter.IsFilteredRow("SHOP", "Orders", draft, nil) {
+		t.Error("new image not evaluated")
+	}
+	if filter.IsFilteredRow("shop", "orders", nil, published) {
+		t.Error("old image of deleted row not evaluated")
+	}
+	if !filter.IsFilteredRow("shop", "old", published, draft) || filter.IsFilteredRow("shop", "old", draft, published) {
+		t.Error("old image not evaluated")
+	}
+	if filter.IsFilteredRow("shop", "either", draft, published) || filter.IsFilteredRow("shop", "either", published, draft) {
+		t.Error("rows moving in or out of scope must match")
+	}
+	if !filter.IsFilteredRow("shop", "either", draft, draft) {
+		t.Error("rows out of scope must be filtered")
+	}
+	if filter.IsFilteredRow("shop", "customer", draft, nil) {
+		t.Error("rows of tables without predicate must not be filtered")
+	}
+
+	var nilFilter *RowFilter
+	if nilFilter.IsFilteredRow("shop", "orders", draft, nil) {
+		t.Fail()
+	}
+}
@@ -13,12 +13,18 @@ const (
 	SinkStart   = "StartSink"
 	SinkStop    = "StopSink"
 	SinkRestart = "RestartSink"
+	SinkAdd     = "AddSink"
+	SinkRemove  = "RemoveSink"
+	SinkLink    = "LinkSink"
+	SinkUnlink  = "UnlinkSink"
 
 	SourceStart   = "StartSource"
 	SourceStop    = "StopSource"
 	SourceRestart = "RestartSource"
 	SourceQuery   = "QuerySource"
 	SourceMeta    = "SourceMeta"
+	SourceAdd     = "AddSource"
+	SourceRemove  = "RemoveSource"
 
 	TaskPending = "PENDING"
 	TaskRunning = "IN_PROGRESS"
@@ -68,11 +74,15 @@ func DeclareNewTaskDescription(class interface{}, description string) (task *Tas
 
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
+		typeName := field.Type.Name()
+		if typeName == "" {
+			typeName = field.Type.Kind().String()
+		}
 
 		task.Parameters = append(task.Parameters, &ParametersDescription{
 			Name:        field.Tag.Get("name"),
 			Description: field.Tag.Get("description"),
-			Type:        field.Type.Name(),
+			Type:        typeName,
 			Required:    field.Tag.Get("required") == "true",
 		})
 	}
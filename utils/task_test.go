@@ -29,3 +29,15 @@ func TestDeclareNewTaskNilClass(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestDeclareNewTaskUnnamedType(t *testing.T) {
+	type AddAction struct {
+		config map[string]interface{} `name:"config" description:"component config" required:"true"` // nolint
+	}
+
+	actionDeclaration := DeclareNewTaskDescription(AddAction{}, "Add component")
+
+	if actionDeclaration.Parameters[0].Type != "map" {
+		t.Fail()
+	}
+}